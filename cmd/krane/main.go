@@ -31,7 +31,12 @@ func init() {
 	utils.EnvOrDefault(constants.EnvProxyEnabled, "true")
 	utils.EnvOrDefault(constants.EnvProxyDashboardSecure, "false")
 	utils.EnvOrDefault(constants.EnvProxyDashboardAlias, "")
+	utils.EnvOrDefault(constants.EnvProxyConfigDir, "/tmp/krane/proxy")
+	utils.EnvOrDefault(constants.EnvProxyEntryPoints, "")
+	utils.EnvOrDefault(constants.EnvProxyBackend, "traefik")
 	utils.EnvOrDefault(constants.EnvLetsEncryptEmail, "")
+	utils.EnvOrDefault(constants.EnvHostPortRangeStart, "30000")
+	utils.EnvOrDefault(constants.EnvHostPortRangeEnd, "32767")
 
 	logger.Configure()
 	logger.Info("Setting up Krane")
@@ -54,14 +59,20 @@ func main() {
 	qsize := utils.UIntEnv(constants.EnvJobQueueSize)
 	queue := job.NewBufferedQueue(qsize)
 
+	enqueuer := job.NewEnqueuer(queue)
+	interval := utils.EnvOrDefault(constants.EnvSchedulerIntervalMs, utils.TwoMinMs)
+	jobScheduler := scheduler.New(db, docker.GetClient(), enqueuer, interval)
+
+	// deployment schedules are evaluated in a separate routine queuing tasks when they are due
+	go jobScheduler.RunSchedules()
+
+	// the previous color of blue/green deployments is only kept around for a while for rollbacks
+	go jobScheduler.RunColorExpirations()
+
 	// if watch mode is enabled, the scheduler will run in a separate routine polling
 	// and queuing jobs to maintain the deployment state in parity with the desired state
 	if utils.BoolEnv(constants.EnvWatchMode) {
 		logger.Warn("The feature watch mode is experimental. Krane will attempt to keep your containers state as close to you deployment configuration even when deployments arent triggered.")
-		enqueuer := job.NewEnqueuer(queue)
-		interval := utils.EnvOrDefault(constants.EnvSchedulerIntervalMs, utils.TwoMinMs)
-
-		jobScheduler := scheduler.New(db, docker.GetClient(), enqueuer, interval)
 		go jobScheduler.Run()
 	}
 
@@ -1,14 +1,18 @@
 package main
 
 import (
+	"fmt"
 	"os"
+	"path"
 
 	"github.com/krane/krane/internal/constants"
 	"github.com/krane/krane/internal/deployment"
 	"github.com/krane/krane/internal/logger"
+	"github.com/krane/krane/internal/proxy"
 	"github.com/krane/krane/internal/utils"
 )
 
+// proxyConfig is the network proxy deployment when using the Traefik proxy backend
 var proxyConfig = deployment.Config{
 	Name:     "krane-proxy",
 	Image:    "biensupernice/proxy",
@@ -19,14 +23,46 @@ var proxyConfig = deployment.Config{
 	Env: map[string]string{
 		constants.EnvLetsEncryptEmail: os.Getenv(constants.EnvLetsEncryptEmail),
 	},
-	Volumes: map[string]string{
-		"/var/run/docker.sock": "/var/run/docker.sock",
+	Volumes: deployment.VolumeConfigs{
+		{
+			Type:   deployment.BindVolume,
+			Source: "/var/run/docker.sock",
+			Target: "/var/run/docker.sock",
+		},
 	},
 	TargetPort: "8080",
-	Ports: map[string]string{
-		"80":  "80",
-		"443": "443",
-		"":    "8080",
+	Ports: deployment.PortConfigs{
+		{HostPort: "80", ContainerPort: "80", Protocol: deployment.TCP},
+		{HostPort: "443", ContainerPort: "443", Protocol: deployment.TCP},
+		{ContainerPort: "8080", Protocol: deployment.TCP},
+	},
+}
+
+// caddyProxyConfig is the network proxy deployment when using the Caddy proxy backend, Caddy loads the
+// Caddyfile written to the proxy config directory and reloads it on changes. Certificates requested by
+// Caddy are kept in a named volume.
+var caddyProxyConfig = deployment.Config{
+	Name:     "krane-proxy",
+	Image:    "caddy",
+	Tag:      "2",
+	Scale:    1,
+	Internal: true,
+	Command: deployment.Args{
+		"caddy", "run",
+		"--config", path.Join(proxy.FileProviderDirectory, proxy.CaddyfileName),
+		"--adapter", "caddyfile",
+		"--watch",
+	},
+	Volumes: deployment.VolumeConfigs{
+		{
+			Type:   deployment.NamedVolume,
+			Source: "krane-proxy-data",
+			Target: "/data",
+		},
+	},
+	Ports: deployment.PortConfigs{
+		{HostPort: "80", ContainerPort: "80", Protocol: deployment.TCP},
+		{HostPort: "443", ContainerPort: "443", Protocol: deployment.TCP},
 	},
 }
 
@@ -34,26 +70,46 @@ var proxyConfig = deployment.Config{
 // attempt to create it. Default behavior is to create the network proxy to allow deployment aliases. This behavior
 // can be turned off using the environment variable PROXY_ENABLED which wont create the network proxy if set to false.
 func EnsureNetworkProxy() {
+	backend, err := proxy.ParseBackend(os.Getenv(constants.EnvProxyBackend))
+	if err != nil {
+		logger.Fatalf("Unable to create network proxy, %v", err)
+	}
+
 	isEnabled := utils.BoolEnv(constants.EnvProxyEnabled)
 	if !isEnabled {
 		logger.Info("Network proxy not enabled")
 		return
 	}
 
+	// uploaded certificates are written to the proxy config directory, which may not persist across restarts
+	if err := deployment.SyncCertificates(); err != nil {
+		logger.Errorf("Unable to write uploaded certificates, %v", err)
+	}
+
+	// proxies configured with files (ie. the Caddyfile) load them on startup
+	if err := deployment.SyncProxy(); err != nil {
+		logger.Errorf("Unable to write proxy configuration, %v", err)
+	}
+
 	leEmail := os.Getenv(constants.EnvLetsEncryptEmail)
-	if proxyConfig.Secure && leEmail == "" {
+	if backend.Name() == proxy.TraefikBackendName && proxyConfig.Secure && leEmail == "" {
 		logger.Fatalf("Missing required environment variable %s when running in SECURE mode", constants.EnvLetsEncryptEmail)
 	}
 
+	config, err := networkProxyConfig(backend)
+	if err != nil {
+		logger.Fatalf("Unable to create network proxy, %v", err)
+	}
+
 	// get containers (if any) for the proxy deployment
-	containers, err := deployment.GetContainersByDeployment(proxyConfig.Name)
+	containers, err := deployment.GetContainersByDeployment(config.Name)
 	if err != nil {
 		logger.Fatalf("Unable to create network proxy, %v", err)
 	}
 
 	// create the proxy if no containers are currently up
 	if len(containers) == 0 {
-		err := createProxy()
+		err := createProxy(config)
 		if err != nil {
 			// if we cant create the proxy, exit the program
 			logger.Fatalf("Unable to create network proxy, %v", err)
@@ -62,10 +118,20 @@ func EnsureNetworkProxy() {
 		return
 	}
 
+	// re-create the proxy if the proxy backend changed
+	current, err := deployment.GetDeploymentConfig(config.Name)
+	if err == nil && current.Image != config.Image {
+		logger.Infof("Network proxy backend changed to %s, re-creating the network proxy", backend.Name())
+		if err := createProxy(config); err != nil {
+			logger.Fatalf("Unable to create network proxy, %v", err)
+		}
+		return
+	}
+
 	// create the proxy if no containers are in a running state
 	for _, c := range containers {
 		if !c.State.Running {
-			err := createProxy()
+			err := createProxy(config)
 			if err != nil {
 				// if we cant create the proxy, exit the program
 				logger.Fatalf("Unable to create network proxy, %v", err)
@@ -78,15 +144,93 @@ func EnsureNetworkProxy() {
 	logger.Debug("Network proxy already in a running state")
 }
 
-func createProxy() error {
-	if err := deployment.SaveConfig(proxyConfig); err != nil {
+// networkProxyConfig returns the network proxy deployment of a proxy backend
+func networkProxyConfig(backend proxy.Backend) (deployment.Config, error) {
+	entryPoints, err := proxy.EntryPoints()
+	if err != nil {
+		return deployment.Config{}, err
+	}
+
+	if backend.Name() == proxy.CaddyBackendName {
+		if len(entryPoints) > 0 {
+			return deployment.Config{}, fmt.Errorf("%s are not supported by the %s proxy", constants.EnvProxyEntryPoints, backend.Name())
+		}
+		return withConfigDirectory(caddyProxyConfig), nil
+	}
+
+	return withEntryPoints(withFileProvider(proxyConfig), entryPoints), nil
+}
+
+func createProxy(config deployment.Config) error {
+	// the proxy config directory is bind mounted into the proxy and must exist beforehand
+	if err := os.MkdirAll(os.Getenv(constants.EnvProxyConfigDir), 0755); err != nil {
+		return err
+	}
+
+	if err := deployment.SaveConfig(config); err != nil {
 		return err
 	}
 
-	if err := deployment.Run(proxyConfig.Name); err != nil {
+	if err := deployment.Run(config.Name); err != nil {
 		return err
 	}
 
 	logger.Debug("Network proxy deployment started")
 	return nil
 }
+
+// withFileProvider returns the proxy configuration loading the dynamic configuration files written
+// by Krane (ie. weighted services for canaries) from the proxy config directory
+func withFileProvider(config deployment.Config) deployment.Config {
+	env := make(map[string]string, 0)
+	for k, v := range config.Env {
+		env[k] = v
+	}
+	env["TRAEFIK_PROVIDERS_FILE_DIRECTORY"] = proxy.FileProviderDirectory
+	env["TRAEFIK_PROVIDERS_FILE_WATCH"] = "true"
+	config.Env = env
+
+	return withConfigDirectory(config)
+}
+
+// withConfigDirectory returns the proxy configuration mounting the proxy config directory Krane writes
+// configuration files to
+func withConfigDirectory(config deployment.Config) deployment.Config {
+	volumes := make(deployment.VolumeConfigs, 0)
+	volumes = append(volumes, config.Volumes...)
+	volumes = append(volumes, deployment.VolumeConfig{
+		Type:     deployment.BindVolume,
+		Source:   os.Getenv(constants.EnvProxyConfigDir),
+		Target:   proxy.FileProviderDirectory,
+		ReadOnly: true,
+	})
+	config.Volumes = volumes
+
+	return config
+}
+
+// withEntryPoints returns the proxy configuration listening on the TCP and UDP entrypoints set by
+// PROXY_ENTRYPOINTS, each entrypoint binds the same port on the host
+func withEntryPoints(config deployment.Config, entryPoints []proxy.EntryPoint) deployment.Config {
+	env := make(map[string]string, 0)
+	for k, v := range config.Env {
+		env[k] = v
+	}
+	for k, v := range proxy.TraefikEntryPointEnv(entryPoints) {
+		env[k] = v
+	}
+	config.Env = env
+
+	ports := make(deployment.PortConfigs, 0)
+	ports = append(ports, config.Ports...)
+	for _, ep := range entryPoints {
+		ports = append(ports, deployment.PortConfig{
+			HostPort:      ep.Port,
+			ContainerPort: ep.Port,
+			Protocol:      deployment.PortProtocol(ep.Protocol),
+		})
+	}
+	config.Ports = ports
+
+	return config
+}
@@ -59,14 +59,54 @@ func withRoutes(router *mux.Router) {
 	withRoute(authRouter, "/deployments/{deployment}", controllers.GetDeployment, middlewares.ValidateSessionMiddleware).Methods(http.MethodGet)
 	withRoute(authRouter, "/deployments/{deployment}", controllers.RunDeployment, middlewares.ValidateSessionMiddleware).Methods(http.MethodPost)
 	withRoute(authRouter, "/deployments/{deployment}", controllers.DeleteDeployment, middlewares.ValidateSessionMiddleware).Methods(http.MethodDelete)
+	withRoute(authRouter, "/deployments/{deployment}/plan", controllers.PlanDeployment, middlewares.ValidateSessionMiddleware).Methods(http.MethodPost)
 	withRoute(authRouter, "/deployments/{deployment}/containers", controllers.GetDeploymentContainers, middlewares.ValidateSessionMiddleware).Methods(http.MethodGet)
 	withRoute(authRouter, "/deployments/{deployment}/containers/start", controllers.StartDeploymentContainers, middlewares.ValidateSessionMiddleware).Methods(http.MethodPost)
 	withRoute(authRouter, "/deployments/{deployment}/containers/stop", controllers.StopDeploymentContainers, middlewares.ValidateSessionMiddleware).Methods(http.MethodPost)
 	withRoute(authRouter, "/deployments/{deployment}/containers/restart", controllers.RestartDeploymentContainers, middlewares.ValidateSessionMiddleware).Methods(http.MethodPost)
+	withRoute(authRouter, "/deployments/{deployment}/tasks", controllers.RunDeploymentTask, middlewares.ValidateSessionMiddleware).Methods(http.MethodPost)
+	withRoute(authRouter, "/deployments/{deployment}/schedules", controllers.GetDeploymentSchedules, middlewares.ValidateSessionMiddleware).Methods(http.MethodGet)
+	withRoute(authRouter, "/deployments/{deployment}/ports", controllers.GetDeploymentPortAllocations, middlewares.ValidateSessionMiddleware).Methods(http.MethodGet)
+	withRoute(authRouter, "/deployments/{deployment}/canary", controllers.GetDeploymentCanary, middlewares.ValidateSessionMiddleware).Methods(http.MethodGet)
+	withRoute(authRouter, "/deployments/{deployment}/canary/weight", controllers.ShiftDeploymentCanary, middlewares.ValidateSessionMiddleware).Methods(http.MethodPost)
+	withRoute(authRouter, "/deployments/{deployment}/canary/promote", controllers.PromoteDeploymentCanary, middlewares.ValidateSessionMiddleware).Methods(http.MethodPost)
+	withRoute(authRouter, "/deployments/{deployment}/canary/abort", controllers.AbortDeploymentCanary, middlewares.ValidateSessionMiddleware).Methods(http.MethodPost)
+	withRoute(authRouter, "/deployments/{deployment}/blue-green", controllers.GetDeploymentColors, middlewares.ValidateSessionMiddleware).Methods(http.MethodGet)
+	withRoute(authRouter, "/deployments/{deployment}/blue-green/rollback", controllers.RollbackDeploymentColor, middlewares.ValidateSessionMiddleware).Methods(http.MethodPost)
+	withRoute(authRouter, "/deployments/{deployment}/maintenance", controllers.GetDeploymentMaintenance, middlewares.ValidateSessionMiddleware).Methods(http.MethodGet)
+	withRoute(authRouter, "/deployments/{deployment}/maintenance", controllers.SetDeploymentMaintenance, middlewares.ValidateSessionMiddleware).Methods(http.MethodPost)
+	withRoute(authRouter, "/deployments/{deployment}/volumes/{volume}/archive", controllers.ArchiveDeploymentVolume, middlewares.ValidateSessionMiddleware).Methods(http.MethodGet)
+	withRoute(authRouter, "/deployments/{deployment}/volumes/{volume}/archive", controllers.RestoreDeploymentVolume, middlewares.ValidateSessionMiddleware).Methods(http.MethodPut)
+	// apply
+	withRoute(authRouter, "/apply", controllers.ApplyBundle, middlewares.ValidateSessionMiddleware).Methods(http.MethodPost)
+	withRoute(authRouter, "/apply/{batch}", controllers.GetApplyBatch, middlewares.ValidateSessionMiddleware).Methods(http.MethodGet)
+	withRoute(authRouter, "/import/compose", controllers.ImportCompose, middlewares.ValidateSessionMiddleware).Methods(http.MethodPost)
+	withRoute(authRouter, "/export", controllers.ExportDeployments, middlewares.ValidateSessionMiddleware).Methods(http.MethodPost)
+	withRoute(authRouter, "/import", controllers.ImportDeployments, middlewares.ValidateSessionMiddleware).Methods(http.MethodPost)
+	withRoute(authRouter, "/import/recipient", controllers.GetImportRecipient, middlewares.ValidateSessionMiddleware).Methods(http.MethodGet)
+	// templates
+	withRoute(authRouter, "/templates", controllers.GetTemplates, middlewares.ValidateSessionMiddleware).Methods(http.MethodGet)
+	withRoute(authRouter, "/templates", controllers.SaveTemplate, middlewares.ValidateSessionMiddleware).Methods(http.MethodPost)
+	withRoute(authRouter, "/templates/{template}", controllers.GetTemplate, middlewares.ValidateSessionMiddleware).Methods(http.MethodGet)
+	withRoute(authRouter, "/templates/{template}", controllers.DeleteTemplate, middlewares.ValidateSessionMiddleware).Methods(http.MethodDelete)
+	withRoute(authRouter, "/templates/{template}/instantiate", controllers.InstantiateTemplate, middlewares.ValidateSessionMiddleware).Methods(http.MethodPost)
+	withRoute(authRouter, "/templates/{template}/apply", controllers.ApplyTemplate, middlewares.ValidateSessionMiddleware).Methods(http.MethodPost)
+	// certificates
+	withRoute(authRouter, "/certificates", controllers.GetCertificates, middlewares.ValidateSessionMiddleware).Methods(http.MethodGet)
+	withRoute(authRouter, "/certificates", controllers.SaveCertificate, middlewares.ValidateSessionMiddleware).Methods(http.MethodPost)
+	withRoute(authRouter, "/certificates/{certificate}", controllers.GetCertificate, middlewares.ValidateSessionMiddleware).Methods(http.MethodGet)
+	withRoute(authRouter, "/certificates/{certificate}", controllers.DeleteCertificate, middlewares.ValidateSessionMiddleware).Methods(http.MethodDelete)
 	// secrets
 	withRoute(authRouter, "/secrets/{deployment}", controllers.GetSecrets, middlewares.ValidateSessionMiddleware).Methods(http.MethodGet)
 	withRoute(authRouter, "/secrets/{deployment}", controllers.CreateOrUpdateSecret, middlewares.ValidateSessionMiddleware).Methods(http.MethodPost)
 	withRoute(authRouter, "/secrets/{deployment}/{key}", controllers.DeleteSecret, middlewares.ValidateSessionMiddleware).Methods(http.MethodDelete)
+	// volumes
+	withRoute(authRouter, "/volumes", controllers.GetVolumes, middlewares.ValidateSessionMiddleware).Methods(http.MethodGet)
+	withRoute(authRouter, "/volumes", controllers.CreateVolume, middlewares.ValidateSessionMiddleware).Methods(http.MethodPost)
+	withRoute(authRouter, "/volumes/{volume}", controllers.GetVolume, middlewares.ValidateSessionMiddleware).Methods(http.MethodGet)
+	withRoute(authRouter, "/volumes/{volume}", controllers.RemoveVolume, middlewares.ValidateSessionMiddleware).Methods(http.MethodDelete)
+	// ports
+	withRoute(authRouter, "/ports", controllers.GetPortAllocations, middlewares.ValidateSessionMiddleware).Methods(http.MethodGet)
 	// jobs
 	withRoute(authRouter, "/jobs", controllers.GetJobsByDaysAgo, middlewares.ValidateSessionMiddleware).Methods(http.MethodGet)
 	withRoute(authRouter, "/jobs/{deployment}", controllers.GetJobsByDeployment, middlewares.ValidateSessionMiddleware).Methods(http.MethodGet)
@@ -79,6 +119,7 @@ func withRoutes(router *mux.Router) {
 	withRoute(authRouter, "/ws/containers/{container}/logs", controllers.SubscribeToContainerLogs, middlewares.ValidateSessionMiddleware).Methods(http.MethodGet)
 	withRoute(authRouter, "/ws/deployments/{deployment}/logs", controllers.SubscribeToDeploymentLogs, middlewares.ValidateSessionMiddleware).Methods(http.MethodGet)
 	withRoute(authRouter, "/ws/deployments/{deployment}/events", controllers.SubscribeToDeploymentEvents, middlewares.ValidateSessionMiddleware).Methods(http.MethodGet)
+	withRoute(authRouter, "/ws/deployments/{deployment}/tasks/{task}/logs", controllers.SubscribeToTaskLogs, middlewares.ValidateSessionMiddleware).Methods(http.MethodGet)
 }
 
 type routeHandler func(http.ResponseWriter, *http.Request)
@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/krane/krane/internal/api/response"
+	"github.com/krane/krane/internal/deployment"
+	"github.com/krane/krane/internal/utils"
+)
+
+// ApplyBundle applies a bundle of deployment configurations as a batch of jobs.
+// When the query param dry_run=true is provided, the plan is returned without applying it
+func ApplyBundle(w http.ResponseWriter, r *http.Request) {
+	var bundle deployment.Bundle
+
+	if err := json.NewDecoder(r.Body).Decode(&bundle); err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(utils.QueryParamOrDefault(r, "dry_run", "false"))
+	if dryRun {
+		plan, err := deployment.PlanBundle(bundle)
+		if err != nil {
+			response.HTTPBad(w, err)
+			return
+		}
+
+		response.HTTPOk(w, plan)
+		return
+	}
+
+	batch, err := deployment.Apply(bundle)
+	if err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	response.HTTPAcceptedWithBody(w, batch)
+	return
+}
+
+// GetApplyBatch returns an apply batch and the status of its jobs
+func GetApplyBatch(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	batchID := params["batch"]
+
+	if batchID == "" {
+		response.HTTPBad(w, errors.New("batch id not provided"))
+		return
+	}
+
+	batch, err := deployment.GetBatch(batchID)
+	if err != nil {
+		response.HTTPNotFound(w, err)
+		return
+	}
+
+	response.HTTPOk(w, batch)
+	return
+}
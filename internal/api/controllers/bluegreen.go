@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/krane/krane/internal/api/response"
+	"github.com/krane/krane/internal/deployment"
+)
+
+// GetDeploymentColors returns the active and previous colors of a blue/green deployment
+func GetDeploymentColors(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	deploymentName := params["deployment"]
+
+	if deploymentName == "" {
+		response.HTTPBad(w, errors.New("deployment name not provided"))
+		return
+	}
+
+	bg, err := deployment.GetBlueGreen(deploymentName)
+	if err != nil {
+		response.HTTPNotFound(w, err)
+		return
+	}
+
+	response.HTTPOk(w, bg)
+	return
+}
+
+// RollbackDeploymentColor switches the traffic of a blue/green deployment back to its previous color
+func RollbackDeploymentColor(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	deploymentName := params["deployment"]
+
+	if deploymentName == "" {
+		response.HTTPBad(w, errors.New("deployment name not provided"))
+		return
+	}
+
+	if !deployment.Exist(deploymentName) {
+		response.HTTPBad(w, fmt.Errorf("deployment %s does not exist", deploymentName))
+		return
+	}
+
+	bg, err := deployment.RollbackBlueGreen(deploymentName)
+	if err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	response.HTTPOk(w, bg)
+	return
+}
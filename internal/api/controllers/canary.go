@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/krane/krane/internal/api/response"
+	"github.com/krane/krane/internal/deployment"
+)
+
+// ShiftCanaryRequest is the request body for shifting the traffic routed to a canary
+type ShiftCanaryRequest struct {
+	Weight int `json:"weight"` // percentage of traffic routed to the canary containers
+}
+
+// GetDeploymentCanary returns the canary of a deployment
+func GetDeploymentCanary(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	deploymentName := params["deployment"]
+
+	if deploymentName == "" {
+		response.HTTPBad(w, errors.New("deployment name not provided"))
+		return
+	}
+
+	canary, err := deployment.GetCanary(deploymentName)
+	if err != nil {
+		response.HTTPNotFound(w, err)
+		return
+	}
+
+	response.HTTPOk(w, canary)
+	return
+}
+
+// ShiftDeploymentCanary updates the percentage of traffic routed to the canary of a deployment
+func ShiftDeploymentCanary(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	deploymentName := params["deployment"]
+
+	if deploymentName == "" {
+		response.HTTPBad(w, errors.New("deployment name not provided"))
+		return
+	}
+
+	var body ShiftCanaryRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	canary, err := deployment.ShiftCanary(deploymentName, body.Weight)
+	if err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	response.HTTPOk(w, canary)
+	return
+}
+
+// PromoteDeploymentCanary rolls out the canary of a deployment to all containers
+func PromoteDeploymentCanary(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	deploymentName := params["deployment"]
+
+	if deploymentName == "" {
+		response.HTTPBad(w, errors.New("deployment name not provided"))
+		return
+	}
+
+	if !deployment.Exist(deploymentName) {
+		response.HTTPBad(w, fmt.Errorf("deployment %s does not exist", deploymentName))
+		return
+	}
+
+	if err := deployment.PromoteCanary(deploymentName); err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	response.HTTPAccepted(w)
+	return
+}
+
+// AbortDeploymentCanary removes the canary of a deployment and restores its stable configuration
+func AbortDeploymentCanary(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	deploymentName := params["deployment"]
+
+	if deploymentName == "" {
+		response.HTTPBad(w, errors.New("deployment name not provided"))
+		return
+	}
+
+	if !deployment.Exist(deploymentName) {
+		response.HTTPBad(w, fmt.Errorf("deployment %s does not exist", deploymentName))
+		return
+	}
+
+	if err := deployment.AbortCanary(deploymentName); err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	response.HTTPAccepted(w)
+	return
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/krane/krane/internal/api/response"
+	"github.com/krane/krane/internal/deployment"
+)
+
+// SaveCertificateRequest represents an uploaded certificate, the certificate chain and private key are PEM encoded
+type SaveCertificateRequest struct {
+	Name string `json:"name"`
+	Cert string `json:"cert"`
+	Key  string `json:"key"`
+}
+
+// GetCertificates returns all uploaded certificates
+func GetCertificates(w http.ResponseWriter, _ *http.Request) {
+	certificates, err := deployment.GetAllCertificates()
+	if err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	response.HTTPOk(w, certificates)
+	return
+}
+
+// GetCertificate returns an uploaded certificate
+func GetCertificate(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	certificateName := params["certificate"]
+
+	if certificateName == "" {
+		response.HTTPBad(w, errors.New("certificate name not provided"))
+		return
+	}
+
+	certificate, err := deployment.GetCertificate(certificateName)
+	if err != nil {
+		response.HTTPNotFound(w, err)
+		return
+	}
+
+	response.HTTPOk(w, certificate)
+	return
+}
+
+// SaveCertificate uploads or replaces a certificate
+func SaveCertificate(w http.ResponseWriter, r *http.Request) {
+	var request SaveCertificateRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	if request.Cert == "" || request.Key == "" {
+		response.HTTPBad(w, errors.New("certificate and private key required"))
+		return
+	}
+
+	certificate, err := deployment.SaveCertificate(request.Name, request.Cert, request.Key)
+	if err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	response.HTTPOk(w, certificate)
+	return
+}
+
+// DeleteCertificate removes an uploaded certificate not used by any deployment
+func DeleteCertificate(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	certificateName := params["certificate"]
+
+	if certificateName == "" {
+		response.HTTPBad(w, errors.New("certificate name not provided"))
+		return
+	}
+
+	if _, err := deployment.GetCertificate(certificateName); err != nil {
+		response.HTTPNotFound(w, err)
+		return
+	}
+
+	if err := deployment.DeleteCertificate(certificateName); err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	response.HTTPNoContent(w)
+	return
+}
@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/krane/krane/internal/api/response"
+	"github.com/krane/krane/internal/compose"
+	"github.com/krane/krane/internal/deployment"
+	"github.com/krane/krane/internal/utils"
+)
+
+// ComposeImportResponse represents the deployments converted from a compose file and the batch applying them
+type ComposeImportResponse struct {
+	compose.Result
+	Batch *deployment.ApplyBatch `json:"batch,omitempty"`
+}
+
+// ImportCompose converts the services of a compose file into deployment configurations. When the
+// query param apply=true is provided, the deployments are saved and run as an apply batch
+func ImportCompose(w http.ResponseWriter, r *http.Request) {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	result, err := compose.Convert(data)
+	if err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	apply, _ := strconv.ParseBool(utils.QueryParamOrDefault(r, "apply", "false"))
+	if !apply {
+		response.HTTPOk(w, ComposeImportResponse{Result: result})
+		return
+	}
+
+	batch, err := deployment.Apply(deployment.Bundle{Deployments: result.Deployments})
+	if err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	response.HTTPAcceptedWithBody(w, ComposeImportResponse{Result: result, Batch: &batch})
+	return
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/gorilla/mux"
 
@@ -13,6 +14,7 @@ import (
 	"github.com/krane/krane/internal/api/response"
 	"github.com/krane/krane/internal/deployment"
 	"github.com/krane/krane/internal/session"
+	"github.com/krane/krane/internal/utils"
 )
 
 // WSUpgrader upgrades HTTP connections to WebSocket connections
@@ -81,7 +83,35 @@ func CreateOrUpdateDeployment(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
-// DeleteDeployment deletes a deployments container resources and configuration
+// PlanDeployment returns the changes running a candidate deployment configuration would make
+// compared to the stored configuration and live containers, without saving or running it
+func PlanDeployment(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	deploymentName := params["deployment"]
+
+	if deploymentName == "" {
+		response.HTTPBad(w, errors.New("deployment name not provided"))
+		return
+	}
+
+	var config deployment.Config
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	plan, err := deployment.PlanConfig(deploymentName, config)
+	if err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	response.HTTPOk(w, plan)
+	return
+}
+
+// DeleteDeployment deletes a deployments container resources and configuration.
+// Named volumes are kept unless the query param remove_volumes=true is provided
 func DeleteDeployment(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	deploymentName := params["deployment"]
@@ -96,7 +126,9 @@ func DeleteDeployment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := deployment.Delete(deploymentName); err != nil {
+	removeVolumes, _ := strconv.ParseBool(utils.QueryParamOrDefault(r, "remove_volumes", "false"))
+
+	if err := deployment.Delete(deploymentName, removeVolumes); err != nil {
 		response.HTTPBad(w, err)
 		return
 	}
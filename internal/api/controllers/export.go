@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/krane/krane/internal/api/response"
+	"github.com/krane/krane/internal/deployment"
+	"github.com/krane/krane/internal/utils"
+)
+
+// ExportRequest represents the options for exporting deployments
+type ExportRequest struct {
+	RecipientKey string `json:"recipient_key"` // public key secret values are encrypted to, values are not exported when empty
+}
+
+// RecipientResponse represents the public key exports are encrypted to when importing into this server
+type RecipientResponse struct {
+	PublicKey string `json:"public_key"`
+}
+
+// ExportDeployments returns every deployment configuration and secret names as a single file
+func ExportDeployments(w http.ResponseWriter, r *http.Request) {
+	var request ExportRequest
+
+	// the request body is optional, secret values are only exported when a recipient key is provided
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	export, err := deployment.ExportDeployments(request.RecipientKey)
+	if err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	filename := fmt.Sprintf("krane-export-%s.json", time.Unix(export.Metadata.CreatedAt, 0).UTC().Format("20060102150405"))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	response.HTTPOk(w, export)
+	return
+}
+
+// ImportDeployments recreates the deployments of an export. Deployments with the name of an existing
+// deployment are reported as conflicts unless the query param overwrite=true is provided
+func ImportDeployments(w http.ResponseWriter, r *http.Request) {
+	var export deployment.Export
+
+	if err := json.NewDecoder(r.Body).Decode(&export); err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	overwrite, _ := strconv.ParseBool(utils.QueryParamOrDefault(r, "overwrite", "false"))
+
+	result, err := deployment.ImportDeployments(export, overwrite)
+	if err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	response.HTTPOk(w, result)
+	return
+}
+
+// GetImportRecipient returns the public key exports are encrypted to when moving deployments to this server
+func GetImportRecipient(w http.ResponseWriter, _ *http.Request) {
+	publicKey, err := deployment.GetRecipientPublicKey()
+	if err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	response.HTTPOk(w, RecipientResponse{PublicKey: publicKey})
+	return
+}
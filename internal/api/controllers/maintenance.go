@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/krane/krane/internal/api/response"
+	"github.com/krane/krane/internal/deployment"
+)
+
+// MaintenanceRequest turns the maintenance of a deployment on or off
+type MaintenanceRequest struct {
+	Enabled    bool   `json:"enabled"`
+	Page       string `json:"page"`        // HTML page served to visitors (default page when empty)
+	RetryAfter int64  `json:"retry_after"` // seconds sent in the Retry-After header (default 300)
+}
+
+// GetDeploymentMaintenance returns the maintenance of a deployment
+func GetDeploymentMaintenance(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	deploymentName := params["deployment"]
+
+	if deploymentName == "" {
+		response.HTTPBad(w, errors.New("deployment name not provided"))
+		return
+	}
+
+	maintenance, err := deployment.GetMaintenance(deploymentName)
+	if err != nil {
+		response.HTTPNotFound(w, err)
+		return
+	}
+
+	response.HTTPOk(w, maintenance)
+	return
+}
+
+// SetDeploymentMaintenance puts a deployment in maintenance or routes its requests back to the containers
+func SetDeploymentMaintenance(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	deploymentName := params["deployment"]
+
+	if deploymentName == "" {
+		response.HTTPBad(w, errors.New("deployment name not provided"))
+		return
+	}
+
+	if !deployment.Exist(deploymentName) {
+		response.HTTPBad(w, fmt.Errorf("deployment %s does not exist", deploymentName))
+		return
+	}
+
+	var request MaintenanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	if !request.Enabled {
+		if err := deployment.DisableMaintenance(deploymentName); err != nil {
+			response.HTTPBad(w, err)
+			return
+		}
+
+		response.HTTPNoContent(w)
+		return
+	}
+
+	maintenance, err := deployment.EnableMaintenance(deploymentName, request.Page, request.RetryAfter)
+	if err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	response.HTTPOk(w, maintenance)
+	return
+}
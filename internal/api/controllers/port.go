@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/krane/krane/internal/api/response"
+	"github.com/krane/krane/internal/deployment"
+)
+
+// GetPortAllocations returns all host ports allocated to deployments
+func GetPortAllocations(w http.ResponseWriter, _ *http.Request) {
+	allocations, err := deployment.GetPortAllocations()
+	if err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	response.HTTPOk(w, allocations)
+	return
+}
+
+// GetDeploymentPortAllocations returns the host ports allocated to a deployment
+func GetDeploymentPortAllocations(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	deploymentName := params["deployment"]
+
+	if deploymentName == "" {
+		response.HTTPBad(w, errors.New("deployment name not provided"))
+		return
+	}
+
+	if !deployment.Exist(deploymentName) {
+		response.HTTPBad(w, fmt.Errorf("deployment %s does not exist", deploymentName))
+		return
+	}
+
+	allocations, err := deployment.GetPortAllocationsByDeployment(deploymentName)
+	if err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	response.HTTPOk(w, allocations)
+	return
+}
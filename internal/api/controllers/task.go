@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+
+	"github.com/krane/krane/internal/api/response"
+	"github.com/krane/krane/internal/deployment"
+	"github.com/krane/krane/internal/logger"
+)
+
+// TaskResponse is the response when queueing a deployment task
+type TaskResponse struct {
+	ID string `json:"id"`
+}
+
+// RunDeploymentTask queues a one-off task container for a deployment returning the task id.
+// The task outcome is recorded as a job with the same id.
+func RunDeploymentTask(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	deploymentName := params["deployment"]
+
+	if deploymentName == "" {
+		response.HTTPBad(w, errors.New("deployment name not provided"))
+		return
+	}
+
+	if !deployment.Exist(deploymentName) {
+		response.HTTPBad(w, fmt.Errorf("deployment %s does not exist", deploymentName))
+		return
+	}
+
+	// an empty body runs the task with the deployment command
+	var request deployment.TaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	taskID, err := deployment.RunTask(deploymentName, request)
+	if err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	response.HTTPAcceptedWithBody(w, TaskResponse{ID: taskID})
+	return
+}
+
+// SubscribeToTaskLogs opens a websocket connection and subscribes the client to the output of a task
+func SubscribeToTaskLogs(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	taskID := params["task"]
+
+	connection, err := WSUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	if err := deployment.SubscribeToTaskLogs(connection, taskID); err != nil {
+		_ = connection.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, err.Error()))
+		if err := connection.Close(); err != nil {
+			logger.Warnf("error closing client connection, %v", err)
+		}
+	}
+	return
+}
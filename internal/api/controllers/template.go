@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/krane/krane/internal/api/response"
+	"github.com/krane/krane/internal/deployment"
+)
+
+// InstantiateTemplateRequest represents the variables substituted in a template
+type InstantiateTemplateRequest struct {
+	Variables map[string]string `json:"variables"`
+}
+
+// TemplateResponse represents a template and the deployments instantiated from it
+type TemplateResponse struct {
+	deployment.Template
+	Deployments []string `json:"deployments"`
+}
+
+// GetTemplates returns all deployment templates
+func GetTemplates(w http.ResponseWriter, _ *http.Request) {
+	templates, err := deployment.GetAllTemplates()
+	if err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	response.HTTPOk(w, templates)
+	return
+}
+
+// GetTemplate returns a deployment template and the deployments instantiated from it
+func GetTemplate(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	templateName := params["template"]
+
+	if templateName == "" {
+		response.HTTPBad(w, errors.New("template name not provided"))
+		return
+	}
+
+	template, err := deployment.GetTemplate(templateName)
+	if err != nil {
+		response.HTTPNotFound(w, err)
+		return
+	}
+
+	instances, err := deployment.GetTemplateInstances(templateName)
+	if err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	names := make([]string, 0)
+	for _, instance := range instances {
+		names = append(names, instance.Name)
+	}
+
+	response.HTTPOk(w, TemplateResponse{Template: template, Deployments: names})
+	return
+}
+
+// SaveTemplate creates or updates a deployment template
+func SaveTemplate(w http.ResponseWriter, r *http.Request) {
+	var template deployment.Template
+
+	if err := json.NewDecoder(r.Body).Decode(&template); err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	saved, err := deployment.SaveTemplate(template)
+	if err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	response.HTTPOk(w, saved)
+	return
+}
+
+// DeleteTemplate removes a deployment template, deployments instantiated from it are kept
+func DeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	templateName := params["template"]
+
+	if templateName == "" {
+		response.HTTPBad(w, errors.New("template name not provided"))
+		return
+	}
+
+	if _, err := deployment.GetTemplate(templateName); err != nil {
+		response.HTTPNotFound(w, err)
+		return
+	}
+
+	if err := deployment.DeleteTemplate(templateName); err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	response.HTTPNoContent(w)
+	return
+}
+
+// InstantiateTemplate saves and runs a deployment rendered from a template and variables
+func InstantiateTemplate(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	templateName := params["template"]
+
+	if templateName == "" {
+		response.HTTPBad(w, errors.New("template name not provided"))
+		return
+	}
+
+	var request InstantiateTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	config, err := deployment.InstantiateTemplate(templateName, request.Variables)
+	if err != nil {
+		response.HTTPBad(w, fmt.Errorf("unable to instantiate template %s, %v", templateName, err))
+		return
+	}
+
+	response.HTTPAcceptedWithBody(w, config)
+	return
+}
+
+// ApplyTemplate rolls every deployment instantiated from a template forward to the current template revision
+func ApplyTemplate(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	templateName := params["template"]
+
+	if templateName == "" {
+		response.HTTPBad(w, errors.New("template name not provided"))
+		return
+	}
+
+	batch, err := deployment.ApplyTemplate(templateName)
+	if err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	response.HTTPAcceptedWithBody(w, batch)
+	return
+}
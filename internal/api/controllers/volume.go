@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/krane/krane/internal/api/response"
+	"github.com/krane/krane/internal/deployment"
+	"github.com/krane/krane/internal/logger"
+	"github.com/krane/krane/internal/utils"
+)
+
+// GetVolumes returns all Docker volumes managed by Krane
+func GetVolumes(w http.ResponseWriter, _ *http.Request) {
+	volumes, err := deployment.GetVolumes()
+	if err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	response.HTTPOk(w, volumes)
+	return
+}
+
+// GetVolume returns a Docker volume managed by Krane
+func GetVolume(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	volumeName := params["volume"]
+
+	if volumeName == "" {
+		response.HTTPBad(w, errors.New("volume name not provided"))
+		return
+	}
+
+	volume, err := deployment.GetVolume(volumeName)
+	if err != nil {
+		response.HTTPNotFound(w, err)
+		return
+	}
+
+	response.HTTPOk(w, volume)
+	return
+}
+
+// CreateVolume creates a Docker volume managed by Krane
+func CreateVolume(w http.ResponseWriter, r *http.Request) {
+	type VolumeRequest struct {
+		Name    string            `json:"name" binding:"required"`
+		Driver  string            `json:"driver"`
+		Options map[string]string `json:"options"`
+	}
+
+	var body VolumeRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	volume, err := deployment.CreateVolume(body.Name, body.Driver, body.Options)
+	if err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	response.HTTPOk(w, volume)
+	return
+}
+
+// RemoveVolume removes a Docker volume managed by Krane.
+// Note: volumes referenced by a deployment configuration cannot be removed
+func RemoveVolume(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	volumeName := params["volume"]
+
+	if volumeName == "" {
+		response.HTTPBad(w, errors.New("volume name not provided"))
+		return
+	}
+
+	if err := deployment.RemoveVolume(volumeName); err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	response.HTTPNoContent(w)
+	return
+}
+
+// ArchiveDeploymentVolume streams a tar archive of the contents of a deployment volume.
+// The deployment containers are stopped during the snapshot when the query param stop=true is provided
+func ArchiveDeploymentVolume(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	deploymentName := params["deployment"]
+	volumeName := params["volume"]
+
+	if deploymentName == "" {
+		response.HTTPBad(w, errors.New("deployment name not provided"))
+		return
+	}
+
+	if volumeName == "" {
+		response.HTTPBad(w, errors.New("volume name not provided"))
+		return
+	}
+
+	if !deployment.Exist(deploymentName) {
+		response.HTTPBad(w, fmt.Errorf("deployment %s does not exist", deploymentName))
+		return
+	}
+
+	stop, _ := strconv.ParseBool(utils.QueryParamOrDefault(r, "stop", "false"))
+
+	archive, err := deployment.ArchiveVolume(deploymentName, volumeName, stop)
+	if err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+	defer archive.Close()
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s-%s.tar", deploymentName, volumeName))
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, archive); err != nil {
+		logger.Warnf("unable to stream volume archive, %v", err)
+	}
+	return
+}
+
+// RestoreDeploymentVolume extracts an uploaded tar archive into a deployment volume.
+// The deployment containers are stopped during the restore when the query param stop=true is provided
+func RestoreDeploymentVolume(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	deploymentName := params["deployment"]
+	volumeName := params["volume"]
+
+	if deploymentName == "" {
+		response.HTTPBad(w, errors.New("deployment name not provided"))
+		return
+	}
+
+	if volumeName == "" {
+		response.HTTPBad(w, errors.New("volume name not provided"))
+		return
+	}
+
+	if !deployment.Exist(deploymentName) {
+		response.HTTPBad(w, fmt.Errorf("deployment %s does not exist", deploymentName))
+		return
+	}
+
+	stop, _ := strconv.ParseBool(utils.QueryParamOrDefault(r, "stop", "false"))
+
+	if err := deployment.RestoreVolume(deploymentName, volumeName, r.Body, stop); err != nil {
+		response.HTTPBad(w, err)
+		return
+	}
+
+	response.HTTPNoContent(w)
+	return
+}
@@ -0,0 +1,513 @@
+package compose
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/krane/krane/internal/deployment"
+)
+
+// Result represents the deployment configurations converted from a compose file
+type Result struct {
+	Deployments []deployment.Config `json:"deployments"` // one deployment per compose service
+	Unsupported []string            `json:"unsupported"` // compose keys which were not converted (ie. services.web.build)
+}
+
+// converter accumulates unsupported keys while converting a compose file
+type converter struct {
+	unsupported []string
+	volumes     map[string]map[string]interface{} // top-level named volume declarations
+}
+
+// Convert parses a compose file converting each service into a deployment configuration.
+// Keys Krane does not support are reported in the result instead of being silently dropped.
+func Convert(data []byte) (Result, error) {
+	var file map[string]interface{}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return Result{}, fmt.Errorf("invalid compose file, %v", err)
+	}
+
+	services, ok := file["services"].(map[string]interface{})
+	if !ok || len(services) == 0 {
+		return Result{}, fmt.Errorf("compose file has no services")
+	}
+
+	c := &converter{unsupported: make([]string, 0), volumes: make(map[string]map[string]interface{}, 0)}
+
+	for _, key := range sortedKeys(file) {
+		switch key {
+		case "services", "version", "name":
+		case "volumes":
+			c.parseVolumeDeclarations(file[key])
+		default:
+			c.unsupport(key)
+		}
+	}
+
+	result := Result{Deployments: make([]deployment.Config, 0)}
+	for _, name := range sortedKeys(services) {
+		service, ok := services[name].(map[string]interface{})
+		if !ok {
+			return Result{}, fmt.Errorf("service %s must be a mapping", name)
+		}
+
+		config, err := c.convertService(name, service)
+		if err != nil {
+			return Result{}, err
+		}
+		result.Deployments = append(result.Deployments, config)
+	}
+
+	result.Unsupported = c.unsupported
+	return result, nil
+}
+
+// convertService converts a compose service into a deployment configuration
+func (c *converter) convertService(name string, service map[string]interface{}) (deployment.Config, error) {
+	config := deployment.Config{
+		Name:      name,
+		Scale:     1,
+		Env:       make(map[string]string, 0),
+		Labels:    make(map[string]string, 0),
+		Ports:     make(deployment.PortConfigs, 0),
+		Volumes:   make(deployment.VolumeConfigs, 0),
+		DependsOn: make([]deployment.DependencyConfig, 0),
+	}
+
+	prefix := fmt.Sprintf("services.%s", name)
+	for _, key := range sortedKeys(service) {
+		value := service[key]
+		keyPath := fmt.Sprintf("%s.%s", prefix, key)
+
+		var err error
+		switch key {
+		case "image":
+			config.Registry, config.Image, config.Tag = splitImage(fmt.Sprint(value))
+		case "environment":
+			config.Env = c.parseEnvironment(keyPath, value)
+		case "labels":
+			config.Labels = c.parseLabels(keyPath, value)
+		case "ports":
+			config.Ports = c.parsePorts(keyPath, value)
+		case "volumes":
+			config.Volumes = c.parseVolumes(keyPath, value)
+		case "command":
+			config.Command, err = parseArgs(keyPath, value)
+		case "entrypoint":
+			config.Entrypoint, err = parseArgs(keyPath, value)
+		case "working_dir":
+			config.WorkingDir = fmt.Sprint(value)
+		case "user":
+			config.User = fmt.Sprint(value)
+		case "depends_on":
+			config.DependsOn = c.parseDependsOn(keyPath, value)
+		case "deploy":
+			config.Scale = c.parseDeploy(keyPath, value)
+		default:
+			c.unsupport(keyPath)
+		}
+
+		if err != nil {
+			return deployment.Config{}, err
+		}
+	}
+
+	if config.Image == "" {
+		return deployment.Config{}, fmt.Errorf("service %s has no image, services built from source are not supported", name)
+	}
+
+	return config, nil
+}
+
+// splitImage splits an image reference into a registry, an image and a tag, the tag of
+// a digest reference (image@sha256:...) is the digest
+func splitImage(ref string) (string, string, string) {
+	registry := ""
+	// the first path component is a registry host when it looks like a hostname
+	if i := strings.Index(ref, "/"); i > 0 {
+		host := ref[:i]
+		if strings.ContainsAny(host, ".:") || host == "localhost" {
+			registry, ref = host, ref[i+1:]
+		}
+	}
+
+	if i := strings.Index(ref, "@"); i > 0 {
+		image := ref[:i]
+		// a tag next to the digest is ignored, the digest pins the image
+		if j := strings.LastIndex(image, ":"); j > strings.LastIndex(image, "/") {
+			image = image[:j]
+		}
+		return registry, image, ref[i+1:]
+	}
+
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		return registry, ref[:i], ref[i+1:]
+	}
+
+	return registry, ref, ""
+}
+
+// parseEnvironment parses environment variables declared as a list of KEY=VALUE or as a mapping
+func (c *converter) parseEnvironment(keyPath string, value interface{}) map[string]string {
+	env := make(map[string]string, 0)
+
+	switch v := value.(type) {
+	case []interface{}:
+		for i, entry := range v {
+			parts := strings.SplitN(fmt.Sprint(entry), "=", 2)
+			if len(parts) != 2 {
+				// variables without a value are resolved from the shell running compose
+				c.unsupport(fmt.Sprintf("%s[%d]", keyPath, i))
+				continue
+			}
+			env[parts[0]] = parts[1]
+		}
+	case map[string]interface{}:
+		for _, k := range sortedKeys(v) {
+			if v[k] == nil {
+				c.unsupport(fmt.Sprintf("%s.%s", keyPath, k))
+				continue
+			}
+			env[k] = fmt.Sprint(v[k])
+		}
+	default:
+		c.unsupport(keyPath)
+	}
+
+	return env
+}
+
+// parseLabels parses labels declared as a list of KEY=VALUE or as a mapping
+func (c *converter) parseLabels(keyPath string, value interface{}) map[string]string {
+	labels := make(map[string]string, 0)
+
+	switch v := value.(type) {
+	case []interface{}:
+		for _, entry := range v {
+			parts := strings.SplitN(fmt.Sprint(entry), "=", 2)
+			if len(parts) == 1 {
+				parts = append(parts, "")
+			}
+			labels[parts[0]] = parts[1]
+		}
+	case map[string]interface{}:
+		for k, label := range v {
+			labels[k] = fmt.Sprint(label)
+		}
+	default:
+		c.unsupport(keyPath)
+	}
+
+	return labels
+}
+
+// parsePorts parses ports declared with the short (ie. "8080:80/tcp") or long syntax
+func (c *converter) parsePorts(keyPath string, value interface{}) deployment.PortConfigs {
+	ports := make(deployment.PortConfigs, 0)
+
+	list, ok := value.([]interface{})
+	if !ok {
+		c.unsupport(keyPath)
+		return ports
+	}
+
+	for i, entry := range list {
+		entryPath := fmt.Sprintf("%s[%d]", keyPath, i)
+
+		raw := fmt.Sprint(entry)
+		if long, ok := entry.(map[string]interface{}); ok {
+			raw = c.longPortSyntax(entryPath, long)
+		}
+
+		p, err := deployment.ParsePortConfig(raw)
+		if err != nil {
+			// port ranges (ie. 3000-3005:3000-3005) are not supported
+			c.unsupport(entryPath)
+			continue
+		}
+		ports = append(ports, p)
+	}
+
+	return ports
+}
+
+// longPortSyntax returns the short syntax of a port declared with the long syntax
+func (c *converter) longPortSyntax(keyPath string, port map[string]interface{}) string {
+	raw := fmt.Sprint(port["target"])
+	if published, ok := port["published"]; ok {
+		raw = fmt.Sprintf("%v:%s", published, raw)
+	}
+	if hostIP, ok := port["host_ip"]; ok {
+		raw = fmt.Sprintf("%v:%s", hostIP, raw)
+	}
+	if protocol, ok := port["protocol"]; ok {
+		raw = fmt.Sprintf("%s/%v", raw, protocol)
+	}
+
+	for _, key := range sortedKeys(port) {
+		switch key {
+		case "target", "published", "host_ip", "protocol":
+		default:
+			c.unsupport(fmt.Sprintf("%s.%s", keyPath, key))
+		}
+	}
+
+	return raw
+}
+
+// parseVolumes parses volumes declared with the short (ie. "data:/var/lib/data:ro") or long syntax
+func (c *converter) parseVolumes(keyPath string, value interface{}) deployment.VolumeConfigs {
+	volumes := make(deployment.VolumeConfigs, 0)
+
+	list, ok := value.([]interface{})
+	if !ok {
+		c.unsupport(keyPath)
+		return volumes
+	}
+
+	for i, entry := range list {
+		entryPath := fmt.Sprintf("%s[%d]", keyPath, i)
+
+		var v deployment.VolumeConfig
+		var ok bool
+		switch e := entry.(type) {
+		case string:
+			v, ok = shortVolumeSyntax(e)
+		case map[string]interface{}:
+			v, ok = c.longVolumeSyntax(entryPath, e)
+		}
+
+		if !ok {
+			// anonymous volumes and relative bind mounts have no equivalent
+			c.unsupport(entryPath)
+			continue
+		}
+
+		if v.Type == deployment.NamedVolume {
+			c.applyVolumeDeclaration(&v)
+		}
+		volumes = append(volumes, v)
+	}
+
+	return volumes
+}
+
+// shortVolumeSyntax parses a volume in the format source:target[:mode]
+func shortVolumeSyntax(spec string) (deployment.VolumeConfig, bool) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return deployment.VolumeConfig{}, false
+	}
+
+	v := deployment.VolumeConfig{Source: parts[0], Target: parts[1]}
+	if len(parts) == 3 {
+		for _, mode := range strings.Split(parts[2], ",") {
+			if mode == "ro" {
+				v.ReadOnly = true
+			}
+		}
+	}
+
+	return volumeType(v)
+}
+
+// longVolumeSyntax parses a volume declared as a mapping
+func (c *converter) longVolumeSyntax(keyPath string, volume map[string]interface{}) (deployment.VolumeConfig, bool) {
+	v := deployment.VolumeConfig{}
+	for _, key := range sortedKeys(volume) {
+		switch key {
+		case "type":
+			v.Type = deployment.VolumeType(fmt.Sprint(volume[key]))
+		case "source":
+			v.Source = fmt.Sprint(volume[key])
+		case "target":
+			v.Target = fmt.Sprint(volume[key])
+		case "read_only":
+			v.ReadOnly, _ = strconv.ParseBool(fmt.Sprint(volume[key]))
+		default:
+			c.unsupport(fmt.Sprintf("%s.%s", keyPath, key))
+		}
+	}
+
+	switch v.Type {
+	case "volume":
+		v.Type = deployment.NamedVolume
+	case deployment.BindVolume, deployment.TmpfsVolume:
+	default:
+		return deployment.VolumeConfig{}, false
+	}
+
+	if v.Type == deployment.TmpfsVolume {
+		return v, path.IsAbs(v.Target)
+	}
+
+	if v.Source == "" {
+		return deployment.VolumeConfig{}, false
+	}
+
+	if v.Type == deployment.BindVolume && !path.IsAbs(v.Source) {
+		return deployment.VolumeConfig{}, false
+	}
+
+	return v, true
+}
+
+// volumeType sets the volume type from its source, absolute paths are bind volumes and names are named volumes
+func volumeType(v deployment.VolumeConfig) (deployment.VolumeConfig, bool) {
+	switch {
+	case path.IsAbs(v.Source):
+		v.Type = deployment.BindVolume
+	case strings.HasPrefix(v.Source, ".") || strings.HasPrefix(v.Source, "~"):
+		return deployment.VolumeConfig{}, false
+	default:
+		v.Type = deployment.NamedVolume
+	}
+	return v, true
+}
+
+// parseVolumeDeclarations stores the top-level named volume declarations
+func (c *converter) parseVolumeDeclarations(value interface{}) {
+	declarations, ok := value.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	for _, name := range sortedKeys(declarations) {
+		declaration, _ := declarations[name].(map[string]interface{})
+		if declaration == nil {
+			declaration = make(map[string]interface{}, 0)
+		}
+
+		for _, key := range sortedKeys(declaration) {
+			switch key {
+			case "driver", "driver_opts":
+			default:
+				c.unsupport(fmt.Sprintf("volumes.%s.%s", name, key))
+			}
+		}
+		c.volumes[name] = declaration
+	}
+}
+
+// applyVolumeDeclaration applies the driver and driver options declared for a named volume
+func (c *converter) applyVolumeDeclaration(v *deployment.VolumeConfig) {
+	declaration, ok := c.volumes[v.Source]
+	if !ok {
+		return
+	}
+
+	if driver, ok := declaration["driver"]; ok {
+		v.Driver = fmt.Sprint(driver)
+	}
+
+	if opts, ok := declaration["driver_opts"].(map[string]interface{}); ok {
+		v.Options = make(map[string]string, 0)
+		for k, opt := range opts {
+			v.Options[k] = fmt.Sprint(opt)
+		}
+	}
+}
+
+// parseArgs parses a command or entrypoint declared as a string or a list
+func parseArgs(keyPath string, value interface{}) (deployment.Args, error) {
+	switch v := value.(type) {
+	case string:
+		args, err := deployment.ParseShellArgs(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s, %v", keyPath, err)
+		}
+		return args, nil
+	case []interface{}:
+		args := make(deployment.Args, 0)
+		for _, arg := range v {
+			args = append(args, fmt.Sprint(arg))
+		}
+		return args, nil
+	}
+	return nil, fmt.Errorf("invalid %s, must be a string or a list", keyPath)
+}
+
+// parseDependsOn parses dependencies declared as a list of services or as a mapping of services to conditions
+func (c *converter) parseDependsOn(keyPath string, value interface{}) []deployment.DependencyConfig {
+	dependencies := make([]deployment.DependencyConfig, 0)
+
+	switch v := value.(type) {
+	case []interface{}:
+		for _, service := range v {
+			dependencies = append(dependencies, deployment.DependencyConfig{Deployment: fmt.Sprint(service)})
+		}
+	case map[string]interface{}:
+		for _, service := range sortedKeys(v) {
+			dependency := deployment.DependencyConfig{Deployment: service}
+
+			options, _ := v[service].(map[string]interface{})
+			switch options["condition"] {
+			case nil, "service_started":
+				dependency.Condition = deployment.StartedDependency
+			case "service_healthy":
+				dependency.Condition = deployment.HealthyDependency
+			default:
+				// ie. service_completed_successfully
+				c.unsupport(fmt.Sprintf("%s.%s.condition", keyPath, service))
+				continue
+			}
+
+			for _, key := range sortedKeys(options) {
+				if key != "condition" {
+					c.unsupport(fmt.Sprintf("%s.%s.%s", keyPath, service, key))
+				}
+			}
+
+			dependencies = append(dependencies, dependency)
+		}
+	default:
+		c.unsupport(keyPath)
+	}
+
+	return dependencies
+}
+
+// parseDeploy returns the number of replicas declared in a deploy section (default 1)
+func (c *converter) parseDeploy(keyPath string, value interface{}) int {
+	scale := 1
+
+	deploy, ok := value.(map[string]interface{})
+	if !ok {
+		c.unsupport(keyPath)
+		return scale
+	}
+
+	for _, key := range sortedKeys(deploy) {
+		if key != "replicas" {
+			c.unsupport(fmt.Sprintf("%s.%s", keyPath, key))
+			continue
+		}
+
+		replicas, err := strconv.Atoi(fmt.Sprint(deploy[key]))
+		if err != nil || replicas < 0 {
+			c.unsupport(fmt.Sprintf("%s.%s", keyPath, key))
+			continue
+		}
+		scale = replicas
+	}
+
+	return scale
+}
+
+func (c *converter) unsupport(keyPath string) {
+	c.unsupported = append(c.unsupported, keyPath)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
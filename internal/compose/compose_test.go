@@ -0,0 +1,135 @@
+package compose
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/krane/krane/internal/deployment"
+)
+
+const composeFile = `
+version: "3.8"
+services:
+  api:
+    image: registry.example.com:5000/acme/api:1.2.0
+    build: .
+    command: bundle exec puma -C config/puma.rb
+    environment:
+      - RAILS_ENV=production
+      - SECRET_KEY_BASE
+    ports:
+      - "8080:3000"
+      - target: 9000
+        published: 9000
+        protocol: udp
+    volumes:
+      - uploads:/app/uploads
+      - /etc/ssl/certs:/etc/ssl/certs:ro
+      - ./config:/app/config
+    labels:
+      com.example.team: payments
+    depends_on:
+      redis:
+        condition: service_healthy
+      migrate:
+        condition: service_completed_successfully
+    deploy:
+      replicas: 3
+      resources:
+        limits:
+          cpus: "0.5"
+    restart: always
+  redis:
+    image: redis
+    entrypoint: ["redis-server", "--appendonly", "yes"]
+    environment:
+      REDIS_PORT: 6379
+networks:
+  backend: {}
+volumes:
+  uploads:
+    driver: local
+    driver_opts:
+      type: none
+    external: false
+`
+
+func TestConvert(t *testing.T) {
+	result, err := Convert([]byte(composeFile))
+	assert.Nil(t, err)
+	assert.Len(t, result.Deployments, 2)
+
+	api := result.Deployments[0]
+	assert.Equal(t, "api", api.Name)
+	assert.Equal(t, "registry.example.com:5000", api.Registry)
+	assert.Equal(t, "acme/api", api.Image)
+	assert.Equal(t, "1.2.0", api.Tag)
+	assert.Equal(t, 3, api.Scale)
+	assert.Equal(t, deployment.Args{"bundle", "exec", "puma", "-C", "config/puma.rb"}, api.Command)
+	assert.Equal(t, map[string]string{"RAILS_ENV": "production"}, api.Env)
+	assert.Equal(t, map[string]string{"com.example.team": "payments"}, api.Labels)
+	assert.Equal(t, []string{"8080:3000/tcp", "9000:9000/udp"}, []string{api.Ports[0].String(), api.Ports[1].String()})
+	assert.Equal(t, deployment.VolumeConfigs{
+		{Type: deployment.NamedVolume, Source: "uploads", Target: "/app/uploads", Driver: "local", Options: map[string]string{"type": "none"}},
+		{Type: deployment.BindVolume, Source: "/etc/ssl/certs", Target: "/etc/ssl/certs", ReadOnly: true},
+	}, api.Volumes)
+	assert.Equal(t, []deployment.DependencyConfig{{Deployment: "redis", Condition: deployment.HealthyDependency}}, api.DependsOn)
+
+	redis := result.Deployments[1]
+	assert.Equal(t, "redis", redis.Image)
+	assert.Equal(t, "", redis.Tag)
+	assert.Equal(t, 1, redis.Scale)
+	assert.Equal(t, deployment.Args{"redis-server", "--appendonly", "yes"}, redis.Entrypoint)
+	assert.Equal(t, map[string]string{"REDIS_PORT": "6379"}, redis.Env)
+
+	assert.Equal(t, []string{
+		"networks",
+		"volumes.uploads.external",
+		"services.api.build",
+		"services.api.depends_on.migrate.condition",
+		"services.api.deploy.resources",
+		"services.api.environment[1]",
+		"services.api.restart",
+		"services.api.volumes[2]",
+	}, result.Unsupported)
+}
+
+func TestConvertInvalid(t *testing.T) {
+	_, err := Convert([]byte(`services: {}`))
+	assert.Error(t, err)
+
+	_, err = Convert([]byte(`services: [`))
+	assert.Error(t, err)
+
+	_, err = Convert([]byte("services:\n  api:\n    build: .\n"))
+	assert.EqualError(t, err, "service api has no image, services built from source are not supported")
+}
+
+func TestSplitImage(t *testing.T) {
+	tests := []struct {
+		ref      string
+		registry string
+		image    string
+		tag      string
+	}{
+		{"nginx", "", "nginx", ""},
+		{"nginx:1.19", "", "nginx", "1.19"},
+		{"library/nginx:1.19", "", "library/nginx", "1.19"},
+		{"localhost/nginx", "localhost", "nginx", ""},
+		{"localhost:5000/nginx", "localhost:5000", "nginx", ""},
+		{"localhost:5000/nginx:1.19", "localhost:5000", "nginx", "1.19"},
+		{"ghcr.io/org/app", "ghcr.io", "org/app", ""},
+		{"ghcr.io/org/app:v2", "ghcr.io", "org/app", "v2"},
+		{"nginx@sha256:abc", "", "nginx", "sha256:abc"},
+		{"nginx:1.19@sha256:abc", "", "nginx", "sha256:abc"},
+		{"ghcr.io/org/app@sha256:abc", "ghcr.io", "org/app", "sha256:abc"},
+	}
+
+	for _, tt := range tests {
+		registry, image, tag := splitImage(tt.ref)
+		assert.Equal(t, tt.registry, registry, tt.ref)
+		assert.Equal(t, tt.image, image, tt.ref)
+		assert.Equal(t, tt.tag, tag, tt.ref)
+	}
+}
@@ -1,9 +1,20 @@
 package constants
 
 const (
-	AuthenticationCollectionName = "authentication"
-	DeploymentsCollectionName    = "deployments"
-	JobsCollectionName           = "jobs"
-	SessionsCollectionName       = "sessions"
-	SecretsCollectionName        = "secrets"
+	AuthenticationCollectionName  = "authentication"
+	BatchesCollectionName         = "batches"
+	BlueGreenCollectionName       = "blue_green"
+	CanariesCollectionName        = "canaries"
+	CertificatesCollectionName    = "certificates"
+	CredentialsCollectionName     = "credentials"
+	DeployedConfigsCollectionName = "deployed_configs"
+	DeploymentsCollectionName     = "deployments"
+	JobsCollectionName            = "jobs"
+	KeysCollectionName            = "keys"
+	MaintenanceCollectionName     = "maintenance"
+	PortAllocationsCollectionName = "port_allocations"
+	SchedulesCollectionName       = "schedules"
+	SessionsCollectionName        = "sessions"
+	SecretsCollectionName         = "secrets"
+	TemplatesCollectionName       = "templates"
 )
@@ -16,5 +16,10 @@ const (
 	EnvProxyEnabled            = "PROXY_ENABLED"
 	EnvProxyDashboardSecure    = "PROXY_DASHBOARD_SECURE"
 	EnvProxyDashboardAlias     = "PROXY_DASHBOARD_ALIAS"
+	EnvProxyConfigDir          = "PROXY_CONFIG_DIR"
+	EnvProxyEntryPoints        = "PROXY_ENTRYPOINTS"
+	EnvProxyBackend            = "PROXY_BACKEND"
 	EnvLetsEncryptEmail        = "LETSENCRYPT_EMAIL"
+	EnvHostPortRangeStart      = "HOST_PORT_RANGE_START"
+	EnvHostPortRangeEnd        = "HOST_PORT_RANGE_END"
 )
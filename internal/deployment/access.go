@@ -0,0 +1,196 @@
+package deployment
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/krane/krane/internal/constants"
+	"github.com/krane/krane/internal/logger"
+	"github.com/krane/krane/internal/store"
+)
+
+// BasicAuthConfig represents the users allowed through basic auth in front of a deployment.
+// Passwords are never part of the deployment configuration, each user references the
+// deployment secret holding its password.
+type BasicAuthConfig struct {
+	Users map[string]string `json:"users"` // username to the key of the deployment secret holding the password
+	Realm string            `json:"realm"` // realm presented to clients (default the deployment name)
+}
+
+// credentials are the bcrypt hashes of basic auth passwords for a deployment, keyed by username.
+// Hashes are kept so every container of a deployment carries the same proxy labels.
+type credentials map[string]string
+
+// credentialsMu guards reading and updating stored basic auth hashes
+var credentialsMu = &sync.Mutex{}
+
+// enabled returns true if basic auth is configured
+func (auth BasicAuthConfig) enabled() bool {
+	return len(auth.Users) > 0
+}
+
+// isValid returns an error if a basic auth configuration is not valid
+func (auth BasicAuthConfig) isValid() error {
+	for user, key := range auth.Users {
+		if user == "" || strings.ContainsAny(user, ":, ") {
+			return fmt.Errorf("invalid basic auth user %s", user)
+		}
+
+		if !isValidSecretKey(key) {
+			return fmt.Errorf("invalid secret %s for basic auth user %s", key, user)
+		}
+	}
+
+	if strings.ContainsAny(auth.Realm, `"`) {
+		return fmt.Errorf("invalid basic auth realm %s", auth.Realm)
+	}
+
+	return nil
+}
+
+// secretKeys returns the keys of the secrets holding basic auth passwords
+func (auth BasicAuthConfig) secretKeys() []string {
+	keys := make([]string, 0)
+	for _, key := range auth.Users {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// isValidIPAllowList returns an error if an entry of an IP allow list is not an IP or CIDR range
+func isValidIPAllowList(allowList []string) error {
+	for _, source := range allowList {
+		if net.ParseIP(source) != nil {
+			continue
+		}
+
+		if _, _, err := net.ParseCIDR(source); err != nil {
+			return fmt.Errorf("invalid ip allow list entry %s", source)
+		}
+	}
+	return nil
+}
+
+// validateBasicAuthSecrets returns an error if a secret holding a basic auth password doesn't exist
+func validateBasicAuthSecrets(config Config) error {
+	for _, key := range config.BasicAuth.secretKeys() {
+		if !secretExists(config.Name, key) {
+			return fmt.Errorf("basic auth secret %s does not exist for deployment %s", key, config.Name)
+		}
+	}
+	return nil
+}
+
+// basicAuthUsers returns the basic auth users of a deployment formatted as user:bcrypt-hash.
+// An error is returned if a password secret can't be resolved, the caller must not leave
+// the deployment unprotected.
+func (config Config) basicAuthUsers() ([]string, error) {
+	users := make([]string, 0)
+	if !config.BasicAuth.enabled() {
+		return users, nil
+	}
+
+	if err := validateBasicAuthSecrets(config); err != nil {
+		return nil, err
+	}
+
+	credentialsMu.Lock()
+	defer credentialsMu.Unlock()
+
+	stored, err := getCredentials(config.Name)
+	if err != nil {
+		logger.Errorf("unable to get basic auth credentials %v", err)
+		stored = make(credentials, 0)
+	}
+
+	hashes := make(credentials, 0)
+	for user, key := range config.BasicAuth.Users {
+		secret, err := GetSecret(config.Name, key)
+		if err != nil || secret == nil {
+			return nil, fmt.Errorf("unable to resolve basic auth password for %s with secret %s", config.Name, key)
+		}
+
+		hash, err := hashPassword(stored[user], secret.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		hashes[user] = hash
+		users = append(users, fmt.Sprintf("%s:%s", user, hash))
+	}
+	sort.Strings(users)
+
+	if err := saveCredentials(config.Name, hashes); err != nil {
+		logger.Errorf("unable to save basic auth credentials %v", err)
+	}
+
+	return users, nil
+}
+
+// proxyBasicAuthUsers returns the basic auth users for the proxy. When a password secret can't be
+// resolved no user is returned, the proxy then denies every request since basic auth is configured.
+func (config Config) proxyBasicAuthUsers() []string {
+	users, err := config.basicAuthUsers()
+	if err != nil {
+		logger.Errorf("denying all requests through basic auth %v", err)
+		return make([]string, 0)
+	}
+	return users
+}
+
+// basicAuthRealm returns the realm presented to clients by basic auth
+func (config Config) basicAuthRealm() string {
+	if config.BasicAuth.Realm == "" {
+		return config.Name
+	}
+	return config.BasicAuth.Realm
+}
+
+// hashPassword returns the stored hash if it still matches the password, otherwise a new bcrypt hash
+func hashPassword(stored, password string) (string, error) {
+	if stored != "" && bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) == nil {
+		return stored, nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// getCredentials returns the stored basic auth hashes for a deployment
+func getCredentials(deployment string) (credentials, error) {
+	bytes, err := store.Client().Get(constants.CredentialsCollectionName, deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := make(credentials, 0)
+	if bytes == nil {
+		return stored, nil
+	}
+
+	if err := json.Unmarshal(bytes, &stored); err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// saveCredentials stores the basic auth hashes for a deployment
+func saveCredentials(deployment string, hashes credentials) error {
+	bytes, _ := json.Marshal(hashes)
+	return store.Client().Put(constants.CredentialsCollectionName, deployment, bytes)
+}
+
+// DeleteCredentials removes the stored basic auth hashes for a deployment
+func DeleteCredentials(deployment string) error {
+	return store.Client().Remove(constants.CredentialsCollectionName, deployment)
+}
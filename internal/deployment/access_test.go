@@ -0,0 +1,86 @@
+package deployment
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestInvalidAccessConfig(t *testing.T) {
+	assert.Nil(t, BasicAuthConfig{Users: map[string]string{"admin": "ADMIN_PASSWORD"}}.isValid())
+	assert.Error(t, BasicAuthConfig{Users: map[string]string{"ad:min": "ADMIN_PASSWORD"}}.isValid())
+	assert.Error(t, BasicAuthConfig{Users: map[string]string{"admin": "@"}}.isValid())
+
+	assert.Nil(t, isValidIPAllowList([]string{"10.0.0.0/8", "192.168.1.7", "::1"}))
+	assert.Error(t, isValidIPAllowList([]string{"10.0.0.0/33"}))
+	assert.Error(t, isValidIPAllowList([]string{"localhost"}))
+}
+
+func TestBasicAuthUsers(t *testing.T) {
+	deployment := "basic-auth-test"
+	_, err := AddSecret(deployment, "ADMIN_PASSWORD", "biensupernice")
+	assert.Nil(t, err)
+
+	config := Config{
+		Name:      deployment,
+		BasicAuth: BasicAuthConfig{Users: map[string]string{"admin": "ADMIN_PASSWORD"}},
+	}
+
+	users, err := config.basicAuthUsers()
+	assert.Nil(t, err)
+	assert.Len(t, users, 1)
+	assert.True(t, strings.HasPrefix(users[0], "admin:"))
+
+	hash := strings.TrimPrefix(users[0], "admin:")
+	assert.Nil(t, bcrypt.CompareHashAndPassword([]byte(hash), []byte("biensupernice")))
+
+	// the same hash is used until the password changes
+	same, err := config.basicAuthUsers()
+	assert.Nil(t, err)
+	assert.Equal(t, users, same)
+
+	_, err = AddSecret(deployment, "ADMIN_PASSWORD", "changed")
+	assert.Nil(t, err)
+	changed, err := config.basicAuthUsers()
+	assert.Nil(t, err)
+	assert.NotEqual(t, users, changed)
+
+	// a missing password secret fails instead of leaving the user out
+	config.BasicAuth.Users["guest"] = "MISSING_PASSWORD"
+	_, err = config.basicAuthUsers()
+	assert.Error(t, err)
+	assert.Error(t, validateBasicAuthSecrets(config))
+
+	// the proxy denies every request rather than routing without auth
+	site := config.proxySite(config.Name)
+	assert.True(t, site.Middlewares.BasicAuth)
+	assert.Empty(t, site.Middlewares.BasicAuthUsers)
+
+	assert.Nil(t, DeleteCredentials(deployment))
+	assert.Nil(t, DeleteSecretsCollection(deployment))
+}
+
+func TestSaveConfigWithMissingBasicAuthSecret(t *testing.T) {
+	config := Config{
+		Name:      "basic-auth-missing",
+		Image:     "biensupernice/api",
+		BasicAuth: BasicAuthConfig{Users: map[string]string{"admin": "ADMIN_PASSWORD"}},
+	}
+
+	// password secrets can only be added once the deployment exists
+	_, err := PlanConfig(config.Name, config)
+	assert.Nil(t, err)
+	assert.Nil(t, SaveConfig(config))
+	defer DeleteConfig(config.Name)
+
+	// runs fail and the proxy denies every request until the secret is added
+	assert.EqualError(t, validateBasicAuthSecrets(config), "basic auth secret ADMIN_PASSWORD does not exist for deployment basic-auth-missing")
+	assert.Empty(t, config.proxyBasicAuthUsers())
+}
+
+func TestBasicAuthRealm(t *testing.T) {
+	assert.Equal(t, "api", Config{Name: "api"}.basicAuthRealm())
+	assert.Equal(t, "staging", Config{Name: "api", BasicAuth: BasicAuthConfig{Realm: "staging"}}.basicAuthRealm())
+}
@@ -0,0 +1,429 @@
+package deployment
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution/uuid"
+
+	"github.com/krane/krane/internal/constants"
+	"github.com/krane/krane/internal/job"
+	"github.com/krane/krane/internal/logger"
+	"github.com/krane/krane/internal/store"
+)
+
+// Bundle represents a set of deployment configurations applied together
+type Bundle struct {
+	Deployments []Config            `json:"deployments"` // deployment configurations to create or update
+	Secrets     map[string][]string `json:"secrets"`     // secret keys by deployment which must exist before updating
+	Prune       bool                `json:"prune"`       // delete deployments not part of the bundle
+}
+
+// PlanAction is the change applied to a deployment
+type PlanAction string
+
+const (
+	CreateAction    PlanAction = "create"
+	UpdateAction    PlanAction = "update"
+	DeleteAction    PlanAction = "delete"
+	UnchangedAction PlanAction = "unchanged"
+)
+
+// PlanStep represents the change applied to a single deployment
+type PlanStep struct {
+	Deployment string     `json:"deployment"`
+	Action     PlanAction `json:"action"`
+	Warnings   []string   `json:"warnings,omitempty"` // issues which don't prevent applying the step (ie. secrets to add once created)
+}
+
+// ApplyPlan represents the changes applied to deployments for a bundle, in the order they are applied
+type ApplyPlan struct {
+	Steps []PlanStep `json:"steps"`
+
+	// configs are the bundle configurations with default values applied by deployment name
+	configs map[string]Config
+}
+
+// BatchStatus is the status of an apply batch or one of its steps
+type BatchStatus string
+
+const (
+	BatchPending    BatchStatus = "pending"
+	BatchInProgress BatchStatus = "in_progress"
+	BatchSucceeded  BatchStatus = "succeeded"
+	BatchFailed     BatchStatus = "failed"
+)
+
+// ApplyBatch represents the jobs queued when applying a bundle
+type ApplyBatch struct {
+	ID        string      `json:"id"`
+	Status    BatchStatus `json:"status"`           // aggregated status of the batch steps
+	Steps     []BatchStep `json:"steps"`            // steps in the order they are applied
+	CreatedAt int64       `json:"created_at_epoch"` // epoch in seconds since 1970
+}
+
+// BatchStep represents a plan step and the job applying it
+type BatchStep struct {
+	PlanStep
+	JobID  string      `json:"job_id,omitempty"`
+	Status BatchStatus `json:"status"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// PlanBundle computes the changes required to apply a bundle against the stored deployment configurations.
+// An error is returned if a bundle configuration is not valid or a secret referenced by an updated deployment
+// does not exist, secrets of created deployments can only be added once created and are returned as warnings.
+func PlanBundle(bundle Bundle) (ApplyPlan, error) {
+	stored, err := GetAllDeploymentConfigs()
+	if err != nil {
+		return ApplyPlan{}, err
+	}
+
+	return planBundle(bundle, stored, secretExists)
+}
+
+// planBundle computes the changes required to apply a bundle against deployment configurations
+func planBundle(bundle Bundle, stored []Config, hasSecret func(deployment, key string) bool) (ApplyPlan, error) {
+	plan := ApplyPlan{Steps: make([]PlanStep, 0), configs: make(map[string]Config, 0)}
+
+	configs := make([]Config, 0)
+	for _, config := range bundle.Deployments {
+		config.applyDefaults()
+
+		if err := config.isValid(); err != nil {
+			return ApplyPlan{}, fmt.Errorf("invalid deployment %s, %v", config.Name, err)
+		}
+
+		if _, ok := plan.configs[config.Name]; ok {
+			return ApplyPlan{}, fmt.Errorf("deployment %s is declared more than once", config.Name)
+		}
+
+		plan.configs[config.Name] = config
+		configs = append(configs, config)
+	}
+
+	for deployment := range bundle.Secrets {
+		if _, ok := plan.configs[deployment]; !ok {
+			return ApplyPlan{}, fmt.Errorf("secrets referenced for deployment %s which is not part of the bundle", deployment)
+		}
+	}
+
+	// the deployments once the bundle is applied, used to validate configurations against each other
+	storedByName := make(map[string]Config, 0)
+	final := append([]Config{}, configs...)
+	deletes := make([]PlanStep, 0)
+	for _, config := range stored {
+		storedByName[config.Name] = config
+		if _, ok := plan.configs[config.Name]; ok {
+			continue
+		}
+
+		// internal deployments (ie. krane-proxy) are never pruned
+		if bundle.Prune && !config.Internal {
+			deletes = append(deletes, PlanStep{Deployment: config.Name, Action: DeleteAction})
+			continue
+		}
+		final = append(final, config)
+	}
+
+	missingByName := make(map[string][]string, 0)
+	for _, config := range configs {
+		if err := validateDependencyCycles(config, final); err != nil {
+			return ApplyPlan{}, fmt.Errorf("invalid deployment %s, %v", config.Name, err)
+		}
+
+		if err := validatePortConflicts(config, final); err != nil {
+			return ApplyPlan{}, fmt.Errorf("invalid deployment %s, %v", config.Name, err)
+		}
+
+		if err := validateRouteConflicts(config, final); err != nil {
+			return ApplyPlan{}, fmt.Errorf("invalid deployment %s, %v", config.Name, err)
+		}
+
+		for _, key := range requiredSecrets(config, bundle.Secrets[config.Name]) {
+			if !hasSecret(config.Name, key) {
+				missingByName[config.Name] = append(missingByName[config.Name], key)
+			}
+		}
+	}
+
+	// deployments are applied after the deployments they depend on, deletes are applied last
+	missing := make([]string, 0)
+	for _, config := range SortByDependencies(configs) {
+		step := PlanStep{Deployment: config.Name, Action: UpdateAction}
+		current, ok := storedByName[config.Name]
+		switch {
+		case !ok:
+			step.Action = CreateAction
+		case sameConfig(current, config):
+			step.Action = UnchangedAction
+		}
+
+		// secrets are added to a deployment once it exists, an updated deployment is run with its secrets
+		for _, key := range missingByName[config.Name] {
+			if step.Action == UpdateAction {
+				missing = append(missing, fmt.Sprintf("%s/%s", config.Name, key))
+				continue
+			}
+			step.Warnings = append(step.Warnings, fmt.Sprintf("secret %s does not exist, runs fail until it is added", key))
+		}
+
+		plan.Steps = append(plan.Steps, step)
+	}
+	plan.Steps = append(plan.Steps, deletes...)
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return ApplyPlan{}, fmt.Errorf("missing secrets %s", strings.Join(missing, ", "))
+	}
+
+	return plan, nil
+}
+
+// requiredSecrets returns the sorted secret keys used by a deployment configuration and the keys referenced in a bundle
+func requiredSecrets(config Config, references []string) []string {
+	keys := make(map[string]bool, 0)
+	for key := range config.Secrets {
+		keys[key] = true
+	}
+	for _, key := range config.BasicAuth.secretKeys() {
+		keys[key] = true
+	}
+	for _, key := range references {
+		keys[key] = true
+	}
+
+	required := make([]string, 0)
+	for key := range keys {
+		required = append(required, key)
+	}
+	sort.Strings(required)
+	return required
+}
+
+// sameConfig returns true if two deployment configurations are identical
+func sameConfig(a, b Config) bool {
+	aBytes, _ := a.Serialize()
+	bBytes, _ := b.Serialize()
+	return string(aBytes) == string(bBytes)
+}
+
+func secretExists(deployment, key string) bool {
+	secret, err := GetSecret(deployment, key)
+	return err == nil && secret != nil
+}
+
+// Apply applies a bundle. Configurations are saved and run, and pruned deployments are deleted, as a
+// batch of jobs queued in order in the background. The batch is returned before the jobs are queued.
+func Apply(bundle Bundle) (ApplyBatch, error) {
+	plan, err := PlanBundle(bundle)
+	if err != nil {
+		return ApplyBatch{}, err
+	}
+
+	batch := ApplyBatch{
+		ID:        uuid.Generate().String(),
+		Status:    BatchPending,
+		Steps:     make([]BatchStep, 0),
+		CreatedAt: time.Now().Unix(),
+	}
+
+	for _, step := range plan.Steps {
+		status := BatchPending
+		if step.Action == UnchangedAction {
+			status = BatchSucceeded
+		}
+		batch.Steps = append(batch.Steps, BatchStep{PlanStep: step, Status: status})
+	}
+
+	if err := saveBatch(batch); err != nil {
+		return ApplyBatch{}, err
+	}
+
+	go applyBatch(batch, plan)
+
+	return batch, nil
+}
+
+// applyBatch queues the jobs for each step of a batch, jobs are queued one at a time to preserve their order
+func applyBatch(batch ApplyBatch, plan ApplyPlan) {
+	for i, step := range batch.Steps {
+		j, err := stepJob(step.PlanStep, plan)
+		if err != nil {
+			logger.Warnf("unable to apply step %s, %v", step.Deployment, err)
+			updateBatchStep(batch.ID, i, BatchFailed, err)
+			continue
+		}
+
+		if j == nil {
+			continue
+		}
+
+		trackStepJob(batch.ID, i, j)
+		if err := enqueueAfterDependencies(plan.configs[step.Deployment], *j); err != nil {
+			updateBatchStep(batch.ID, i, BatchFailed, err)
+		}
+	}
+}
+
+// trackStepJob records the job applying a batch step and the outcome of each of its executions on the step
+func trackStepJob(batchID string, i int, j *job.Job) {
+	setBatchStepJob(batchID, i, j.ID)
+
+	setup, run, finally := j.Setup, j.Run, j.Finally
+	if setup != nil {
+		j.Setup = func(args interface{}) error {
+			updateBatchStep(batchID, i, BatchInProgress, nil)
+			if err := setup(args); err != nil {
+				updateBatchStep(batchID, i, BatchFailed, err)
+				return err
+			}
+			return nil
+		}
+	}
+
+	j.Run = func(args interface{}) error {
+		updateBatchStep(batchID, i, BatchInProgress, nil)
+		if err := run(args); err != nil {
+			updateBatchStep(batchID, i, BatchFailed, err)
+			return err
+		}
+
+		if finally == nil {
+			updateBatchStep(batchID, i, BatchSucceeded, nil)
+		}
+		return nil
+	}
+
+	if finally != nil {
+		j.Finally = func(args interface{}) error {
+			if err := finally(args); err != nil {
+				updateBatchStep(batchID, i, BatchFailed, err)
+				return err
+			}
+
+			updateBatchStep(batchID, i, BatchSucceeded, nil)
+			return nil
+		}
+	}
+}
+
+// stepJob saves the configuration for a plan step returning the job applying it, nil is returned for unchanged deployments
+func stepJob(step PlanStep, plan ApplyPlan) (*job.Job, error) {
+	switch step.Action {
+	case CreateAction, UpdateAction:
+		if err := SaveConfig(plan.configs[step.Deployment]); err != nil {
+			return nil, err
+		}
+
+		config, err := GetDeploymentConfig(step.Deployment)
+		if err != nil {
+			return nil, err
+		}
+
+		j := newDeploymentJob(config)
+		return &j, nil
+	case DeleteAction:
+		config, err := GetDeploymentConfig(step.Deployment)
+		if err != nil {
+			return nil, err
+		}
+
+		j := newDeleteJob(config, false)
+		return &j, nil
+	}
+
+	return nil, nil
+}
+
+// GetBatch returns an apply batch with the status of each step recorded by the job applying it
+func GetBatch(id string) (ApplyBatch, error) {
+	bytes, err := store.Client().Get(constants.BatchesCollectionName, id)
+	if err != nil {
+		return ApplyBatch{}, err
+	}
+
+	if bytes == nil {
+		return ApplyBatch{}, fmt.Errorf("apply batch %s not found", id)
+	}
+
+	var batch ApplyBatch
+	if err := store.Deserialize(bytes, &batch); err != nil {
+		return ApplyBatch{}, err
+	}
+
+	batch.Status = aggregateStatus(batch.Steps)
+	return batch, nil
+}
+
+// batchesMu serializes updates of batch steps by the jobs applying them
+var batchesMu sync.Mutex
+
+// setBatchStepJob records the id of the job applying a batch step
+func setBatchStepJob(id string, i int, jobID string) {
+	modifyBatchStep(id, i, func(step *BatchStep) {
+		step.JobID = jobID
+	})
+}
+
+// updateBatchStep records the status of a batch step along with the error it failed with (if any)
+func updateBatchStep(id string, i int, status BatchStatus, err error) {
+	modifyBatchStep(id, i, func(step *BatchStep) {
+		step.Status = status
+		step.Error = ""
+		if err != nil {
+			step.Error = err.Error()
+		}
+	})
+}
+
+// modifyBatchStep updates a step of a stored batch
+func modifyBatchStep(id string, i int, modify func(step *BatchStep)) {
+	batchesMu.Lock()
+	defer batchesMu.Unlock()
+
+	bytes, err := store.Client().Get(constants.BatchesCollectionName, id)
+	if err != nil || bytes == nil {
+		logger.Warnf("unable to get apply batch %s", id)
+		return
+	}
+
+	var batch ApplyBatch
+	if err := store.Deserialize(bytes, &batch); err != nil {
+		logger.Errorf("unable to read apply batch %v", err)
+		return
+	}
+
+	if i < 0 || i >= len(batch.Steps) {
+		return
+	}
+	modify(&batch.Steps[i])
+
+	if err := saveBatch(batch); err != nil {
+		logger.Errorf("unable to save apply batch %v", err)
+	}
+}
+
+// aggregateStatus returns the status of a batch from the status of its steps. A batch is in progress until
+// every step completed and failed if any step failed.
+func aggregateStatus(steps []BatchStep) BatchStatus {
+	status := BatchSucceeded
+	for _, step := range steps {
+		switch step.Status {
+		case BatchPending, BatchInProgress:
+			return BatchInProgress
+		case BatchFailed:
+			status = BatchFailed
+		}
+	}
+	return status
+}
+
+func saveBatch(batch ApplyBatch) error {
+	bytes, _ := json.Marshal(batch)
+	return store.Client().Put(constants.BatchesCollectionName, batch.ID, bytes)
+}
@@ -0,0 +1,171 @@
+package deployment
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/krane/krane/internal/job"
+)
+
+func storedConfig(config Config) Config {
+	config.applyDefaults()
+	return config
+}
+
+func noSecrets(_, _ string) bool { return false }
+
+func TestPlanBundle(t *testing.T) {
+	stored := []Config{
+		storedConfig(Config{Name: "redis", Image: "redis"}),
+		storedConfig(Config{Name: "api", Image: "biensupernice/api", Tag: "1.0.0"}),
+		storedConfig(Config{Name: "legacy", Image: "biensupernice/legacy"}),
+		storedConfig(Config{Name: "krane-proxy", Image: "traefik", Internal: true}),
+	}
+
+	bundle := Bundle{
+		Deployments: []Config{
+			{Name: "web", Image: "biensupernice/web", DependsOn: []DependencyConfig{{Deployment: "api"}}},
+			{Name: "api", Image: "biensupernice/api", Tag: "1.1.0", DependsOn: []DependencyConfig{{Deployment: "redis"}}},
+			{Name: "redis", Image: "redis"},
+		},
+	}
+
+	plan, err := planBundle(bundle, stored, noSecrets)
+	assert.Nil(t, err)
+	assert.Equal(t, []PlanStep{
+		{Deployment: "redis", Action: UnchangedAction},
+		{Deployment: "api", Action: UpdateAction},
+		{Deployment: "web", Action: CreateAction},
+	}, plan.Steps)
+
+	// internal deployments are never pruned
+	bundle.Prune = true
+	plan, err = planBundle(bundle, stored, noSecrets)
+	assert.Nil(t, err)
+	assert.Equal(t, PlanStep{Deployment: "legacy", Action: DeleteAction}, plan.Steps[len(plan.Steps)-1])
+	assert.Len(t, plan.Steps, 4)
+}
+
+func TestPlanBundleSecrets(t *testing.T) {
+	bundle := Bundle{
+		Deployments: []Config{{Name: "api", Image: "biensupernice/api", Secrets: map[string]string{"DB_PASSWORD": "@DB_PASSWORD"}}},
+		Secrets:     map[string][]string{"api": {"API_TOKEN"}},
+	}
+
+	// secrets are added once a deployment is created
+	plan, err := planBundle(bundle, []Config{}, noSecrets)
+	assert.Nil(t, err)
+	assert.Equal(t, []PlanStep{{
+		Deployment: "api",
+		Action:     CreateAction,
+		Warnings: []string{
+			"secret API_TOKEN does not exist, runs fail until it is added",
+			"secret DB_PASSWORD does not exist, runs fail until it is added",
+		},
+	}}, plan.Steps)
+
+	// updated deployments are rejected
+	stored := []Config{storedConfig(Config{Name: "api", Image: "biensupernice/api"})}
+	_, err = planBundle(bundle, stored, noSecrets)
+	assert.EqualError(t, err, "missing secrets api/API_TOKEN, api/DB_PASSWORD")
+
+	plan, err = planBundle(bundle, stored, func(_, _ string) bool { return true })
+	assert.Nil(t, err)
+	assert.Equal(t, []PlanStep{{Deployment: "api", Action: UpdateAction}}, plan.Steps)
+
+	bundle.Secrets = map[string][]string{"web": {"API_TOKEN"}}
+	_, err = planBundle(bundle, []Config{}, func(_, _ string) bool { return true })
+	assert.Error(t, err)
+}
+
+func TestPlanBundleInvalid(t *testing.T) {
+	stored := []Config{storedConfig(Config{Name: "redis", Image: "redis", Ports: PortConfigs{{HostPort: "6379", ContainerPort: "6379"}}})}
+
+	// duplicate deployment
+	_, err := planBundle(Bundle{Deployments: []Config{{Name: "api", Image: "api"}, {Name: "api", Image: "api"}}}, stored, noSecrets)
+	assert.Error(t, err)
+
+	// invalid deployment
+	_, err = planBundle(Bundle{Deployments: []Config{{Name: "api"}}}, stored, noSecrets)
+	assert.Error(t, err)
+
+	// dependency cycle within the bundle
+	_, err = planBundle(Bundle{Deployments: []Config{
+		{Name: "api", Image: "api", DependsOn: []DependencyConfig{{Deployment: "worker"}}},
+		{Name: "worker", Image: "worker", DependsOn: []DependencyConfig{{Deployment: "api"}}},
+	}}, stored, noSecrets)
+	assert.Error(t, err)
+
+	// host port claimed by a stored deployment
+	_, err = planBundle(Bundle{Deployments: []Config{{Name: "cache", Image: "redis", Ports: PortConfigs{{HostPort: "6379", ContainerPort: "6379"}}}}}, stored, noSecrets)
+	assert.Error(t, err)
+
+	// unless the deployment claiming it is pruned
+	_, err = planBundle(Bundle{Prune: true, Deployments: []Config{{Name: "cache", Image: "redis", Ports: PortConfigs{{HostPort: "6379", ContainerPort: "6379"}}}}}, stored, noSecrets)
+	assert.Nil(t, err)
+}
+
+func TestBatchStatus(t *testing.T) {
+	assert.Equal(t, BatchSucceeded, aggregateStatus([]BatchStep{{Status: BatchSucceeded}, {Status: BatchSucceeded}}))
+	assert.Equal(t, BatchInProgress, aggregateStatus([]BatchStep{{Status: BatchFailed}, {Status: BatchPending}}))
+	assert.Equal(t, BatchFailed, aggregateStatus([]BatchStep{{Status: BatchFailed}, {Status: BatchSucceeded}}))
+}
+
+func TestTrackStepJob(t *testing.T) {
+	batch := ApplyBatch{
+		ID: "tracked-batch",
+		Steps: []BatchStep{
+			{PlanStep: PlanStep{Deployment: "api", Action: UpdateAction}, Status: BatchPending},
+			{PlanStep: PlanStep{Deployment: "legacy", Action: DeleteAction}, Status: BatchPending},
+		},
+	}
+	assert.Nil(t, saveBatch(batch))
+
+	attempts := 0
+	update := &job.Job{
+		ID: "update-job",
+		Run: func(args interface{}) error {
+			attempts++
+			if attempts == 1 {
+				return errors.New("unable to pull image")
+			}
+			return nil
+		},
+	}
+	trackStepJob(batch.ID, 0, update)
+
+	// failed deletes are reported as failed, not in progress until the deployment is gone
+	remove := &job.Job{
+		ID:      "delete-job",
+		Run:     func(args interface{}) error { return nil },
+		Finally: func(args interface{}) error { return errors.New("unable to remove secrets collection") },
+	}
+	trackStepJob(batch.ID, 1, remove)
+
+	stored, err := GetBatch(batch.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "update-job", stored.Steps[0].JobID)
+	assert.Equal(t, BatchPending, stored.Steps[0].Status)
+
+	assert.Error(t, update.Run(nil))
+	stored, _ = GetBatch(batch.ID)
+	assert.Equal(t, BatchFailed, stored.Steps[0].Status)
+	assert.Equal(t, "unable to pull image", stored.Steps[0].Error)
+
+	// a retry succeeding clears the error of the previous execution
+	assert.Nil(t, update.Run(nil))
+	stored, _ = GetBatch(batch.ID)
+	assert.Equal(t, BatchSucceeded, stored.Steps[0].Status)
+	assert.Equal(t, "", stored.Steps[0].Error)
+
+	assert.Nil(t, remove.Run(nil))
+	stored, _ = GetBatch(batch.ID)
+	assert.Equal(t, BatchInProgress, stored.Steps[1].Status)
+
+	assert.Error(t, remove.Finally(nil))
+	stored, _ = GetBatch(batch.ID)
+	assert.Equal(t, BatchFailed, stored.Steps[1].Status)
+	assert.Equal(t, BatchFailed, stored.Status)
+}
@@ -0,0 +1,156 @@
+package deployment
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/krane/krane/internal/docker"
+	"github.com/krane/krane/internal/logger"
+)
+
+// volumeArchive is a tar archive of a volumes contents. Closing the archive
+// removes the helper container and restarts any containers stopped for the snapshot.
+type volumeArchive struct {
+	io.ReadCloser
+	cleanup func()
+}
+
+// Close closes the archive stream and cleans up resources used to create it
+func (a volumeArchive) Close() error {
+	err := a.ReadCloser.Close()
+	a.cleanup()
+	return err
+}
+
+// ArchiveVolume returns a tar archive of the contents of a deployment volume. When stop is true, the
+// deployment containers are stopped while the archive is read and started again once the archive is closed.
+func ArchiveVolume(deployment, volume string, stop bool) (io.ReadCloser, error) {
+	ctx := context.Background()
+
+	v, err := getDeploymentVolume(deployment, volume)
+	if err != nil {
+		return nil, err
+	}
+
+	stopped := make([]KraneContainer, 0)
+	if stop {
+		stopped, err = stopRunningContainers(deployment)
+		if err != nil {
+			startContainers(stopped)
+			return nil, err
+		}
+	}
+
+	helperID, err := docker.GetClient().CreateVolumeHelperContainer(ctx, v.toDockerMount())
+	if err != nil {
+		startContainers(stopped)
+		return nil, fmt.Errorf("unable to create volume helper container, %v", err)
+	}
+
+	cleanup := func() {
+		if err := docker.GetClient().RemoveContainer(ctx, helperID, true); err != nil {
+			logger.Warnf("unable to remove volume helper container %v", err)
+		}
+		startContainers(stopped)
+	}
+
+	reader, err := docker.GetClient().ArchivePath(ctx, helperID, docker.VolumeHelperMountPath)
+	if err != nil {
+		cleanup()
+		return nil, fmt.Errorf("unable to archive volume %s, %v", volume, err)
+	}
+
+	return volumeArchive{ReadCloser: reader, cleanup: cleanup}, nil
+}
+
+// RestoreVolume extracts a tar archive into a deployment volume. When stop is true, the deployment
+// containers are stopped while the archive is extracted and started again once the restore completes.
+func RestoreVolume(deployment, volume string, archive io.Reader, stop bool) error {
+	ctx := context.Background()
+	defer ctx.Done()
+
+	v, err := getDeploymentVolume(deployment, volume)
+	if err != nil {
+		return err
+	}
+
+	if v.ReadOnly {
+		return fmt.Errorf("volume %s is mounted as read-only", volume)
+	}
+
+	if stop {
+		stopped, err := stopRunningContainers(deployment)
+		defer startContainers(stopped)
+		if err != nil {
+			return err
+		}
+	}
+
+	helperID, err := docker.GetClient().CreateVolumeHelperContainer(ctx, v.toDockerMount())
+	if err != nil {
+		return fmt.Errorf("unable to create volume helper container, %v", err)
+	}
+	defer func() {
+		if err := docker.GetClient().RemoveContainer(ctx, helperID, true); err != nil {
+			logger.Warnf("unable to remove volume helper container %v", err)
+		}
+	}()
+
+	if err := docker.GetClient().ExtractToPath(ctx, helperID, docker.VolumeHelperMountPath, archive); err != nil {
+		return fmt.Errorf("unable to restore volume %s, %v", volume, err)
+	}
+
+	logger.Debugf("Restored volume %s for deployment %s", volume, deployment)
+	return nil
+}
+
+// getDeploymentVolume returns a named volume declared in a deployment configuration
+func getDeploymentVolume(deployment, volume string) (VolumeConfig, error) {
+	config, err := GetDeploymentConfig(deployment)
+	if err != nil {
+		return VolumeConfig{}, err
+	}
+
+	for _, v := range config.NamedVolumes() {
+		if v.Source == volume {
+			return v, nil
+		}
+	}
+
+	return VolumeConfig{}, fmt.Errorf("deployment %s does not have a named volume %s", deployment, volume)
+}
+
+// stopRunningContainers stops the running containers for a deployment returning the containers it stopped
+func stopRunningContainers(deployment string) ([]KraneContainer, error) {
+	stopped := make([]KraneContainer, 0)
+
+	containers, err := GetContainersByDeployment(deployment)
+	if err != nil {
+		return stopped, err
+	}
+
+	for _, c := range containers {
+		if !c.State.Running {
+			continue
+		}
+
+		logger.Debugf("Stopping container %s", c.Name)
+		if err := c.Stop(); err != nil {
+			return stopped, fmt.Errorf("unable to stop container %s, %v", c.Name, err)
+		}
+		stopped = append(stopped, c)
+	}
+
+	return stopped, nil
+}
+
+// startContainers starts a list of containers logging (but not returning) any errors
+func startContainers(containers []KraneContainer) {
+	for _, c := range containers {
+		logger.Debugf("Starting container %s", c.Name)
+		if err := c.Start(); err != nil {
+			logger.Warnf("unable to start container %s, %v", c.Name, err)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package deployment
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/krane/krane/internal/docker"
+)
+
+// fakeDocker is a Docker API serving the containers of a deployment and recording the requests made
+// to it, used to test the volume archive and restore path without a Docker host
+type fakeDocker struct {
+	mu         sync.Mutex
+	containers []types.ContainerJSON
+	requests   []string
+	mounts     []string
+	archive    []byte
+	extracted  []byte
+}
+
+var dockerPath = regexp.MustCompile(`^/v[0-9.]+`)
+
+func (f *fakeDocker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := dockerPath.ReplaceAllString(r.URL.Path, "")
+	if r.URL.Query().Get("path") != "" {
+		path += "?path=" + r.URL.Query().Get("path")
+	}
+	f.requests = append(f.requests, r.Method+" "+path)
+
+	switch {
+	case r.Method == http.MethodGet && path == "/containers/json":
+		list := make([]types.Container, 0)
+		for _, c := range f.containers {
+			list = append(list, types.Container{ID: c.ID})
+		}
+		_ = json.NewEncoder(w).Encode(list)
+	case r.Method == http.MethodGet && strings.HasSuffix(path, "/json"):
+		id := strings.TrimSuffix(strings.TrimPrefix(path, "/containers/"), "/json")
+		for _, c := range f.containers {
+			if c.ID == id {
+				_ = json.NewEncoder(w).Encode(c)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	case strings.HasSuffix(path, "/stop") || strings.HasSuffix(path, "/start"):
+		running := strings.HasSuffix(path, "/start")
+		id := strings.TrimPrefix(strings.TrimSuffix(strings.TrimSuffix(path, "/stop"), "/start"), "/containers/")
+		for _, c := range f.containers {
+			if c.ID == id {
+				c.State.Running = running
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case path == "/images/create":
+		_, _ = w.Write([]byte(`{"status":"pulled"}`))
+	case path == "/containers/create":
+		var body struct {
+			HostConfig container.HostConfig
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		for _, m := range body.HostConfig.Mounts {
+			f.mounts = append(f.mounts, m.Source+":"+m.Target)
+		}
+		_, _ = w.Write([]byte(`{"Id":"helper"}`))
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/containers/helper/archive"):
+		stat, _ := json.Marshal(types.ContainerPathStat{Name: "volume"})
+		w.Header().Set("X-Docker-Container-Path-Stat", base64.StdEncoding.EncodeToString(stat))
+		_, _ = w.Write(f.archive)
+	case r.Method == http.MethodPut && strings.HasPrefix(path, "/containers/helper/archive"):
+		f.extracted, _ = ioutil.ReadAll(r.Body)
+	case r.Method == http.MethodDelete:
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// calls returns the requests made to the fake Docker API matching a prefix
+func (f *fakeDocker) calls(prefix string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	calls := make([]string, 0)
+	for _, r := range f.requests {
+		if strings.HasPrefix(r, prefix) {
+			calls = append(calls, r)
+		}
+	}
+	return calls
+}
+
+// reset clears the requests recorded by the fake Docker API
+func (f *fakeDocker) reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = make([]string, 0)
+	f.mounts = make([]string, 0)
+}
+
+// connectFakeDocker points the docker client to a fake Docker API for the duration of a test
+func connectFakeDocker(t *testing.T, fake *fakeDocker) {
+	server := httptest.NewServer(fake)
+	_ = os.Setenv("DOCKER_HOST", strings.Replace(server.URL, "http://", "tcp://", 1))
+	docker.ClientFromEnv()
+
+	t.Cleanup(func() {
+		server.Close()
+		_ = os.Unsetenv("DOCKER_HOST")
+		docker.ClientFromEnv()
+	})
+}
+
+// fakeContainer returns a container of a deployment as returned by the Docker API
+func fakeContainer(id, deployment string, running bool) types.ContainerJSON {
+	return types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			ID:         id,
+			Name:       "/" + id,
+			State:      &types.ContainerState{Running: running},
+			HostConfig: &container.HostConfig{},
+		},
+		Config:          &container.Config{Hostname: id, Labels: map[string]string{docker.ContainerDeploymentLabel: deployment}},
+		NetworkSettings: &types.NetworkSettings{},
+	}
+}
+
+// tarArchive returns a tar archive of files
+func tarArchive(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	writer := tar.NewWriter(&buf)
+	for name, contents := range files {
+		assert.Nil(t, writer.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(contents))}))
+		_, err := writer.Write([]byte(contents))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, writer.Close())
+	return buf.Bytes()
+}
+
+func TestArchiveVolume(t *testing.T) {
+	config := Config{
+		Name:    "archive-test",
+		Image:   "biensupernice/api",
+		Volumes: VolumeConfigs{{Type: NamedVolume, Source: "archive-data", Target: "/app/data"}},
+	}
+	assert.Nil(t, SaveConfig(config))
+	defer DeleteConfig(config.Name)
+
+	fake := &fakeDocker{
+		containers: []types.ContainerJSON{
+			fakeContainer("archive-test-1", "archive-test", true),
+			fakeContainer("archive-test-2", "archive-test", false),
+			fakeContainer("other-1", "other", true),
+		},
+		archive: tarArchive(t, map[string]string{"config.json": `{"key": "value"}`}),
+	}
+	connectFakeDocker(t, fake)
+
+	_, err := ArchiveVolume(config.Name, "missing", false)
+	assert.EqualError(t, err, "deployment archive-test does not have a named volume missing")
+
+	// the volume is mounted into the helper container instead of the deployment target
+	fake.reset()
+	archive, err := ArchiveVolume(config.Name, "archive-data", true)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"archive-data:" + docker.VolumeHelperMountPath}, fake.mounts)
+	assert.Equal(t, []string{"GET /containers/helper/archive?path=/volume/."}, fake.calls("GET /containers/helper"))
+
+	// only the running containers of the deployment are stopped while the archive is read
+	assert.Equal(t, []string{"POST /containers/archive-test-1/stop"}, fake.calls("POST /containers/archive-test"))
+	assert.Empty(t, fake.calls("POST /containers/other-1"))
+
+	contents, err := ioutil.ReadAll(archive)
+	assert.Nil(t, err)
+	assert.Equal(t, fake.archive, contents)
+
+	// closing the archive removes the helper container and starts the stopped containers again
+	assert.Nil(t, archive.Close())
+	assert.Equal(t, []string{"DELETE /containers/helper"}, fake.calls("DELETE"))
+	assert.Equal(t, []string{"POST /containers/archive-test-1/stop", "POST /containers/archive-test-1/start"}, fake.calls("POST /containers/archive-test"))
+
+	// without stop the containers keep running
+	fake.reset()
+	archive, err = ArchiveVolume(config.Name, "archive-data", false)
+	assert.Nil(t, err)
+	assert.Nil(t, archive.Close())
+	assert.Empty(t, fake.calls("POST /containers/archive-test"))
+	assert.Equal(t, []string{"DELETE /containers/helper"}, fake.calls("DELETE"))
+}
+
+func TestRestoreVolume(t *testing.T) {
+	config := Config{
+		Name:  "restore-test",
+		Image: "biensupernice/api",
+		Volumes: VolumeConfigs{
+			{Type: NamedVolume, Source: "restore-data", Target: "/app/data"},
+			{Type: NamedVolume, Source: "restore-static", Target: "/app/static", ReadOnly: true},
+		},
+	}
+	assert.Nil(t, SaveConfig(config))
+	defer DeleteConfig(config.Name)
+
+	fake := &fakeDocker{containers: []types.ContainerJSON{fakeContainer("restore-test-1", "restore-test", true)}}
+	connectFakeDocker(t, fake)
+
+	archive := tarArchive(t, map[string]string{"notes.txt": "hello world"})
+
+	// read-only volumes are never written to
+	err := RestoreVolume(config.Name, "restore-static", bytes.NewReader(archive), true)
+	assert.EqualError(t, err, "volume restore-static is mounted as read-only")
+	assert.Empty(t, fake.calls(""))
+
+	// the archive is extracted at the root of the volume, with the containers stopped until the restore completes
+	assert.Nil(t, RestoreVolume(config.Name, "restore-data", bytes.NewReader(archive), true))
+	assert.Equal(t, []string{"restore-data:" + docker.VolumeHelperMountPath}, fake.mounts)
+	assert.Equal(t, []string{"PUT /containers/helper/archive?path=/volume"}, fake.calls("PUT"))
+	assert.Equal(t, archive, fake.extracted)
+	assert.Equal(t, []string{"POST /containers/restore-test-1/stop", "POST /containers/restore-test-1/start"}, fake.calls("POST /containers/restore-test"))
+	assert.Equal(t, []string{"DELETE /containers/helper"}, fake.calls("DELETE"))
+}
@@ -0,0 +1,559 @@
+package deployment
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution/uuid"
+	"github.com/docker/go-connections/nat"
+	"github.com/lithammer/shortuuid/v3"
+
+	"github.com/krane/krane/internal/constants"
+	"github.com/krane/krane/internal/docker"
+	"github.com/krane/krane/internal/job"
+	"github.com/krane/krane/internal/logger"
+	"github.com/krane/krane/internal/proxy"
+	"github.com/krane/krane/internal/store"
+	"github.com/krane/krane/internal/utils"
+)
+
+// Strategy is how the containers of a deployment are replaced when it runs
+type Strategy string
+
+const (
+	// ReplaceStrategy replaces the current containers with new containers
+	ReplaceStrategy Strategy = "replace"
+
+	// BlueGreenStrategy brings up the new containers next to the current containers and
+	// switches traffic over once they are healthy, the previous containers are kept for rollbacks
+	BlueGreenStrategy Strategy = "blue_green"
+)
+
+// Color identifies one of the two sets of containers of a blue/green deployment
+type Color string
+
+const (
+	Blue  Color = "blue"
+	Green Color = "green"
+)
+
+// defaultKeepPrevious is how long (in seconds) the previous color is kept running by default
+const defaultKeepPrevious = 3600
+
+// BlueGreenConfig represents how long the previous version of a blue/green deployment is kept around
+type BlueGreenConfig struct {
+	KeepPrevious int64 `json:"keep_previous"` // seconds the previous color is kept running for rollbacks (default 1 hour)
+}
+
+// BlueGreen represents the colors of a blue/green deployment
+type BlueGreen struct {
+	Deployment        string  `json:"deployment"`
+	Active            Color   `json:"active"`              // color receiving traffic
+	ActiveConfig      Config  `json:"active_config"`       // configuration the active color was created from
+	Previous          Color   `json:"previous"`            // color kept running for rollbacks, empty once removed
+	PreviousConfig    *Config `json:"previous_config"`     // configuration the previous color was created from
+	SwitchedAt        int64   `json:"switched_at"`         // when traffic was last switched over
+	PreviousExpiresAt int64   `json:"previous_expires_at"` // when the previous color is removed
+}
+
+// applyDefaults applies default blue/green configuration values
+func (bg *BlueGreenConfig) applyDefaults() {
+	if bg.KeepPrevious == 0 {
+		bg.KeepPrevious = defaultKeepPrevious
+	}
+}
+
+// isValidStrategy returns an error if the strategy of a deployment is not valid
+func (config Config) isValidStrategy() error {
+	switch config.Strategy {
+	case "", ReplaceStrategy:
+		return nil
+	case BlueGreenStrategy:
+	default:
+		return fmt.Errorf("invalid strategy %s", config.Strategy)
+	}
+
+	if config.Canary != nil {
+		return errors.New("blue_green strategy can't be combined with a canary")
+	}
+
+	// tcp and udp routers can't be switched between colors
+	if len(config.TCP) > 0 || len(config.UDP) > 0 {
+		return errors.New("blue_green strategy can't be combined with tcp or udp entrypoints")
+	}
+
+	if config.stableServiceName() == "" {
+		return errors.New("blue_green strategy requires a target port or container ports to route traffic to")
+	}
+
+	// both colors run at the same time, they can't bind the same host port
+	for _, p := range config.Ports {
+		if p.HostPort != "" {
+			return fmt.Errorf("blue_green strategy can't bind host port %s", p.HostPort)
+		}
+	}
+
+	if config.BlueGreen.KeepPrevious < 0 {
+		return fmt.Errorf("invalid blue_green keep previous %d", config.BlueGreen.KeepPrevious)
+	}
+
+	return nil
+}
+
+// next returns the color the next version of a deployment is created as
+func (color Color) next() Color {
+	if color == Blue {
+		return Green
+	}
+	return Blue
+}
+
+// colorName returns the name used for the resources of a color of a deployment
+func colorName(deployment string, color Color) string {
+	return fmt.Sprintf("%s-%s", deployment, color)
+}
+
+// blueGreenName returns the name of the proxy configuration switching traffic between colors
+func blueGreenName(deployment string) string {
+	return fmt.Sprintf("%s-blue-green", deployment)
+}
+
+// colorServiceName returns the proxy service of a color of a deployment
+func (config Config) colorServiceName(color Color) string {
+	return proxy.TraefikServiceName(colorName(config.Name, color), config.httpContainerPorts(), config.TargetPort)
+}
+
+// colorProxyLabels returns the proxy labels of a color, named after the color so both colors can run at the same time
+func (config Config) colorProxyLabels(color Color) map[string]string {
+	colored := config
+	colored.Labels = make(map[string]string, 0)
+	colored.applyProxyLabels(colorName(config.Name, color))
+	return colored.Labels
+}
+
+// colorLabels returns the labels of the containers of a color. Colors only declare their proxy services
+// and middlewares, public traffic reaches the active color through the proxy configuration written when
+// switching over. A color is not reachable before it receives traffic.
+func (config Config) colorLabels(color Color) map[string]string {
+	labels := make(map[string]string, 0)
+	for k, v := range config.Labels {
+		if !strings.HasPrefix(k, "traefik.") {
+			labels[k] = v
+		}
+	}
+
+	for k, v := range config.colorProxyLabels(color) {
+		if !strings.HasPrefix(k, "traefik.http.routers.") {
+			labels[k] = v
+		}
+	}
+
+	labels[docker.ContainerDeploymentLabel] = config.Name
+	labels[docker.ContainerColorLabel] = string(color)
+	return labels
+}
+
+// colorDockerConfig returns the docker configuration for creating a container of a color.
+// Colors don't bind host ports since both colors run at the same time.
+func (config Config) colorDockerConfig(color Color) docker.DockerConfig {
+	kraneNetwork, err := docker.GetClient().GetNetworkByName(docker.KraneNetworkName)
+	if err != nil {
+		return docker.DockerConfig{}
+	}
+
+	return docker.DockerConfig{
+		ContainerName: fmt.Sprintf("%s-%s", colorName(config.Name, color), shortuuid.New()),
+		Image:         docker.ImageRef(config.Registry, config.Image, config.Tag),
+		NetworkID:     kraneNetwork.ID,
+		Aliases:       config.Alias,
+		Labels:        config.colorLabels(color),
+		Ports:         nat.PortMap{},
+		PortSet:       config.DockerPortSet(),
+		VolumeMounts:  config.DockerVolumeMount(),
+		VolumeSet:     config.DockerVolumeSet(),
+		Env:           config.DockerEnvs(),
+		Command:       config.Command,
+		Entrypoint:    config.Entrypoint,
+		WorkingDir:    config.WorkingDir,
+		User:          config.User,
+	}
+}
+
+// switchTraffic writes the proxy configuration routing the traffic of a deployment to a color
+func switchTraffic(config Config, color Color) error {
+	dynamicConfig := proxy.TraefikSwitchConfig(config.colorProxyLabels(color), config.colorServiceName(color)+"@docker")
+	return proxy.WriteDynamicConfig(blueGreenName(config.Name), dynamicConfig)
+}
+
+// GetBlueGreen returns the colors of a blue/green deployment
+func GetBlueGreen(deployment string) (BlueGreen, error) {
+	bytes, err := store.Client().Get(constants.BlueGreenCollectionName, deployment)
+	if err != nil {
+		return BlueGreen{}, err
+	}
+
+	if bytes == nil {
+		return BlueGreen{}, fmt.Errorf("deployment %s has no active color", deployment)
+	}
+
+	var bg BlueGreen
+	if err := json.Unmarshal(bytes, &bg); err != nil {
+		return BlueGreen{}, err
+	}
+	return bg, nil
+}
+
+// saveBlueGreen stores the colors of a blue/green deployment
+func saveBlueGreen(bg BlueGreen) error {
+	bytes, _ := json.Marshal(bg)
+	return store.Client().Put(constants.BlueGreenCollectionName, bg.Deployment, bytes)
+}
+
+// clearBlueGreen removes the colors of a deployment and its proxy configuration
+func clearBlueGreen(deployment string) error {
+	if err := proxy.RemoveDynamicConfig(blueGreenName(deployment)); err != nil {
+		return err
+	}
+	return store.Client().Remove(constants.BlueGreenCollectionName, deployment)
+}
+
+// activeColor returns the color receiving the traffic of a deployment, empty if the deployment has no colors
+func activeColor(deployment string) Color {
+	bg, err := GetBlueGreen(deployment)
+	if err != nil {
+		return ""
+	}
+	return bg.Active
+}
+
+// containersOfColor returns the containers of a color from a list of containers
+func containersOfColor(containers []KraneContainer, color Color) []KraneContainer {
+	colored := make([]KraneContainer, 0)
+	for _, c := range containers {
+		if c.Color == color && !c.Canary {
+			colored = append(colored, c)
+		}
+	}
+	return colored
+}
+
+// ActiveContainers returns the containers of a deployment receiving its traffic, leaving out
+// canary containers and the previous color of a blue/green deployment
+func ActiveContainers(deployment string, containers []KraneContainer) []KraneContainer {
+	return containersOfColor(containers, activeColor(deployment))
+}
+
+// RollbackBlueGreen switches the traffic of a deployment back to its previous color and restores the
+// configuration the previous color was created from. The previous color must still be running.
+func RollbackBlueGreen(deployment string) (BlueGreen, error) {
+	bg, err := GetBlueGreen(deployment)
+	if err != nil {
+		return BlueGreen{}, err
+	}
+
+	if bg.Previous == "" || bg.PreviousConfig == nil {
+		return BlueGreen{}, fmt.Errorf("deployment %s has no previous color to roll back to", deployment)
+	}
+
+	containers, err := GetContainersByDeployment(deployment)
+	if err != nil {
+		return BlueGreen{}, err
+	}
+
+	previous := containersOfColor(containers, bg.Previous)
+	if len(previous) == 0 {
+		return BlueGreen{}, fmt.Errorf("deployment %s has no containers left for color %s", deployment, bg.Previous)
+	}
+
+	for _, c := range previous {
+		if !c.State.Running {
+			return BlueGreen{}, fmt.Errorf("container %s of color %s is not running", c.Name, bg.Previous)
+		}
+	}
+
+	if err := switchTraffic(*bg.PreviousConfig, bg.Previous); err != nil {
+		return BlueGreen{}, err
+	}
+
+	// the configuration is restored as is, it was already validated when it was saved
+	restored := *bg.PreviousConfig
+	bytes, _ := restored.Serialize()
+	if err := store.Client().Put(constants.DeploymentsCollectionName, deployment, bytes); err != nil {
+		return BlueGreen{}, err
+	}
+
+	now := time.Now().Unix()
+	activeConfig := bg.ActiveConfig
+	bg.Active, bg.Previous = bg.Previous, bg.Active
+	bg.ActiveConfig, bg.PreviousConfig = restored, &activeConfig
+	bg.SwitchedAt = now
+	bg.PreviousExpiresAt = now + restored.BlueGreen.KeepPrevious
+	if err := saveBlueGreen(bg); err != nil {
+		return BlueGreen{}, err
+	}
+	return bg, nil
+}
+
+// PreviousColorExpired returns true if the previous color of a deployment is due to be removed
+func PreviousColorExpired(deployment string, now time.Time) bool {
+	bg, err := GetBlueGreen(deployment)
+	if err != nil {
+		return false
+	}
+	return bg.Previous != "" && now.Unix() >= bg.PreviousExpiresAt
+}
+
+// RemovePreviousColor removes the containers of the previous color of a deployment, it can't be rolled back to afterwards
+func RemovePreviousColor(deployment string) error {
+	if _, err := GetBlueGreen(deployment); err != nil {
+		return err
+	}
+
+	go enqueue(newRemovePreviousColorJob(deployment))
+	return nil
+}
+
+// newBlueGreenJob returns the job creating the containers of the next color of a deployment and switching traffic
+// over once they are healthy. The containers of the current color are kept running for rollbacks.
+func newBlueGreenJob(config Config) job.Job {
+	type BlueGreenJobArgs struct {
+		Config             Config
+		Current            BlueGreen
+		Color              Color
+		StaleContainers    []KraneContainer
+		ContainersToRemove []KraneContainer
+	}
+
+	jobID := uuid.Generate().String()
+	e := createEventEmitter(config.Name, jobID)
+	return job.Job{
+		ID:          jobID,
+		Deployment:  config.Name,
+		Type:        string(RunDeploymentJobType),
+		RetryPolicy: utils.UIntEnv(constants.EnvDeploymentRetryPolicy),
+		Args: &BlueGreenJobArgs{
+			Config:             config,
+			StaleContainers:    []KraneContainer{},
+			ContainersToRemove: []KraneContainer{},
+		},
+		Setup: func(args interface{}) error {
+			jobArgs := args.(*BlueGreenJobArgs)
+			deploymentName := jobArgs.Config.Name
+
+			// ensure secrets collections
+			if err := CreateSecretsCollection(deploymentName); err != nil {
+				logger.Errorf("unable to create secrets collection %v", err)
+				return err
+			}
+
+			// ensure jobs collections
+			if err := CreateJobsCollection(deploymentName); err != nil {
+				logger.Errorf("unable to create jobs collection %v", err)
+				return err
+			}
+
+			// the first blue/green run of a deployment has no current color
+			current, err := GetBlueGreen(deploymentName)
+			if err != nil {
+				current = BlueGreen{Deployment: deploymentName}
+			}
+			jobArgs.Current = current
+			jobArgs.Color = current.Active.next()
+
+			containers, err := GetContainersByDeployment(deploymentName)
+			if err != nil {
+				logger.Errorf("unable to get containers %v", err)
+				return err
+			}
+
+			// containers of the next color don't receive traffic and are replaced up front, containers
+			// without a color (created by the replace strategy) are removed once traffic is switched over
+			jobArgs.StaleContainers = containersOfColor(containers, jobArgs.Color)
+			jobArgs.ContainersToRemove = make([]KraneContainer, 0)
+			for _, c := range containers {
+				if c.Color == "" {
+					jobArgs.ContainersToRemove = append(jobArgs.ContainersToRemove, c)
+				}
+			}
+
+			return nil
+		},
+		Run: func(args interface{}) error {
+			jobArgs := args.(*BlueGreenJobArgs)
+			config := jobArgs.Config
+			current := jobArgs.Current
+			color := jobArgs.Color
+
+			if err := prepareContainers(config, e); err != nil {
+				return err
+			}
+
+			// pre-deploy hook, a failing hook leaves the current color untouched
+			if err := runHook(config, config.Hooks.PreDeploy, PreDeployHookPhase, e); err != nil {
+				logger.Errorf("pre-deploy hook failed %v", err)
+				return err
+			}
+
+			for _, c := range jobArgs.StaleContainers {
+				logger.Debugf("Removing container %s", c.Name)
+				if err := c.Remove(); err != nil {
+					logger.Errorf("unable to remove container %v", err)
+					return err
+				}
+			}
+
+			// create and start the containers of the next color
+			containersCreated := make([]KraneContainer, 0)
+			for i := 0; i < config.Scale; i++ {
+				c, err := createContainer(config, config.colorDockerConfig(color))
+				if err != nil {
+					logger.Errorf("unable to create container %v", err)
+					rollbackContainers(containersCreated, []KraneContainer{})
+					return err
+				}
+				containersCreated = append(containersCreated, c)
+
+				if err := c.Start(); err != nil {
+					logger.Errorf("unable to start container %v", err)
+					rollbackContainers(containersCreated, []KraneContainer{})
+					return err
+				}
+			}
+			logger.Debugf("%d container(s) for deployment %s started as %s", len(containersCreated), config.Name, color)
+
+			retries := 10
+			if err := RetriableContainersHealthCheck(containersCreated, retries); err != nil {
+				logger.Errorf("containers did not pass health check %v", err)
+				rollbackContainers(containersCreated, []KraneContainer{})
+				return err
+			}
+			logger.Debugf("Deployment %s health check complete", config.Name)
+
+			// switch traffic over to the next color
+			if err := switchTraffic(config, color); err != nil {
+				logger.Errorf("unable to switch traffic %v", err)
+				rollbackContainers(containersCreated, []KraneContainer{})
+				return err
+			}
+
+			// post-deploy hook, when the hook fails traffic is switched back and the new containers are removed
+			if err := runHook(config, config.Hooks.PostDeploy, PostDeployHookPhase, e); err != nil {
+				logger.Errorf("post-deploy hook failed %v", err)
+				if err := switchBack(current); err != nil {
+					logger.Errorf("unable to switch traffic back %v", err)
+				}
+				rollbackContainers(containersCreated, []KraneContainer{})
+				return err
+			}
+
+			now := time.Now().Unix()
+			bg := BlueGreen{
+				Deployment:   config.Name,
+				Active:       color,
+				ActiveConfig: config,
+				SwitchedAt:   now,
+			}
+			if current.Active != "" {
+				previousConfig := current.ActiveConfig
+				bg.Previous = current.Active
+				bg.PreviousConfig = &previousConfig
+				bg.PreviousExpiresAt = now + config.BlueGreen.KeepPrevious
+			}
+
+			if err := saveBlueGreen(bg); err != nil {
+				logger.Errorf("unable to save active color %v", err)
+				return err
+			}
+
+			// a successful run ends the maintenance of the deployment (if any)
+			if err := clearMaintenance(config.Name); err != nil {
+				logger.Errorf("unable to clear maintenance %v", err)
+				return err
+			}
+
+			if err := SyncProxy(); err != nil {
+				logger.Errorf("unable to write proxy configuration %v", err)
+				return err
+			}
+
+			switchEmitter := *e
+			switchEmitter.Phase = SwitchPhase
+			switchEmitter.emit(fmt.Sprintf("Traffic for deployment %s switched over to %s", config.Name, color))
+			return nil
+		},
+		Finally: func(args interface{}) error {
+			jobArgs := args.(*BlueGreenJobArgs)
+
+			for _, c := range jobArgs.ContainersToRemove {
+				logger.Debugf("Removing container %s", c.Name)
+				if err := c.Remove(); err != nil {
+					logger.Errorf("unable to remove container %v", err)
+					return err
+				}
+			}
+
+			// colors don't bind host ports, allocations made by the replace strategy are released
+			if err := ReleasePortAllocations(jobArgs.Config.Name); err != nil {
+				logger.Errorf("unable to release port allocations %v", err)
+				return err
+			}
+
+			return nil
+		},
+	}
+}
+
+// switchBack routes traffic back to the color active before a switch. Without a color, traffic goes
+// back to the containers created by the replace strategy.
+func switchBack(current BlueGreen) error {
+	if current.Active == "" {
+		return proxy.RemoveDynamicConfig(blueGreenName(current.Deployment))
+	}
+	return switchTraffic(current.ActiveConfig, current.Active)
+}
+
+// newRemovePreviousColorJob returns the job removing the containers of the previous color of a deployment
+func newRemovePreviousColorJob(deployment string) job.Job {
+	return job.Job{
+		ID:          uuid.Generate().String(),
+		Deployment:  deployment,
+		Type:        string(RemovePreviousColorJobType),
+		RetryPolicy: utils.UIntEnv(constants.EnvDeploymentRetryPolicy),
+		Args:        deployment,
+		Run: func(args interface{}) error {
+			deployment := args.(string)
+
+			bg, err := GetBlueGreen(deployment)
+			if err != nil {
+				logger.Errorf("unable to get colors %v", err)
+				return err
+			}
+
+			if bg.Previous == "" {
+				return nil
+			}
+
+			containers, err := GetContainersByDeployment(deployment)
+			if err != nil {
+				logger.Errorf("unable to get containers %v", err)
+				return err
+			}
+
+			for _, c := range containersOfColor(containers, bg.Previous) {
+				logger.Debugf("Removing container %s", c.Name)
+				if err := c.Remove(); err != nil {
+					logger.Errorf("unable to remove container %v", err)
+					return err
+				}
+			}
+
+			bg.Previous = ""
+			bg.PreviousConfig = nil
+			bg.PreviousExpiresAt = 0
+			return saveBlueGreen(bg)
+		},
+	}
+}
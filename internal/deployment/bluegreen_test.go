@@ -0,0 +1,107 @@
+package deployment
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/krane/krane/internal/docker"
+)
+
+func TestBlueGreenStrategy(t *testing.T) {
+	config := storedConfig(Config{Name: "api", Image: "biensupernice/api", TargetPort: "8080", Strategy: BlueGreenStrategy})
+	assert.Equal(t, int64(defaultKeepPrevious), config.BlueGreen.KeepPrevious)
+	assert.Nil(t, config.isValid())
+
+	assert.Equal(t, ReplaceStrategy, storedConfig(Config{Name: "api", Image: "biensupernice/api"}).Strategy)
+
+	invalid := config
+	invalid.Strategy = "rolling"
+	assert.Error(t, invalid.isValid())
+
+	invalid = config
+	invalid.Canary = &CanaryConfig{Weight: 10, Scale: 1}
+	assert.Error(t, invalid.isValid())
+
+	invalid = config
+	invalid.TargetPort = ""
+	assert.Error(t, invalid.isValid())
+
+	invalid = config
+	invalid.Ports = PortConfigs{{HostPort: "80", ContainerPort: "8080", Protocol: TCP}}
+	assert.Error(t, invalid.isValid())
+
+	valid := config
+	valid.Ports = PortConfigs{{ContainerPort: "8080", Protocol: TCP}}
+	assert.Nil(t, valid.isValid())
+}
+
+func TestColorLabels(t *testing.T) {
+	config := Config{
+		Name:       "api",
+		TargetPort: "8080",
+		Alias:      []string{"api.example.com"},
+		Labels:     map[string]string{"team": "payments", "traefik.http.routers.api-insecure.rule": "Host(`api.example.com`)"},
+	}
+
+	assert.Equal(t, Green, Blue.next())
+	assert.Equal(t, Blue, Green.next())
+	assert.Equal(t, Blue, Color("").next())
+
+	labels := config.colorLabels(Green)
+	assert.Equal(t, "api", labels[docker.ContainerDeploymentLabel])
+	assert.Equal(t, "green", labels[docker.ContainerColorLabel])
+	assert.Equal(t, "payments", labels["team"])
+	assert.Equal(t, "8080", labels["traefik.http.services.api-green.loadbalancer.server.port"])
+	assert.Equal(t, "true", labels["traefik.enable"])
+
+	// colors are only reachable through the proxy configuration switching traffic over
+	assert.NotContains(t, labels, "traefik.http.routers.api-green-insecure.rule")
+	assert.NotContains(t, labels, "traefik.http.routers.api-insecure.rule")
+	assert.Contains(t, config.colorProxyLabels(Green), "traefik.http.routers.api-green-insecure.rule")
+	assert.Equal(t, "api-green", config.colorServiceName(Green))
+}
+
+func TestActiveContainers(t *testing.T) {
+	containers := []KraneContainer{
+		{Name: "api-1"},
+		{Name: "api-blue-1", Color: Blue},
+		{Name: "api-green-1", Color: Green},
+		{Name: "api-canary-1", Canary: true},
+	}
+
+	// without colors the containers created by the replace strategy are active
+	active := ActiveContainers("bluegreen-test", containers)
+	assert.Len(t, active, 1)
+	assert.Equal(t, "api-1", active[0].Name)
+
+	now := time.Now()
+	assert.Nil(t, saveBlueGreen(BlueGreen{
+		Deployment:        "bluegreen-test",
+		Active:            Green,
+		Previous:          Blue,
+		PreviousConfig:    &Config{Name: "bluegreen-test"},
+		PreviousExpiresAt: now.Add(time.Hour).Unix(),
+	}))
+	defer clearBlueGreen("bluegreen-test")
+
+	active = ActiveContainers("bluegreen-test", containers)
+	assert.Len(t, active, 1)
+	assert.Equal(t, "api-green-1", active[0].Name)
+
+	assert.False(t, PreviousColorExpired("bluegreen-test", now))
+	assert.True(t, PreviousColorExpired("bluegreen-test", now.Add(2*time.Hour)))
+	assert.False(t, PreviousColorExpired("bluegreen-missing", now))
+}
+
+func TestRollbackBlueGreenWithoutPreviousColor(t *testing.T) {
+	_, err := RollbackBlueGreen("bluegreen-missing")
+	assert.Error(t, err)
+
+	assert.Nil(t, saveBlueGreen(BlueGreen{Deployment: "bluegreen-rollback", Active: Blue}))
+	defer clearBlueGreen("bluegreen-rollback")
+
+	_, err = RollbackBlueGreen("bluegreen-rollback")
+	assert.Error(t, err)
+}
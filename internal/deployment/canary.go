@@ -0,0 +1,455 @@
+package deployment
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution/uuid"
+	"github.com/docker/go-connections/nat"
+	"github.com/lithammer/shortuuid/v3"
+
+	"github.com/krane/krane/internal/constants"
+	"github.com/krane/krane/internal/docker"
+	"github.com/krane/krane/internal/job"
+	"github.com/krane/krane/internal/logger"
+	"github.com/krane/krane/internal/proxy"
+	"github.com/krane/krane/internal/store"
+	"github.com/krane/krane/internal/utils"
+)
+
+// CanaryConfig represents how new versions of a deployment are rolled out. When set, running a new
+// version of a deployment creates canary containers receiving a share of the traffic next to the
+// current containers, until the canary is promoted or aborted.
+type CanaryConfig struct {
+	Weight int `json:"weight"` // percentage of traffic routed to the canary containers (default 10)
+	Scale  int `json:"scale"`  // number of canary containers (default 1)
+}
+
+// Canary represents a new version of a deployment receiving a share of the traffic
+type Canary struct {
+	Deployment string `json:"deployment"`
+	Stable     Config `json:"stable"`     // configuration of the current containers receiving the rest of the traffic
+	Weight     int    `json:"weight"`     // percentage of traffic routed to the canary containers
+	CreatedAt  int64  `json:"created_at"` // when the canary was started
+	UpdatedAt  int64  `json:"updated_at"` // when the weight was last shifted
+}
+
+// applyDefaults applies default canary configuration values
+func (canary *CanaryConfig) applyDefaults() {
+	if canary.Weight == 0 {
+		canary.Weight = 10
+	}
+
+	if canary.Scale == 0 {
+		canary.Scale = 1
+	}
+}
+
+// isValid returns an error if a canary configuration is not valid for a deployment
+func (canary CanaryConfig) isValid(config Config) error {
+	if err := isValidCanaryWeight(canary.Weight); err != nil {
+		return err
+	}
+
+	if canary.Scale < 0 {
+		return fmt.Errorf("invalid canary scale %d", canary.Scale)
+	}
+
+	if config.stableServiceName() == "" {
+		return errors.New("canary requires a target port or container ports to route traffic to")
+	}
+
+	return nil
+}
+
+// isValidCanaryWeight returns an error if a canary weight is not a percentage
+func isValidCanaryWeight(weight int) error {
+	if weight < 0 || weight > 100 {
+		return fmt.Errorf("invalid canary weight %d, must be between 0 and 100", weight)
+	}
+	return nil
+}
+
+// stableServiceName returns the proxy service of the current containers of a deployment
+func (config Config) stableServiceName() string {
+	return proxy.TraefikServiceName(config.Name, config.httpContainerPorts(), config.TargetPort)
+}
+
+// canaryServiceName returns the proxy service of the canary containers of a deployment
+func (config Config) canaryServiceName() string {
+	return proxy.TraefikServiceName(canaryName(config.Name), config.httpContainerPorts(), config.TargetPort)
+}
+
+// canaryName returns the name used for the canary resources of a deployment
+func canaryName(deployment string) string {
+	return fmt.Sprintf("%s-canary", deployment)
+}
+
+// GetCanary returns the canary of a deployment
+func GetCanary(deployment string) (Canary, error) {
+	bytes, err := store.Client().Get(constants.CanariesCollectionName, deployment)
+	if err != nil {
+		return Canary{}, err
+	}
+
+	if bytes == nil {
+		return Canary{}, fmt.Errorf("deployment %s has no canary", deployment)
+	}
+
+	var canary Canary
+	if err := json.Unmarshal(bytes, &canary); err != nil {
+		return Canary{}, err
+	}
+	return canary, nil
+}
+
+// HasCanary returns true if a deployment has a canary
+func HasCanary(deployment string) bool {
+	_, err := GetCanary(deployment)
+	return err == nil
+}
+
+// saveCanary stores the canary of a deployment
+func saveCanary(canary Canary) error {
+	bytes, _ := json.Marshal(canary)
+	return store.Client().Put(constants.CanariesCollectionName, canary.Deployment, bytes)
+}
+
+// clearCanary removes the canary of a deployment and its proxy configuration
+func clearCanary(deployment string) error {
+	if err := proxy.RemoveDynamicConfig(canaryName(deployment)); err != nil {
+		return err
+	}
+	return store.Client().Remove(constants.CanariesCollectionName, deployment)
+}
+
+// isNewCanaryVersion returns true if running a deployment rolled out as a canary starts a canary, when
+// its configuration is a new version of the configuration the current containers were created from
+func isNewCanaryVersion(config Config) bool {
+	if config.Canary == nil {
+		return false
+	}
+
+	deployed, err := getDeployedConfig(config.Name)
+	if err != nil {
+		return false
+	}
+
+	// only a new version of the deployment starts a canary, not a change to the canary settings.
+	// Both configurations are compared as stored, unset and empty values serialize differently
+	candidate, current := config, deployed
+	candidate.Canary, current.Canary = nil, nil
+	return !sameConfig(roundTrip(candidate), roundTrip(current))
+}
+
+// prepareCanary returns the canary of a deployment, starting one if there is none. The configuration
+// the current containers were created from is kept as the stable version.
+func prepareCanary(config Config) (Canary, error) {
+	if canary, err := GetCanary(config.Name); err == nil {
+		return canary, nil
+	}
+
+	stable, err := getDeployedConfig(config.Name)
+	if err != nil {
+		return Canary{}, err
+	}
+
+	now := time.Now().Unix()
+	canary := Canary{
+		Deployment: config.Name,
+		Stable:     stable,
+		Weight:     config.Canary.Weight,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := saveCanary(canary); err != nil {
+		return Canary{}, err
+	}
+	return canary, nil
+}
+
+// roundTrip returns a deployment configuration as it is read back from the db
+func roundTrip(config Config) Config {
+	bytes, _ := config.Serialize()
+	stored, _ := DeSerializeConfig(bytes)
+	return stored
+}
+
+// ShiftCanary updates the percentage of traffic routed to the canary containers of a deployment
+func ShiftCanary(deployment string, weight int) (Canary, error) {
+	if err := isValidCanaryWeight(weight); err != nil {
+		return Canary{}, err
+	}
+
+	canary, err := GetCanary(deployment)
+	if err != nil {
+		return Canary{}, err
+	}
+
+	config, err := GetDeploymentConfig(deployment)
+	if err != nil {
+		return Canary{}, err
+	}
+
+	canary.Weight = weight
+	canary.UpdatedAt = time.Now().Unix()
+	if err := writeCanaryProxyConfig(canary, config); err != nil {
+		return Canary{}, err
+	}
+
+	if err := saveCanary(canary); err != nil {
+		return Canary{}, err
+	}
+	return canary, nil
+}
+
+// PromoteCanary rolls out the canary version of a deployment to all containers.
+// The deployment is re-run, replacing the current and canary containers.
+func PromoteCanary(deployment string) error {
+	if _, err := GetCanary(deployment); err != nil {
+		return err
+	}
+
+	// the canary is cleared once the new containers are healthy
+	config, err := GetDeploymentConfig(deployment)
+	if err != nil {
+		return err
+	}
+
+	go enqueueAfterDependencies(config, newRunJob(config))
+	return nil
+}
+
+// AbortCanary removes the canary containers of a deployment and restores its stable configuration
+func AbortCanary(deployment string) error {
+	canary, err := GetCanary(deployment)
+	if err != nil {
+		return err
+	}
+
+	go enqueue(newAbortCanaryJob(canary))
+	return nil
+}
+
+// writeCanaryProxyConfig writes the proxy configuration splitting traffic between the current and canary containers
+func writeCanaryProxyConfig(canary Canary, config Config) error {
+	// the routers are mirrored from the labels of the current containers
+	stable := canary.Stable
+	stable.Labels = make(map[string]string, 0)
+	for k, v := range canary.Stable.Labels {
+		stable.Labels[k] = v
+	}
+	stable.ApplyProxyLabels()
+
+	services := []proxy.WeightedServiceRef{
+		{Name: stable.stableServiceName() + "@docker", Weight: 100 - canary.Weight},
+		{Name: config.canaryServiceName() + "@docker", Weight: canary.Weight},
+	}
+
+	dynamicConfig := proxy.TraefikWeightedConfig(config.Name, stable.Labels, services)
+	return proxy.WriteDynamicConfig(canaryName(config.Name), dynamicConfig)
+}
+
+// canaryLabels returns the labels of canary containers. Canary containers only declare their proxy
+// service, traffic reaches them through the weighted service of the deployment.
+func (config Config) canaryLabels() map[string]string {
+	labels := make(map[string]string, 0)
+	for k, v := range config.Labels {
+		if !strings.HasPrefix(k, "traefik.") {
+			labels[k] = v
+		}
+	}
+
+	labels[docker.ContainerDeploymentLabel] = config.Name
+	labels[docker.ContainerCanaryLabel] = "true"
+	labels["traefik.enable"] = "true"
+	labels["traefik.docker.network"] = docker.KraneNetworkName
+
+	for k, v := range proxy.TraefikServiceLabels(canaryName(config.Name), config.httpContainerPorts(), config.TargetPort, config.Proxy.LoadBalancer.loadBalancer()) {
+		labels[k] = v
+	}
+	return labels
+}
+
+// canaryDockerConfig returns the docker configuration for creating a canary container.
+// Canary containers don't bind host ports, those stay with the current containers.
+func (config Config) canaryDockerConfig() docker.DockerConfig {
+	kraneNetwork, err := docker.GetClient().GetNetworkByName(docker.KraneNetworkName)
+	if err != nil {
+		return docker.DockerConfig{}
+	}
+
+	return docker.DockerConfig{
+		ContainerName: fmt.Sprintf("%s-%s", canaryName(config.Name), shortuuid.New()),
+		Image:         docker.ImageRef(config.Registry, config.Image, config.Tag),
+		NetworkID:     kraneNetwork.ID,
+		Aliases:       make([]string, 0),
+		Labels:        config.canaryLabels(),
+		Ports:         nat.PortMap{},
+		PortSet:       config.DockerPortSet(),
+		VolumeMounts:  config.DockerVolumeMount(),
+		VolumeSet:     config.DockerVolumeSet(),
+		Env:           config.DockerEnvs(),
+		Command:       config.Command,
+		Entrypoint:    config.Entrypoint,
+		WorkingDir:    config.WorkingDir,
+		User:          config.User,
+	}
+}
+
+// canaryContainers returns the canary containers from a list of containers
+func canaryContainers(containers []KraneContainer) []KraneContainer {
+	canaries := make([]KraneContainer, 0)
+	for _, c := range containers {
+		if c.Canary {
+			canaries = append(canaries, c)
+		}
+	}
+	return canaries
+}
+
+// newCanaryJob returns the job creating the canary containers of a deployment and splitting traffic
+// between the current and canary containers. Hooks are not run for canaries, they run when promoted.
+func newCanaryJob(config Config) job.Job {
+	type CanaryJobArgs struct {
+		Config             Config
+		ContainersToRemove []KraneContainer
+	}
+
+	jobID := uuid.Generate().String()
+	e := createEventEmitter(config.Name, jobID)
+	return job.Job{
+		ID:          jobID,
+		Deployment:  config.Name,
+		Type:        string(CanaryJobType),
+		RetryPolicy: utils.UIntEnv(constants.EnvDeploymentRetryPolicy),
+		Args: &CanaryJobArgs{
+			Config:             config,
+			ContainersToRemove: []KraneContainer{},
+		},
+		Setup: func(args interface{}) error {
+			jobArgs := args.(*CanaryJobArgs)
+
+			// containers of a previous canary are replaced
+			containers, err := GetContainersByDeployment(jobArgs.Config.Name)
+			if err != nil {
+				logger.Errorf("unable to get containers %v", err)
+				return err
+			}
+			jobArgs.ContainersToRemove = canaryContainers(containers)
+
+			return nil
+		},
+		Run: func(args interface{}) error {
+			jobArgs := args.(*CanaryJobArgs)
+			config := jobArgs.Config
+
+			// the current containers are kept as the stable version of a new canary
+			canary, err := prepareCanary(config)
+			if err != nil {
+				logger.Errorf("unable to prepare canary %v", err)
+				return err
+			}
+
+			if err := prepareContainers(config, e); err != nil {
+				return err
+			}
+
+			// create and start canary containers
+			containersCreated := make([]KraneContainer, 0)
+			for i := 0; i < config.Canary.Scale; i++ {
+				c, err := createContainer(config, config.canaryDockerConfig())
+				if err != nil {
+					logger.Errorf("unable to create canary container %v", err)
+					rollbackContainers(containersCreated, []KraneContainer{})
+					return err
+				}
+				containersCreated = append(containersCreated, c)
+
+				if err := c.Start(); err != nil {
+					logger.Errorf("unable to start canary container %v", err)
+					rollbackContainers(containersCreated, []KraneContainer{})
+					return err
+				}
+			}
+
+			retries := 10
+			if err := RetriableContainersHealthCheck(containersCreated, retries); err != nil {
+				logger.Errorf("canary containers did not pass health check %v", err)
+				rollbackContainers(containersCreated, []KraneContainer{})
+				return err
+			}
+
+			// split traffic between the current and canary containers
+			if err := writeCanaryProxyConfig(canary, config); err != nil {
+				logger.Errorf("unable to configure canary traffic %v", err)
+				rollbackContainers(containersCreated, []KraneContainer{})
+				return err
+			}
+
+			canaryEmitter := *e
+			canaryEmitter.Phase = CanaryPhase
+			canaryEmitter.emit(fmt.Sprintf("Canary for deployment %s receiving %d%% of the traffic", config.Name, canary.Weight))
+			return nil
+		},
+		Finally: func(args interface{}) error {
+			jobArgs := args.(*CanaryJobArgs)
+
+			for _, c := range jobArgs.ContainersToRemove {
+				logger.Debugf("Removing canary container %s", c.Name)
+				if err := c.Remove(); err != nil {
+					logger.Errorf("unable to remove container %v", err)
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+}
+
+// newAbortCanaryJob returns the job removing the canary containers of a deployment and restoring its stable configuration
+func newAbortCanaryJob(canary Canary) job.Job {
+	return job.Job{
+		ID:          uuid.Generate().String(),
+		Deployment:  canary.Deployment,
+		Type:        string(AbortCanaryJobType),
+		RetryPolicy: utils.UIntEnv(constants.EnvDeploymentRetryPolicy),
+		Args:        canary,
+		Run: func(args interface{}) error {
+			canary := args.(Canary)
+
+			// route all traffic back to the current containers before removing the canary containers
+			if err := proxy.RemoveDynamicConfig(canaryName(canary.Deployment)); err != nil {
+				logger.Errorf("unable to remove canary proxy configuration %v", err)
+				return err
+			}
+
+			containers, err := GetContainersByDeployment(canary.Deployment)
+			if err != nil {
+				logger.Errorf("unable to get containers %v", err)
+				return err
+			}
+
+			for _, c := range canaryContainers(containers) {
+				logger.Debugf("Removing canary container %s", c.Name)
+				if err := c.Remove(); err != nil {
+					logger.Errorf("unable to remove container %v", err)
+					return err
+				}
+			}
+
+			// the stable configuration is restored as is, it was already validated when it was saved
+			bytes, _ := canary.Stable.Serialize()
+			if err := store.Client().Put(constants.DeploymentsCollectionName, canary.Deployment, bytes); err != nil {
+				logger.Errorf("unable to restore deployment configuration %v", err)
+				return err
+			}
+
+			return clearCanary(canary.Deployment)
+		},
+	}
+}
@@ -0,0 +1,105 @@
+package deployment
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/krane/krane/internal/constants"
+	"github.com/krane/krane/internal/docker"
+	"github.com/krane/krane/internal/store"
+)
+
+func TestCanaryConfigDefaults(t *testing.T) {
+	canary := CanaryConfig{}
+	canary.applyDefaults()
+	assert.Equal(t, CanaryConfig{Weight: 10, Scale: 1}, canary)
+
+	config := Config{Name: "api", TargetPort: "8080"}
+	assert.Nil(t, canary.isValid(config))
+	assert.Error(t, CanaryConfig{Weight: 101, Scale: 1}.isValid(config))
+	assert.Error(t, CanaryConfig{Weight: 10, Scale: 1}.isValid(Config{Name: "api"}))
+}
+
+func TestCanaryLabels(t *testing.T) {
+	config := Config{
+		Name:       "api",
+		TargetPort: "8080",
+		Alias:      []string{"api.example.com"},
+		Labels:     map[string]string{"team": "payments", "traefik.http.routers.api-insecure.rule": "Host(`api.example.com`)"},
+	}
+
+	labels := config.canaryLabels()
+	assert.Equal(t, "api", labels[docker.ContainerDeploymentLabel])
+	assert.Equal(t, "true", labels[docker.ContainerCanaryLabel])
+	assert.Equal(t, "payments", labels["team"])
+	assert.Equal(t, "8080", labels["traefik.http.services.api-canary.loadbalancer.server.port"])
+	assert.NotContains(t, labels, "traefik.http.routers.api-insecure.rule")
+	assert.Equal(t, "api-canary", config.canaryServiceName())
+}
+
+func TestPrepareAndShiftCanary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "krane-proxy")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	_ = os.Setenv(constants.EnvProxyConfigDir, dir)
+	defer os.Unsetenv(constants.EnvProxyConfigDir)
+
+	stable := storedConfig(Config{Name: "canary-test", Image: "biensupernice/api", Tag: "1.0.0", TargetPort: "8080", Alias: []string{"api.example.com"}})
+	candidate := stable
+	candidate.Canary = &CanaryConfig{Weight: 20, Scale: 1}
+
+	// a deployment which was never run has no current containers to keep as stable
+	assert.False(t, isNewCanaryVersion(candidate))
+	_, err = prepareCanary(candidate)
+	assert.Error(t, err)
+
+	// saving a new version doesn't start a canary, running it does
+	assert.Nil(t, saveDeployedConfig(stable))
+	defer deleteDeployedConfig(stable.Name)
+
+	bytes, _ := candidate.Serialize()
+	assert.Nil(t, store.Client().Put(constants.DeploymentsCollectionName, stable.Name, bytes))
+	defer DeleteConfig(stable.Name)
+
+	// changing only the canary settings doesn't start a canary
+	assert.False(t, isNewCanaryVersion(candidate))
+	assert.False(t, HasCanary(stable.Name))
+
+	// a new version starts a canary keeping the deployed version as stable
+	candidate.Tag = "2.0.0"
+	assert.True(t, isNewCanaryVersion(candidate))
+	canary, err := prepareCanary(candidate)
+	assert.Nil(t, err)
+	assert.Equal(t, 20, canary.Weight)
+	assert.Equal(t, "1.0.0", canary.Stable.Tag)
+
+	// an existing canary is kept when the canary version runs again
+	candidate.Canary.Weight = 30
+	canary, err = prepareCanary(candidate)
+	assert.Nil(t, err)
+	assert.Equal(t, 20, canary.Weight)
+
+	bytes, _ = candidate.Serialize()
+	assert.Nil(t, store.Client().Put(constants.DeploymentsCollectionName, stable.Name, bytes))
+
+	_, err = ShiftCanary(stable.Name, 150)
+	assert.Error(t, err)
+
+	canary, err = ShiftCanary(stable.Name, 50)
+	assert.Nil(t, err)
+	assert.Equal(t, 50, canary.Weight)
+
+	file, err := ioutil.ReadFile(filepath.Join(dir, "canary-test-canary.yml"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(file), "name: canary-test-canary@docker\n                      weight: 50")
+
+	assert.Nil(t, clearCanary(stable.Name))
+	assert.False(t, HasCanary(stable.Name))
+	_, err = os.Stat(filepath.Join(dir, "canary-test-canary.yml"))
+	assert.True(t, os.IsNotExist(err))
+}
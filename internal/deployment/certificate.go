@@ -0,0 +1,321 @@
+package deployment
+
+import (
+	"crypto/cipher"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/krane/krane/internal/constants"
+	"github.com/krane/krane/internal/proxy"
+	"github.com/krane/krane/internal/store"
+)
+
+// Certificate represents an uploaded TLS certificate served by the proxy instead of a Let's Encrypt certificate
+type Certificate struct {
+	Name        string   `json:"name"`
+	Domains     []string `json:"domains"`          // domains covered by the certificate, wildcards included (*.example.com)
+	Issuer      string   `json:"issuer"`           // common name of the certificate issuer
+	NotBefore   int64    `json:"not_before"`       // epoch in seconds the certificate is valid from
+	NotAfter    int64    `json:"not_after"`        // epoch in seconds the certificate expires
+	Fingerprint string   `json:"fingerprint"`      // sha256 fingerprint of the certificate
+	UpdatedAt   int64    `json:"updated_at_epoch"` // epoch in seconds since 1970
+}
+
+// storedCertificate is a certificate with its PEM encoded certificate chain and private key, both encrypted
+type storedCertificate struct {
+	Certificate
+	Cert string `json:"cert"`
+	Key  string `json:"key"`
+}
+
+// certificatesMu guards writing the certificates loaded by the proxy
+var certificatesMu sync.Mutex
+
+// SaveCertificate creates or replaces an uploaded certificate from a PEM encoded certificate chain and private key
+func SaveCertificate(name, certPEM, keyPEM string) (Certificate, error) {
+	if !(Config{Name: name}).isValidName() {
+		return Certificate{}, fmt.Errorf("invalid certificate name %s", name)
+	}
+
+	cert, err := parseCertificate(name, certPEM, keyPEM)
+	if err != nil {
+		return Certificate{}, err
+	}
+
+	gcm, err := certificateCipher()
+	if err != nil {
+		return Certificate{}, err
+	}
+
+	stored := storedCertificate{Certificate: cert}
+	if stored.Cert, err = seal(gcm, certPEM, certificateAAD(name, "cert")); err != nil {
+		return Certificate{}, err
+	}
+
+	if stored.Key, err = seal(gcm, keyPEM, certificateAAD(name, "key")); err != nil {
+		return Certificate{}, err
+	}
+
+	bytes, _ := json.Marshal(stored)
+	if err := store.Client().Put(constants.CertificatesCollectionName, name, bytes); err != nil {
+		return Certificate{}, err
+	}
+
+	if err := SyncCertificates(); err != nil {
+		return Certificate{}, err
+	}
+
+	return cert, nil
+}
+
+// parseCertificate returns the details of a certificate, the private key must match the certificate
+func parseCertificate(name, certPEM, keyPEM string) (Certificate, error) {
+	pair, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+	if err != nil {
+		return Certificate{}, fmt.Errorf("invalid certificate %s, %v", name, err)
+	}
+
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return Certificate{}, fmt.Errorf("invalid certificate %s, %v", name, err)
+	}
+
+	if time.Now().After(leaf.NotAfter) {
+		return Certificate{}, fmt.Errorf("certificate %s expired on %s", name, leaf.NotAfter.Format(time.RFC3339))
+	}
+
+	domains := make([]string, 0)
+	for _, domain := range leaf.DNSNames {
+		domains = append(domains, strings.ToLower(domain))
+	}
+
+	if len(domains) == 0 && leaf.Subject.CommonName != "" {
+		domains = append(domains, strings.ToLower(leaf.Subject.CommonName))
+	}
+
+	if len(domains) == 0 {
+		return Certificate{}, fmt.Errorf("certificate %s does not cover any domain", name)
+	}
+
+	sum := sha256.Sum256(leaf.Raw)
+	return Certificate{
+		Name:        name,
+		Domains:     domains,
+		Issuer:      leaf.Issuer.CommonName,
+		NotBefore:   leaf.NotBefore.Unix(),
+		NotAfter:    leaf.NotAfter.Unix(),
+		Fingerprint: hex.EncodeToString(sum[:]),
+		UpdatedAt:   time.Now().Unix(),
+	}, nil
+}
+
+// GetCertificate returns an uploaded certificate, the certificate chain and private key are never returned
+func GetCertificate(name string) (Certificate, error) {
+	stored, err := getStoredCertificate(name)
+	if err != nil {
+		return Certificate{}, err
+	}
+	return stored.Certificate, nil
+}
+
+// getStoredCertificate returns an uploaded certificate with its encrypted certificate chain and private key
+func getStoredCertificate(name string) (storedCertificate, error) {
+	bytes, err := store.Client().Get(constants.CertificatesCollectionName, name)
+	if err != nil {
+		return storedCertificate{}, err
+	}
+
+	if bytes == nil {
+		return storedCertificate{}, fmt.Errorf("certificate %s not found", name)
+	}
+
+	var stored storedCertificate
+	if err := json.Unmarshal(bytes, &stored); err != nil {
+		return storedCertificate{}, err
+	}
+	return stored, nil
+}
+
+// GetAllCertificates returns all uploaded certificates
+func GetAllCertificates() ([]Certificate, error) {
+	stored, err := getAllStoredCertificates()
+	if err != nil {
+		return make([]Certificate, 0), err
+	}
+
+	certificates := make([]Certificate, 0)
+	for _, s := range stored {
+		certificates = append(certificates, s.Certificate)
+	}
+	return certificates, nil
+}
+
+// getAllStoredCertificates returns all uploaded certificates sorted by name
+func getAllStoredCertificates() ([]storedCertificate, error) {
+	bytes, err := store.Client().GetAll(constants.CertificatesCollectionName)
+	if err != nil {
+		return make([]storedCertificate, 0), err
+	}
+
+	certificates := make([]storedCertificate, 0)
+	for _, b := range bytes {
+		var stored storedCertificate
+		if err := json.Unmarshal(b, &stored); err != nil {
+			return make([]storedCertificate, 0), err
+		}
+		certificates = append(certificates, stored)
+	}
+
+	sort.Slice(certificates, func(i, j int) bool { return certificates[i].Name < certificates[j].Name })
+	return certificates, nil
+}
+
+// DeleteCertificate removes an uploaded certificate, certificates used by deployments can't be removed
+func DeleteCertificate(name string) error {
+	if _, err := GetCertificate(name); err != nil {
+		return err
+	}
+
+	configs, err := GetAllDeploymentConfigs()
+	if err != nil {
+		return err
+	}
+
+	for _, config := range configs {
+		if config.Certificate == name {
+			return fmt.Errorf("certificate %s is used by deployment %s", name, config.Name)
+		}
+	}
+
+	if err := store.Client().Remove(constants.CertificatesCollectionName, name); err != nil {
+		return err
+	}
+
+	// the proxy stops loading the certificate before its files are removed
+	if err := SyncCertificates(); err != nil {
+		return err
+	}
+
+	certificatesMu.Lock()
+	defer certificatesMu.Unlock()
+	return proxy.RemoveCertificate(name)
+}
+
+// SyncCertificates writes the uploaded certificates and the proxy configuration loading them
+func SyncCertificates() error {
+	certificatesMu.Lock()
+	defer certificatesMu.Unlock()
+
+	certificates, err := getAllStoredCertificates()
+	if err != nil {
+		return err
+	}
+
+	if len(certificates) == 0 {
+		return proxy.RemoveDynamicConfig(proxy.CertificatesConfigName)
+	}
+
+	gcm, err := certificateCipher()
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0)
+	for _, stored := range certificates {
+		cert, err := open(gcm, stored.Cert, certificateAAD(stored.Name, "cert"))
+		if err != nil {
+			return fmt.Errorf("unable to decrypt certificate %s, %v", stored.Name, err)
+		}
+
+		key, err := open(gcm, stored.Key, certificateAAD(stored.Name, "key"))
+		if err != nil {
+			return fmt.Errorf("unable to decrypt certificate %s, %v", stored.Name, err)
+		}
+
+		if err := proxy.WriteCertificate(stored.Name, []byte(cert), []byte(key)); err != nil {
+			return err
+		}
+		names = append(names, stored.Name)
+	}
+
+	return proxy.WriteDynamicConfig(proxy.CertificatesConfigName, proxy.TraefikCertificatesConfig(names))
+}
+
+// covers returns true if a certificate is valid for a host, wildcards only cover a single label
+func (cert Certificate) covers(host string) bool {
+	host = strings.ToLower(host)
+	for _, domain := range cert.Domains {
+		if domain == host {
+			return true
+		}
+
+		if strings.HasPrefix(domain, "*.") {
+			label := strings.TrimSuffix(host, domain[1:])
+			if label != host && label != "" && !strings.Contains(label, ".") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// certResolver returns the resolver requesting certificates for the secure routers of a deployment,
+// deployments serving an uploaded certificate don't use a resolver
+func (config Config) certResolver() string {
+	if config.Certificate != "" {
+		return ""
+	}
+	return proxy.LetsEncryptResolver
+}
+
+// validateCertificate returns an error if the uploaded certificate of a deployment doesn't exist or
+// doesn't cover the aliases and routes of the deployment
+func validateCertificate(config Config) error {
+	if config.Certificate == "" {
+		return nil
+	}
+
+	cert, err := GetCertificate(config.Certificate)
+	if err != nil {
+		return err
+	}
+
+	hosts := append([]string{}, config.Alias...)
+	for _, route := range config.Routes {
+		hosts = append(hosts, route.Host)
+	}
+
+	for _, host := range hosts {
+		if host != "" && !cert.covers(host) {
+			return fmt.Errorf("certificate %s does not cover %s", cert.Name, host)
+		}
+	}
+	return nil
+}
+
+// certificateCipher returns the cipher encrypting uploaded certificates at rest. The key is derived from
+// the Krane private key so certificates can't be read from the db alone.
+func certificateCipher() (cipher.AEAD, error) {
+	privateKey := os.Getenv(constants.EnvKranePrivateKey)
+	if privateKey == "" {
+		return nil, errors.New("krane private key not set")
+	}
+
+	key := sha256.Sum256([]byte("krane-certificates/" + privateKey))
+	return newGCM(key[:])
+}
+
+// certificateAAD binds an encrypted certificate value to its certificate
+func certificateAAD(name, part string) []byte {
+	return []byte(fmt.Sprintf("certificate/%s/%s", name, part))
+}
@@ -0,0 +1,130 @@
+package deployment
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/krane/krane/internal/constants"
+	"github.com/krane/krane/internal/store"
+)
+
+// selfSignedCertificate returns a PEM encoded self-signed certificate and private key for domains
+func selfSignedCertificate(t *testing.T, domains []string, notAfter time.Time) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: domains[0]},
+		DNSNames:     domains,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	return string(cert), string(keyPEM)
+}
+
+func TestParseCertificate(t *testing.T) {
+	cert, key := selfSignedCertificate(t, []string{"*.example.com", "example.com"}, time.Now().Add(24*time.Hour))
+
+	parsed, err := parseCertificate("wildcard", cert, key)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"*.example.com", "example.com"}, parsed.Domains)
+	assert.Len(t, parsed.Fingerprint, 64)
+
+	assert.True(t, parsed.covers("example.com"))
+	assert.True(t, parsed.covers("API.example.com"))
+	assert.False(t, parsed.covers("v1.api.example.com"))
+	assert.False(t, parsed.covers("example.org"))
+
+	// the private key must match the certificate
+	_, otherKey := selfSignedCertificate(t, []string{"example.com"}, time.Now().Add(24*time.Hour))
+	_, err = parseCertificate("mismatch", cert, otherKey)
+	assert.Error(t, err)
+
+	expiredCert, expiredKey := selfSignedCertificate(t, []string{"example.com"}, time.Now().Add(-time.Minute))
+	_, err = parseCertificate("expired", expiredCert, expiredKey)
+	assert.Error(t, err)
+}
+
+func TestSaveAndDeleteCertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "krane-proxy")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	_ = os.Setenv(constants.EnvProxyConfigDir, dir)
+	defer os.Unsetenv(constants.EnvProxyConfigDir)
+	_ = os.Setenv(constants.EnvKranePrivateKey, "test-private-key")
+	defer os.Unsetenv(constants.EnvKranePrivateKey)
+
+	cert, key := selfSignedCertificate(t, []string{"*.internal.example.com"}, time.Now().Add(24*time.Hour))
+	saved, err := SaveCertificate("internal-wildcard", cert, key)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"*.internal.example.com"}, saved.Domains)
+
+	// the certificate and private key are encrypted at rest
+	bytes, err := store.Client().Get(constants.CertificatesCollectionName, "internal-wildcard")
+	assert.Nil(t, err)
+	assert.NotContains(t, string(bytes), "PRIVATE KEY")
+
+	written, err := ioutil.ReadFile(filepath.Join(dir, "certs", "internal-wildcard.key"))
+	assert.Nil(t, err)
+	assert.Equal(t, key, string(written))
+
+	dynamicConfig, err := ioutil.ReadFile(filepath.Join(dir, "certificates.yml"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(dynamicConfig), "/etc/krane/proxy/certs/internal-wildcard.crt")
+
+	config := storedConfig(Config{Name: "cert-test", Image: "biensupernice/api", Secure: true, Certificate: "internal-wildcard", Alias: []string{"api.internal.example.com"}})
+	assert.Nil(t, validateCertificate(config))
+	assert.Equal(t, "", config.certResolver())
+
+	uncovered := config
+	uncovered.Alias = []string{"api.example.com"}
+	assert.Error(t, validateCertificate(uncovered))
+
+	// certificates used by a deployment can't be removed
+	bytes, _ = config.Serialize()
+	assert.Nil(t, store.Client().Put(constants.DeploymentsCollectionName, config.Name, bytes))
+	assert.Error(t, DeleteCertificate("internal-wildcard"))
+	assert.Nil(t, DeleteConfig(config.Name))
+
+	assert.Nil(t, DeleteCertificate("internal-wildcard"))
+	_, err = GetCertificate("internal-wildcard")
+	assert.Error(t, err)
+
+	_, err = os.Stat(filepath.Join(dir, "certs", "internal-wildcard.key"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(dir, "certificates.yml"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCertificateRequiresSecure(t *testing.T) {
+	config := storedConfig(Config{Name: "api", Image: "biensupernice/api", Certificate: "internal-wildcard"})
+	assert.Error(t, config.isValid())
+
+	config.Secure = true
+	assert.Nil(t, config.isValid())
+
+	assert.Equal(t, "lets-encrypt", storedConfig(Config{Name: "api", Image: "biensupernice/api"}).certResolver())
+}
@@ -0,0 +1,95 @@
+package deployment
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Args represents the arguments of a container command or entrypoint
+type Args []string
+
+// UnmarshalJSON parses arguments from either a list of arguments (exec form)
+// ie. ["npm", "run", "start"] or a single string (shell form) ie. "npm run start"
+// which is split into arguments using shell quoting rules
+func (args *Args) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err == nil {
+		parsed, err := ParseShellArgs(raw)
+		if err != nil {
+			return err
+		}
+		*args = parsed
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return errors.New("command and entrypoint must be a string or a list of strings")
+	}
+	*args = list
+	return nil
+}
+
+// ParseShellArgs splits a shell form string into arguments. Arguments are separated by whitespace,
+// single quotes preserve the literal value of every character, double quotes preserve the literal
+// value of every character except \" and \\, and a backslash outside quotes escapes the next character.
+// A backslash followed by a newline continues the command on the next line.
+func ParseShellArgs(raw string) (Args, error) {
+	args := make(Args, 0)
+
+	var current strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+
+	for _, r := range raw {
+		switch {
+		case escaped && r == '\n':
+			// line continuation
+			escaped = false
+		case escaped:
+			if quote == '"' && r != '"' && r != '\\' {
+				current.WriteRune('\\')
+			}
+			current.WriteRune(r)
+			escaped = false
+			inArg = true
+		case r == '\\' && quote != '\'':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if escaped {
+		return nil, fmt.Errorf("invalid command %s, unexpected end of input after \\", raw)
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("invalid command %s, unterminated %c quote", raw, quote)
+	}
+
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
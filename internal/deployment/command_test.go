@@ -0,0 +1,61 @@
+package deployment
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseShellArgs(t *testing.T) {
+	tests := []struct {
+		raw  string
+		args Args
+	}{
+		{"npm run start --port 3000", Args{"npm", "run", "start", "--port", "3000"}},
+		{"  sh   -c  ", Args{"sh", "-c"}},
+		{`sh -c 'echo "hello world" && sleep 1'`, Args{"sh", "-c", `echo "hello world" && sleep 1`}},
+		{`echo "it's \"quoted\" \$HOME"`, Args{"echo", `it's "quoted" \$HOME`}},
+		{`echo hello\ world`, Args{"echo", "hello world"}},
+		{`echo ''`, Args{"echo", ""}},
+		{"node server.js \\\n  --port 3000", Args{"node", "server.js", "--port", "3000"}},
+		{"", Args{}},
+	}
+
+	for _, tt := range tests {
+		args, err := ParseShellArgs(tt.raw)
+		assert.Nil(t, err)
+		assert.Equal(t, tt.args, args, tt.raw)
+	}
+}
+
+func TestParseShellArgsInvalidQuoting(t *testing.T) {
+	_, err := ParseShellArgs(`echo "hello`)
+	assert.Error(t, err)
+
+	_, err = ParseShellArgs(`echo 'hello`)
+	assert.Error(t, err)
+
+	_, err = ParseShellArgs(`echo hello\`)
+	assert.Error(t, err)
+}
+
+func TestUnmarshalCommandAndEntrypoint(t *testing.T) {
+	var config Config
+	err := json.Unmarshal([]byte(`{
+		"command": "npm run start --port 3000",
+		"entrypoint": ["/bin/sh", "-c"],
+		"working_dir": "/app",
+		"user": "node:node"
+	}`), &config)
+	assert.Nil(t, err)
+	assert.Equal(t, Args{"npm", "run", "start", "--port", "3000"}, config.Command)
+	assert.Equal(t, Args{"/bin/sh", "-c"}, config.Entrypoint)
+	assert.Equal(t, "/app", config.WorkingDir)
+	assert.Equal(t, "node:node", config.User)
+}
+
+func TestRelativeWorkingDirIsInvalid(t *testing.T) {
+	config := Config{Name: "example-deployment", Image: "biensupernice/krane", WorkingDir: "app"}
+	assert.Error(t, config.isValid())
+}
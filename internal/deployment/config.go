@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"strings"
 
 	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/go-connections/nat"
@@ -19,23 +20,40 @@ import (
 
 // Config represents a deployment configuration
 type Config struct {
-	Name       string            `json:"name" binding:"required"`  // deployment name
-	Image      string            `json:"image" binding:"required"` // container image
-	Registry   string            `json:"registry"`                 // container registry
-	Tag        string            `json:"tag"`                      // container image tag
-	Alias      []string          `json:"alias"`                    // custom domain aliases (my-app.example.com or my-app.localhost)
-	Env        map[string]string `json:"env"`                      // deployment environment variables
-	Secrets    map[string]string `json:"secrets"`                  // deployment secrets resolved as environment variables
-	Labels     map[string]string `json:"labels"`                   // container labels
-	Ports      map[string]string `json:"ports"`                    // container ports to expose from the container to the host
-	TargetPort string            `json:"target_port"`              // the target port to load-balance request through
-	Volumes    map[string]string `json:"volumes"`                  // container volumes
-	Command    string            `json:"command"`                  // container start command
-	Entrypoint string            `json:"entrypoint"`               // container entrypoint
-	Scale      int               `json:"scale"`                    // number of containers to create for the deployment
-	Secure     bool              `json:"secure"`                   // enable/disable secure communication over HTTPS/TLS w/ auto generated certs
-	Internal   bool              `json:"internal"`                 // whether a deployment is internal (ie. krane-proxy)
-	RateLimit  uint              `json:"rate_limit"`               // requests per second for a given deployment (default 0, which means no rate limit)
+	Name        string             `json:"name" binding:"required"`  // deployment name
+	Image       string             `json:"image" binding:"required"` // container image
+	Registry    string             `json:"registry"`                 // container registry
+	Tag         string             `json:"tag"`                      // container image tag
+	Alias       []string           `json:"alias"`                    // custom domain aliases (my-app.example.com or my-app.localhost)
+	Routes      []RouteConfig      `json:"routes"`                   // host and path prefixes routed to the deployment
+	TCP         []TCPRouteConfig   `json:"tcp"`                      // raw TCP connections received on proxy entrypoints
+	UDP         []UDPRouteConfig   `json:"udp"`                      // UDP datagrams received on proxy entrypoints
+	Env         map[string]string  `json:"env"`                      // deployment environment variables
+	Secrets     map[string]string  `json:"secrets"`                  // deployment secrets resolved as environment variables
+	Labels      map[string]string  `json:"labels"`                   // container labels
+	Ports       PortConfigs        `json:"ports"`                    // container ports to expose from the container to the host
+	TargetPort  string             `json:"target_port"`              // the target port to load-balance request through
+	Volumes     VolumeConfigs      `json:"volumes"`                  // container volumes (bind, named or tmpfs)
+	Command     Args               `json:"command"`                  // container start command (list of arguments or shell form string)
+	Entrypoint  Args               `json:"entrypoint"`               // container entrypoint (list of arguments or shell form string)
+	WorkingDir  string             `json:"working_dir"`              // container working directory
+	User        string             `json:"user"`                     // user (and optionally group) the container process runs as
+	Scale       int                `json:"scale"`                    // number of containers to create for the deployment
+	Secure      bool               `json:"secure"`                   // enable/disable secure communication over HTTPS/TLS w/ auto generated certs
+	Certificate string             `json:"certificate"`              // uploaded certificate served instead of a Let's Encrypt certificate
+	Internal    bool               `json:"internal"`                 // whether a deployment is internal (ie. krane-proxy)
+	RateLimit   uint               `json:"rate_limit"`               // requests per second for a given deployment (default 0, which means no rate limit)
+	BasicAuth   BasicAuthConfig    `json:"basic_auth"`               // users allowed through basic auth in front of the deployment
+	IPAllowList []string           `json:"ip_allowlist"`             // IPs or CIDR ranges allowed to reach the deployment
+	Proxy       ProxyConfig        `json:"proxy"`                    // how the proxy handles requests for the deployment
+	Canary      *CanaryConfig      `json:"canary"`                   // roll out new versions as a canary receiving a share of the traffic
+	Strategy    Strategy           `json:"strategy"`                 // how containers are replaced when the deployment runs (replace or blue_green)
+	BlueGreen   BlueGreenConfig    `json:"blue_green"`               // how long the previous version of a blue_green deployment is kept
+	Sidecars    []SidecarConfig    `json:"sidecars"`                 // additional containers running alongside each deployment container
+	Hooks       HooksConfig        `json:"hooks"`                    // one-off containers run before and after deploying new containers
+	Schedules   []ScheduleConfig   `json:"schedules"`                // one-off commands run on a cron schedule
+	DependsOn   []DependencyConfig `json:"depends_on"`               // deployments started before the deployment
+	Template    *TemplateOrigin    `json:"template,omitempty"`       // template the deployment was instantiated from
 }
 
 // SaveConfig a deployment configuration into the db
@@ -47,6 +65,46 @@ func SaveConfig(config Config) error {
 		return err
 	}
 
+	others, err := GetAllDeploymentConfigs()
+	if err != nil {
+		return err
+	}
+
+	if err := validateDependencyCycles(config, others); err != nil {
+		logger.Errorf("deployment config is not valid %v", err)
+		return err
+	}
+
+	if err := validatePortConflicts(config, others); err != nil {
+		logger.Errorf("deployment config is not valid %v", err)
+		return err
+	}
+
+	if err := validateRouteConflicts(config, others); err != nil {
+		logger.Errorf("deployment config is not valid %v", err)
+		return err
+	}
+
+	if err := validateEntryPointConflicts(config, others); err != nil {
+		logger.Errorf("deployment config is not valid %v", err)
+		return err
+	}
+
+	if err := validateCertificate(config); err != nil {
+		logger.Errorf("deployment config is not valid %v", err)
+		return err
+	}
+
+	allocations, err := GetPortAllocations()
+	if err != nil {
+		return err
+	}
+
+	if err := validateAllocatedPortConflicts(config, allocations); err != nil {
+		logger.Errorf("deployment config is not valid %v", err)
+		return err
+	}
+
 	bytes, _ := config.Serialize()
 	return store.Client().Put(constants.DeploymentsCollectionName, config.Name, bytes)
 }
@@ -73,10 +131,54 @@ func (config *Config) applyDefaults() {
 		config.Alias = make([]string, 0)
 	}
 
+	if config.Routes == nil {
+		config.Routes = make([]RouteConfig, 0)
+	}
+
+	for i := range config.Routes {
+		config.Routes[i].applyDefaults()
+	}
+
+	if config.TCP == nil {
+		config.TCP = make([]TCPRouteConfig, 0)
+	}
+
+	for i := range config.TCP {
+		config.TCP[i].applyDefaults()
+	}
+
+	if config.UDP == nil {
+		config.UDP = make([]UDPRouteConfig, 0)
+	}
+
+	for i := range config.UDP {
+		config.UDP[i].EntryPoint = strings.ToLower(config.UDP[i].EntryPoint)
+	}
+
 	if config.Labels == nil {
 		config.Labels = make(map[string]string, 0)
 	}
 
+	if config.BasicAuth.Users == nil {
+		config.BasicAuth.Users = make(map[string]string, 0)
+	}
+
+	if config.IPAllowList == nil {
+		config.IPAllowList = make([]string, 0)
+	}
+
+	config.Proxy.applyDefaults()
+
+	if config.Canary != nil {
+		config.Canary.applyDefaults()
+	}
+
+	if config.Strategy == "" {
+		config.Strategy = ReplaceStrategy
+	}
+
+	config.BlueGreen.applyDefaults()
+
 	if config.Secrets == nil {
 		config.Secrets = make(map[string]string, 0)
 	}
@@ -86,17 +188,59 @@ func (config *Config) applyDefaults() {
 	}
 
 	if config.Volumes == nil {
-		config.Volumes = make(map[string]string, 0)
+		config.Volumes = make(VolumeConfigs, 0)
+	}
+
+	for i := range config.Volumes {
+		config.Volumes[i].applyDefaults()
 	}
 
 	if config.Ports == nil {
-		config.Ports = make(map[string]string, 0)
+		config.Ports = make(PortConfigs, 0)
+	}
+
+	for i := range config.Ports {
+		if config.Ports[i].Protocol == "" {
+			config.Ports[i].Protocol = TCP
+		}
 	}
 
 	if config.Tag == "" {
 		config.Tag = "latest"
 	}
 
+	if config.Sidecars == nil {
+		config.Sidecars = make([]SidecarConfig, 0)
+	}
+
+	for i := range config.Sidecars {
+		config.Sidecars[i].applyDefaults()
+	}
+
+	if config.Hooks.PreDeploy != nil {
+		config.Hooks.PreDeploy.applyDefaults()
+	}
+
+	if config.Hooks.PostDeploy != nil {
+		config.Hooks.PostDeploy.applyDefaults()
+	}
+
+	if config.Schedules == nil {
+		config.Schedules = make([]ScheduleConfig, 0)
+	}
+
+	for i := range config.Schedules {
+		config.Schedules[i].applyDefaults()
+	}
+
+	if config.DependsOn == nil {
+		config.DependsOn = make([]DependencyConfig, 0)
+	}
+
+	for i := range config.DependsOn {
+		config.DependsOn[i].applyDefaults()
+	}
+
 	return
 }
 
@@ -111,6 +255,112 @@ func (config Config) isValid() error {
 		return errors.New("image required in deployment config")
 	}
 
+	targets := make(map[string]bool, 0)
+	for _, v := range config.Volumes {
+		if err := v.isValid(); err != nil {
+			return err
+		}
+
+		if targets[v.Target] {
+			return fmt.Errorf("volume target %s is mounted more than once", v.Target)
+		}
+		targets[v.Target] = true
+	}
+
+	for _, p := range config.Ports {
+		if err := p.isValid(); err != nil {
+			return err
+		}
+	}
+
+	for _, route := range config.Routes {
+		if err := route.isValid(); err != nil {
+			return err
+		}
+	}
+
+	for _, route := range config.TCP {
+		if err := route.isValid(); err != nil {
+			return err
+		}
+	}
+
+	for _, route := range config.UDP {
+		if err := route.isValid(); err != nil {
+			return err
+		}
+	}
+
+	if config.Certificate != "" && !config.Secure {
+		return fmt.Errorf("certificate %s requires a secure deployment", config.Certificate)
+	}
+
+	if err := config.BasicAuth.isValid(); err != nil {
+		return err
+	}
+
+	if err := isValidIPAllowList(config.IPAllowList); err != nil {
+		return err
+	}
+
+	if err := config.Proxy.isValid(); err != nil {
+		return err
+	}
+
+	if config.Canary != nil {
+		if err := config.Canary.isValid(config); err != nil {
+			return err
+		}
+	}
+
+	if err := config.isValidStrategy(); err != nil {
+		return err
+	}
+
+	if err := config.isValidProxyBackend(); err != nil {
+		return err
+	}
+
+	if config.WorkingDir != "" && !strings.HasPrefix(config.WorkingDir, "/") {
+		return fmt.Errorf("working directory %s must be an absolute path", config.WorkingDir)
+	}
+
+	sidecars := make(map[string]bool, 0)
+	for _, sidecar := range config.Sidecars {
+		if err := sidecar.isValid(config); err != nil {
+			return err
+		}
+
+		if sidecars[sidecar.Name] {
+			return fmt.Errorf("sidecar %s is declared more than once", sidecar.Name)
+		}
+		sidecars[sidecar.Name] = true
+	}
+
+	schedules := make(map[string]bool, 0)
+	for _, schedule := range config.Schedules {
+		if err := schedule.isValid(); err != nil {
+			return err
+		}
+
+		if schedules[schedule.Name] {
+			return fmt.Errorf("schedule %s is declared more than once", schedule.Name)
+		}
+		schedules[schedule.Name] = true
+	}
+
+	dependencies := make(map[string]bool, 0)
+	for _, dependency := range config.DependsOn {
+		if err := dependency.isValid(config); err != nil {
+			return err
+		}
+
+		if dependencies[dependency.Deployment] {
+			return fmt.Errorf("dependency %s is declared more than once", dependency.Deployment)
+		}
+		dependencies[dependency.Deployment] = true
+	}
+
 	return nil
 }
 
@@ -175,61 +425,97 @@ func DeleteConfig(deployment string) error {
 	return store.Client().Remove(constants.DeploymentsCollectionName, deployment)
 }
 
+// saveDeployedConfig records the configuration the current containers of a deployment were created from
+func saveDeployedConfig(config Config) error {
+	bytes, _ := config.Serialize()
+	return store.Client().Put(constants.DeployedConfigsCollectionName, config.Name, bytes)
+}
+
+// getDeployedConfig returns the configuration the current containers of a deployment were created from
+func getDeployedConfig(deployment string) (Config, error) {
+	bytes, err := store.Client().Get(constants.DeployedConfigsCollectionName, deployment)
+	if err != nil {
+		return Config{}, err
+	}
+
+	if bytes == nil {
+		return Config{}, fmt.Errorf("deployment %s has not been deployed", deployment)
+	}
+	return DeSerializeConfig(bytes)
+}
+
+// deleteDeployedConfig removes the configuration the containers of a deployment were created from
+func deleteDeployedConfig(deployment string) error {
+	return store.Client().Remove(constants.DeployedConfigsCollectionName, deployment)
+}
+
 // Empty returns true if a config has not defined a deployment name or image
 func (config Config) Empty() bool {
 	return config.Name == "" || config.Image == ""
 }
 
-// DockerConfig returns the docker configuration for creating a container
-func (config Config) DockerConfig() docker.DockerConfig {
+// DockerConfig returns the docker configuration for creating the container in a deployment slot
+func (config Config) DockerConfig(slot int) (docker.DockerConfig, error) {
 	kraneNetwork, err := docker.GetClient().GetNetworkByName(docker.KraneNetworkName)
 	if err != nil {
-		return docker.DockerConfig{}
+		return docker.DockerConfig{}, err
 	}
 
-	var command []string
-	var entrypoint []string
-
-	if config.Command != "" {
-		command = append(command, config.Command)
-	}
-
-	if config.Entrypoint != "" {
-		entrypoint = append(entrypoint, config.Entrypoint)
+	ports, err := config.DockerPorts(slot)
+	if err != nil {
+		return docker.DockerConfig{}, err
 	}
 
 	containerName := fmt.Sprintf("%s-%s", config.Name, shortuuid.New())
 	return docker.DockerConfig{
 		ContainerName: containerName,
-		Image:         config.Image,
+		Image:         docker.ImageRef(config.Registry, config.Image, config.Tag),
 		NetworkID:     kraneNetwork.ID,
-		Aliases:       config.Alias,
+		Aliases:       config.networkAliases(),
 		Labels:        config.DockerLabels(),
-		Ports:         config.DockerPorts(),
+		Ports:         ports,
 		PortSet:       config.DockerPortSet(),
 		VolumeMounts:  config.DockerVolumeMount(),
 		VolumeSet:     config.DockerVolumeSet(),
 		Env:           config.DockerEnvs(),
-		Command:       command,
-		Entrypoint:    entrypoint,
+		Command:       config.Command,
+		Entrypoint:    config.Entrypoint,
+		WorkingDir:    config.WorkingDir,
+		User:          config.User,
+	}, nil
+}
+
+// networkAliases returns the names the containers of a deployment are reachable at on the Krane network.
+// With Caddy the deployment name resolves to all of its containers, Caddy balances requests by name.
+func (config Config) networkAliases() []string {
+	aliases := make([]string, 0)
+	aliases = append(aliases, config.Alias...)
+	if proxy.GetBackend().Name() == proxy.CaddyBackendName {
+		aliases = append(aliases, config.Name)
 	}
+	return aliases
 }
 
 // DockerEnvs returns a list of formatted Docker environment variables
 func (config Config) DockerEnvs() []string {
+	return dockerEnvs(config.Name, config.Env, config.Secrets)
+}
+
+// dockerEnvs returns a list of formatted Docker environment variables from environment variables and deployment secrets
+func dockerEnvs(deployment string, env map[string]string, secrets map[string]string) []string {
 	envs := make([]string, 0)
 
 	// environment variables sourced from the deployment config
-	for k, v := range config.Env {
+	for k, v := range env {
 		envs = append(envs, fmt.Sprintf("%s=%s", k, v))
 	}
 
 	// secrets specified in the deployment config which work the same as environment variables
 	// but with resolved values located server side
-	for key, alias := range config.Secrets {
-		secret, err := GetSecret(config.Name, key)
+	for key, alias := range secrets {
+		secret, err := GetSecret(deployment, key)
 		if err != nil || secret == nil {
-			logger.Infof("unable to resolve secret for %s with alias %s", config.Name, alias)
+			logger.Infof("unable to resolve secret for %s with alias %s", deployment, alias)
 			continue
 		}
 		envs = append(envs, fmt.Sprintf("%s=%s", key, secret.Value))
@@ -247,22 +533,13 @@ func (config Config) DockerLabels() map[string]string {
 
 // ApplyProxyLabels applies network labels to a deployment config
 func (config Config) ApplyProxyLabels() {
-	// default traefik labels
-	config.Labels["traefik.enable"] = "true"
-	config.Labels["traefik.docker.network"] = docker.KraneNetworkName
-
-	// router labels
-	for k, v := range proxy.TraefikRouterLabels(config.Name, config.Alias, config.Secure) {
-		config.Labels[k] = v
-	}
-
-	// middleware labels
-	for k, v := range proxy.TraefikMiddlewareLabels(config.Name, config.Secure, config.RateLimit) {
-		config.Labels[k] = v
-	}
+	config.applyProxyLabels(config.Name)
+}
 
-	// service labels
-	for k, v := range proxy.TraefikServiceLabels(config.Name, config.Ports, config.TargetPort) {
+// applyProxyLabels applies network labels to a deployment config, with the routers, middlewares
+// and services named after name (the deployment name unless the containers run as a color)
+func (config Config) applyProxyLabels(name string) {
+	for k, v := range proxy.GetBackend().Labels(config.proxySite(name)) {
 		config.Labels[k] = v
 	}
 }
@@ -270,12 +547,8 @@ func (config Config) ApplyProxyLabels() {
 // DockerVolumeMount returns a list of formatted Docker volume mounts
 func (config Config) DockerVolumeMount() []mount.Mount {
 	volumes := make([]mount.Mount, 0)
-	for hostVolume, containerVolume := range config.Volumes {
-		volumes = append(volumes, mount.Mount{
-			Type:   mount.TypeBind,
-			Source: hostVolume,
-			Target: containerVolume,
-		})
+	for _, v := range config.Volumes {
+		volumes = append(volumes, v.toDockerMount())
 	}
 	return volumes
 }
@@ -283,46 +556,44 @@ func (config Config) DockerVolumeMount() []mount.Mount {
 // DockerVolumeSet returns a set of Docker formatted volumes
 func (config Config) DockerVolumeSet() map[string]struct{} {
 	volumes := make(map[string]struct{}, 0)
-	for _, containerVolume := range config.Volumes {
-		volumes[containerVolume] = struct{}{}
+	for _, v := range config.Volumes {
+		volumes[v.Target] = struct{}{}
 	}
 	return volumes
 }
 
-// DockerPorts returns Docker formatted port map
-func (config Config) DockerPorts() nat.PortMap {
+// DockerPorts returns Docker formatted port map for the container in a deployment slot, an error is
+// returned if a host port can't be allocated so containers never start with a port left unpublished
+func (config Config) DockerPorts(slot int) (nat.PortMap, error) {
 	bindings := nat.PortMap{}
-	for hostPort, containerPort := range config.Ports {
+	for _, p := range config.Ports {
+		hostPort := p.HostPort
 		if hostPort == "" {
-			// randomly assign a host port if no explicit host port to bind to was provided
-			freePort, err := findFreePort()
+			// assign a stable host port to the slot if no explicit host port to bind to was provided
+			allocatedPort, err := AllocateHostPort(config.Name, slot, p)
 			if err != nil {
-				logger.Errorf("Error looking for a free port on host machine %v", err)
-				continue
+				return nat.PortMap{}, fmt.Errorf("unable to allocate a host port for container port %s, %v", p.ContainerPort, err)
 			}
-			hostPort = freePort
+			hostPort = allocatedPort
 		}
 
-		hostBinding := nat.PortBinding{HostPort: hostPort}
-
-		// TODO: expose functionality for binding to other protocols besides tcp
-		cPort, err := nat.NewPort(string(TCP), containerPort)
+		cPort, err := p.dockerPort()
 		if err != nil {
-			logger.Errorf("Error creating a new container port %v", err)
-			continue
+			return nat.PortMap{}, err
 		}
 
-		bindings[cPort] = []nat.PortBinding{hostBinding}
+		hostBinding := nat.PortBinding{HostIP: p.HostIP, HostPort: hostPort}
+		bindings[cPort] = append(bindings[cPort], hostBinding)
 	}
 
-	return bindings
+	return bindings, nil
 }
 
 // DockerPortSet returns Docker formatted port set
 func (config Config) DockerPortSet() nat.PortSet {
 	bindings := nat.PortSet{}
-	for _, containerPort := range config.Ports {
-		cPort, err := nat.NewPort(string(TCP), containerPort)
+	for _, p := range config.Ports {
+		cPort, err := p.dockerPort()
 		if err != nil {
 			logger.Errorf("Error creating a new container port %v", err)
 			continue
@@ -331,3 +602,17 @@ func (config Config) DockerPortSet() nat.PortSet {
 	}
 	return bindings
 }
+
+// httpContainerPorts returns the container ports able to receive http traffic from the proxy
+func (config Config) httpContainerPorts() []string {
+	ports := make([]string, 0)
+	seen := make(map[string]bool, 0)
+	for _, p := range config.Ports {
+		if p.protocol() != TCP || seen[p.ContainerPort] {
+			continue
+		}
+		seen[p.ContainerPort] = true
+		ports = append(ports, p.ContainerPort)
+	}
+	return ports
+}
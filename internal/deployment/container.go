@@ -3,11 +3,13 @@ package deployment
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/docker/docker/api/types"
 
 	"github.com/krane/krane/internal/docker"
+	"github.com/krane/krane/internal/logger"
 )
 
 // KraneContainer represents a Krane managed container
@@ -25,6 +27,12 @@ type KraneContainer struct {
 	Volumes    []Volume          `json:"volumes"`
 	Command    []string          `json:"command"`
 	Entrypoint []string          `json:"entrypoint"`
+	WorkingDir string            `json:"working_dir"`
+	User       string            `json:"user"`
+	Canary     bool              `json:"canary,omitempty"`   // whether the container receives canary traffic
+	Color      Color             `json:"color,omitempty"`    // color of the container when the deployment runs as blue/green
+	Sidecar    string            `json:"sidecar,omitempty"`  // name of the sidecar when the container is a sidecar
+	Sidecars   []KraneContainer  `json:"sidecars,omitempty"` // sidecar containers running alongside the container
 }
 
 // ContainerState represents the state of a Krane container
@@ -51,12 +59,21 @@ const (
 	ContainerCreated ContainerStatus = "created"
 )
 
-// ContainerCreate creates a docker container from a deployment config
-func ContainerCreate(config Config) (KraneContainer, error) {
+// ContainerCreate creates a docker container from a deployment config for a deployment slot.
+// A slot is the index of the container within the deployment scale used to assign stable host ports.
+func ContainerCreate(config Config, slot int) (KraneContainer, error) {
+	dockerConfig, err := config.DockerConfig(slot)
+	if err != nil {
+		return KraneContainer{}, err
+	}
+	return createContainer(config, dockerConfig)
+}
+
+// createContainer creates a docker container and the sidecars of a deployment config
+func createContainer(config Config, mappedConfig docker.DockerConfig) (KraneContainer, error) {
 	ctx := context.Background()
 	defer ctx.Done()
 
-	mappedConfig := config.DockerConfig()
 	body, err := docker.GetClient().CreateContainer(ctx, mappedConfig)
 	if err != nil {
 		return KraneContainer{}, err
@@ -69,30 +86,64 @@ func ContainerCreate(config Config) (KraneContainer, error) {
 		return KraneContainer{}, err
 	}
 
-	return fromDockerContainerToKcontainer(json), nil
+	c := fromDockerContainerToKcontainer(json)
+
+	// sidecars are created with (and removed together with) the container they run alongside
+	sidecars, err := createSidecars(config, c)
+	c.Sidecars = sidecars
+	if err != nil {
+		if removeErr := c.Remove(); removeErr != nil {
+			logger.Warnf("unable to remove container %s, %v", c.Name, removeErr)
+		}
+		return KraneContainer{}, err
+	}
+
+	return c, nil
 }
 
-// Start starts a Krane managed Docker Container
+// Start starts a Krane managed Docker Container followed by its sidecars
 func (c KraneContainer) Start() error {
 	ctx := context.Background()
 	defer ctx.Done()
 
-	return docker.GetClient().StartContainer(ctx, c.ID)
+	if err := docker.GetClient().StartContainer(ctx, c.ID); err != nil {
+		return err
+	}
+
+	for _, sidecar := range c.Sidecars {
+		if err := docker.GetClient().StartContainer(ctx, sidecar.ID); err != nil {
+			return fmt.Errorf("unable to start sidecar %s, %v", sidecar.Sidecar, err)
+		}
+	}
+
+	return nil
 }
 
-// Stops stops a Krane managed Docker Container
+// Stops stops the sidecars of a Krane managed Docker Container followed by the container
 func (c KraneContainer) Stop() error {
 	ctx := context.Background()
 	defer ctx.Done()
 
+	for _, sidecar := range c.Sidecars {
+		if err := docker.GetClient().StopContainer(ctx, sidecar.ID); err != nil {
+			return fmt.Errorf("unable to stop sidecar %s, %v", sidecar.Sidecar, err)
+		}
+	}
+
 	return docker.GetClient().StopContainer(ctx, c.ID)
 }
 
-// Remove removes a Krane managed Docker container
+// Remove removes the sidecars of a Krane managed Docker container followed by the container
 func (c KraneContainer) Remove() error {
 	ctx := context.Background()
 	defer ctx.Done()
 
+	for _, sidecar := range c.Sidecars {
+		if err := docker.GetClient().RemoveContainer(ctx, sidecar.ID, true); err != nil {
+			return fmt.Errorf("unable to remove sidecar %s, %v", sidecar.Sidecar, err)
+		}
+	}
+
 	return docker.GetClient().RemoveContainer(ctx, c.ID, true)
 }
 
@@ -104,13 +155,29 @@ func fromDockerContainerToKcontainer(container types.ContainerJSON) KraneContain
 	createdAt, _ := time.Parse(time.RFC3339, container.ContainerJSONBase.Created)
 	state := fromDockerStateToState(*container.State)
 	ports := fromPortMapToPortList(container.NetworkSettings.Ports)
+	if len(ports) == 0 && container.HostConfig != nil {
+		// containers not in a running state have no published ports, fallback to the configured port bindings
+		ports = fromPortMapToPortList(container.HostConfig.PortBindings)
+	}
 	volumes := fromMountPointToVolumeList(container.Mounts)
 
+	// sidecars share the hostname and network of the container they are attached to
+	sidecar := container.Config.Labels[docker.ContainerSidecarLabel]
+	name := container.Config.Hostname
+	if sidecar != "" {
+		name = strings.TrimPrefix(container.Name, "/")
+	}
+
+	networkID := ""
+	if n, ok := container.NetworkSettings.Networks[docker.KraneNetworkName]; ok && n != nil {
+		networkID = n.NetworkID
+	}
+
 	return KraneContainer{
 		ID:         container.ID,
 		Deployment: container.Config.Labels[docker.ContainerDeploymentLabel],
-		Name:       container.Config.Hostname,
-		NetworkID:  container.NetworkSettings.Networks[docker.KraneNetworkName].NetworkID,
+		Name:       name,
+		NetworkID:  networkID,
 		Image:      container.Config.Image,
 		ImageID:    container.ContainerJSONBase.Image,
 		CreatedAt:  createdAt.Unix(),
@@ -120,6 +187,11 @@ func fromDockerContainerToKcontainer(container types.ContainerJSON) KraneContain
 		Volumes:    volumes,
 		Command:    container.Config.Cmd,
 		Entrypoint: container.Config.Entrypoint,
+		WorkingDir: container.Config.WorkingDir,
+		User:       container.Config.User,
+		Canary:     container.Config.Labels[docker.ContainerCanaryLabel] == "true",
+		Color:      Color(container.Config.Labels[docker.ContainerColorLabel]),
+		Sidecar:    sidecar,
 	}
 }
 
@@ -159,7 +231,7 @@ func GetContainers() ([]KraneContainer, error) {
 		}
 	}
 
-	return containers, nil
+	return groupSidecars(containers), nil
 }
 
 // isKraneManagedContainer returns if a container is managed by Krane based on its labels
@@ -185,6 +257,83 @@ func GetContainersByDeployment(deployment string) ([]KraneContainer, error) {
 	return containers, nil
 }
 
+// stopContainersSharingHostPorts stops the containers binding any of the host ports of a container, returning
+// the containers it stopped. Used before starting a new container so it can take over host ports from the
+// container it replaces.
+func stopContainersSharingHostPorts(c KraneContainer, containers []KraneContainer) ([]KraneContainer, error) {
+	stopped := make([]KraneContainer, 0)
+	hostPorts := make(map[string]bool, 0)
+	for _, p := range c.Ports {
+		hostPorts[allocationKey(p.HostPort, PortProtocol(p.Type))] = true
+	}
+
+	for _, other := range containers {
+		if other.ID == c.ID || !other.State.Running {
+			continue
+		}
+
+		for _, p := range other.Ports {
+			if !hostPorts[allocationKey(p.HostPort, PortProtocol(p.Type))] {
+				continue
+			}
+
+			logger.Debugf("Stopping container %s to release host port %s", other.Name, p.HostPort)
+			if err := other.Stop(); err != nil {
+				return stopped, err
+			}
+			stopped = append(stopped, other)
+			break
+		}
+	}
+
+	return stopped, nil
+}
+
+// startContainersBySlot starts new containers one slot at a time. A container taking over the host ports of a
+// container it replaces stops that container first, and must pass its health check before the next slot is
+// replaced so at most one slot is unavailable at a time.
+func startContainersBySlot(created []KraneContainer, replaced []KraneContainer, retries int) ([]KraneContainer, error) {
+	started := make([]KraneContainer, 0)
+	for _, c := range created {
+		stopped, err := stopContainersSharingHostPorts(c, replaced)
+		if err != nil {
+			return started, fmt.Errorf("unable to release host ports, %v", err)
+		}
+
+		if err := c.Start(); err != nil {
+			return started, err
+		}
+		started = append(started, c)
+
+		if len(stopped) == 0 {
+			continue
+		}
+
+		if err := RetriableContainersHealthCheck([]KraneContainer{c}, retries); err != nil {
+			return started, err
+		}
+	}
+	return started, nil
+}
+
+// rollbackContainers removes newly created containers and starts the previously running containers they replaced
+func rollbackContainers(created []KraneContainer, previous []KraneContainer) {
+	for _, c := range created {
+		logger.Debugf("Removing container %s", c.Name)
+		if err := c.Remove(); err != nil {
+			logger.Warnf("unable to remove container %s, %v", c.Name, err)
+		}
+	}
+
+	running := make([]KraneContainer, 0)
+	for _, c := range previous {
+		if c.State.Running {
+			running = append(running, c)
+		}
+	}
+	startContainers(running)
+}
+
 // RetriableContainersHealthCheck returns an error if a container is considered unhealthy
 func RetriableContainersHealthCheck(containers []KraneContainer, retries int) error {
 	for _, c := range containers {
@@ -224,9 +373,15 @@ func (c KraneContainer) Running() (bool, error) {
 		return false, err
 	}
 
-	if resp.State.Running {
-		return true, nil
+	if !resp.State.Running {
+		return false, fmt.Errorf("container %s is not in running state", c.ID)
+	}
+
+	for _, sidecar := range c.Sidecars {
+		if _, err := sidecar.Running(); err != nil {
+			return false, fmt.Errorf("sidecar %s is not in running state, %v", sidecar.Sidecar, err)
+		}
 	}
 
-	return false, fmt.Errorf("container %s is not in running state", c.ID)
+	return true, nil
 }
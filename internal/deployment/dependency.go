@@ -0,0 +1,252 @@
+package deployment
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+
+	"github.com/krane/krane/internal/job"
+	"github.com/krane/krane/internal/logger"
+)
+
+// DependencyCondition is the state a dependency must reach before a deployment starts
+type DependencyCondition string
+
+const (
+	StartedDependency DependencyCondition = "started" // every dependency container is running
+	HealthyDependency DependencyCondition = "healthy" // every dependency container is running and passing its docker health check
+)
+
+// dependencyRetries is the number of times dependencies are checked before a deployment job is queued regardless
+const dependencyRetries = 10
+
+// DependencyConfig represents another deployment a deployment depends on
+type DependencyConfig struct {
+	Deployment string              `json:"deployment"` // name of the deployment depended on
+	Condition  DependencyCondition `json:"condition"`  // started or healthy (default started)
+}
+
+// UnmarshalJSON accepts either a deployment name (ie. "redis") or a dependency
+// object (ie. {"deployment": "redis", "condition": "healthy"})
+func (dependency *DependencyConfig) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*dependency = DependencyConfig{Deployment: name}
+		return nil
+	}
+
+	type dependencyConfig DependencyConfig
+	var d dependencyConfig
+	if err := json.Unmarshal(data, &d); err != nil {
+		return errors.New("depends_on entries must be a deployment name or an object with a deployment and condition")
+	}
+	*dependency = DependencyConfig(d)
+	return nil
+}
+
+// applyDefaults applies default dependency configuration values
+func (dependency *DependencyConfig) applyDefaults() {
+	if dependency.Condition == "" {
+		dependency.Condition = StartedDependency
+	}
+}
+
+// isValid returns an error if a dependency configuration is not valid
+func (dependency DependencyConfig) isValid(config Config) error {
+	if !(Config{Name: dependency.Deployment}).isValidName() {
+		return fmt.Errorf("invalid dependency %s", dependency.Deployment)
+	}
+
+	if dependency.Deployment == config.Name {
+		return fmt.Errorf("deployment %s cannot depend on itself", config.Name)
+	}
+
+	switch dependency.Condition {
+	case StartedDependency, HealthyDependency:
+	default:
+		return fmt.Errorf("invalid condition %s for dependency %s, must be started or healthy", dependency.Condition, dependency.Deployment)
+	}
+
+	return nil
+}
+
+// validateDependencyCycles returns an error if saving a deployment configuration would
+// introduce a dependency cycle with the other deployment configurations
+func validateDependencyCycles(config Config, others []Config) error {
+	graph := make(map[string][]string, 0)
+	for _, other := range others {
+		if other.Name == config.Name {
+			continue
+		}
+		graph[other.Name] = dependencyNames(other)
+	}
+	graph[config.Name] = dependencyNames(config)
+
+	if cycle := findCycle(config.Name, graph, make(map[string]bool, 0), []string{}); cycle != nil {
+		return fmt.Errorf("dependency cycle %s", strings.Join(cycle, " -> "))
+	}
+
+	return nil
+}
+
+// findCycle walks the dependency graph from a deployment returning the first cycle found, nil otherwise
+func findCycle(deployment string, graph map[string][]string, visited map[string]bool, path []string) []string {
+	for i, d := range path {
+		if d == deployment {
+			return append(append([]string{}, path[i:]...), deployment)
+		}
+	}
+
+	if visited[deployment] {
+		return nil
+	}
+	visited[deployment] = true
+
+	path = append(path, deployment)
+	for _, dependency := range graph[deployment] {
+		if cycle := findCycle(dependency, graph, visited, path); cycle != nil {
+			return cycle
+		}
+	}
+
+	return nil
+}
+
+// dependencyNames returns the names of the deployments a deployment depends on
+func dependencyNames(config Config) []string {
+	names := make([]string, 0)
+	for _, dependency := range config.DependsOn {
+		names = append(names, dependency.Deployment)
+	}
+	return names
+}
+
+// SortByDependencies returns deployment configurations ordered so that deployments come after
+// the deployments they depend on. Deployments part of a dependency cycle are kept at the end.
+func SortByDependencies(configs []Config) []Config {
+	byName := make(map[string]Config, 0)
+	for _, config := range configs {
+		byName[config.Name] = config
+	}
+
+	sorted := make([]Config, 0)
+	added := make(map[string]bool, 0)
+	for len(sorted) < len(configs) {
+		progress := false
+		for _, config := range configs {
+			if added[config.Name] {
+				continue
+			}
+
+			ready := true
+			for _, dependency := range config.DependsOn {
+				if _, ok := byName[dependency.Deployment]; ok && !added[dependency.Deployment] {
+					ready = false
+					break
+				}
+			}
+
+			if ready {
+				sorted = append(sorted, config)
+				added[config.Name] = true
+				progress = true
+			}
+		}
+
+		if !progress {
+			break
+		}
+	}
+
+	for _, config := range configs {
+		if !added[config.Name] {
+			sorted = append(sorted, config)
+		}
+	}
+
+	return sorted
+}
+
+// CheckDependencies returns an error if a dependency of a deployment has not reached its condition
+func CheckDependencies(config Config) error {
+	for _, dependency := range config.DependsOn {
+		if err := dependency.check(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check returns an error if a dependency has not reached its condition
+func (dependency DependencyConfig) check() error {
+	if !Exist(dependency.Deployment) {
+		return fmt.Errorf("dependency %s does not exist", dependency.Deployment)
+	}
+
+	containers, err := GetContainersByDeployment(dependency.Deployment)
+	if err != nil {
+		return err
+	}
+
+	if len(containers) == 0 {
+		return fmt.Errorf("dependency %s has no containers", dependency.Deployment)
+	}
+
+	for _, c := range containers {
+		if !c.State.Running {
+			return fmt.Errorf("dependency %s container %s is not running", dependency.Deployment, c.Name)
+		}
+
+		// containers without a docker health check are considered healthy once running
+		if dependency.Condition == HealthyDependency && c.State.Health != nil && c.State.Health.Status != types.Healthy {
+			return fmt.Errorf("dependency %s container %s is %s", dependency.Deployment, c.Name, c.State.Health.Status)
+		}
+	}
+
+	return nil
+}
+
+// waitForDependencies waits for the dependencies of a deployment to reach their condition,
+// an error is returned if they are not ready after retrying with an increasing delay
+func waitForDependencies(config Config, e *EventEmitter) error {
+	if len(config.DependsOn) == 0 {
+		return nil
+	}
+
+	dependencyEmitter := *e
+	dependencyEmitter.Phase = DependenciesPhase
+
+	var err error
+	for i := 0; i <= dependencyRetries; i++ {
+		time.Sleep(time.Duration(5*i) * time.Second)
+
+		if err = CheckDependencies(config); err == nil {
+			dependencyEmitter.emit(fmt.Sprintf("Dependencies for deployment %s are ready", config.Name))
+			return nil
+		}
+
+		logger.Debugf("Waiting on dependencies for deployment %s, %v", config.Name, err)
+		dependencyEmitter.emit(fmt.Sprintf("Waiting on dependencies, %v", err))
+	}
+
+	return fmt.Errorf("dependencies for deployment %s are not ready, %v", config.Name, err)
+}
+
+// enqueueAfterDependencies queues a deployment job once the dependencies of the deployment are ready. The wait
+// happens before the job is queued so no worker is held while waiting, when dependencies are still not ready the
+// job is queued anyway and fails its own dependency check.
+func enqueueAfterDependencies(config Config, j job.Job) error {
+	e := createEventEmitter(config.Name, j.ID)
+	if err := waitForDependencies(config, e); err != nil {
+		logger.Warnf("queuing job %s for deployment %s with dependencies not ready, %v", j.ID, config.Name, err)
+
+		dependencyEmitter := *e
+		dependencyEmitter.Phase = DependenciesPhase
+		dependencyEmitter.emit(fmt.Sprintf("%v, the job will fail its dependency check", err))
+	}
+	return enqueue(j)
+}
@@ -0,0 +1,80 @@
+package deployment
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnmarshalDependencies(t *testing.T) {
+	var config Config
+	err := json.Unmarshal([]byte(`{
+		"name": "api",
+		"image": "biensupernice/api",
+		"depends_on": ["redis", {"deployment": "postgres", "condition": "healthy"}]
+	}`), &config)
+	assert.Nil(t, err)
+
+	config.applyDefaults()
+	assert.Nil(t, config.isValid())
+	assert.Equal(t, []DependencyConfig{
+		{Deployment: "redis", Condition: StartedDependency},
+		{Deployment: "postgres", Condition: HealthyDependency},
+	}, config.DependsOn)
+
+	err = json.Unmarshal([]byte(`{"name": "api", "depends_on": [1]}`), &config)
+	assert.Error(t, err)
+}
+
+func TestInvalidDependencies(t *testing.T) {
+	config := Config{Name: "api", Image: "biensupernice/api"}
+
+	config.DependsOn = []DependencyConfig{{Deployment: "api", Condition: StartedDependency}}
+	assert.Error(t, config.isValid())
+
+	config.DependsOn = []DependencyConfig{{Deployment: "Redis!", Condition: StartedDependency}}
+	assert.Error(t, config.isValid())
+
+	config.DependsOn = []DependencyConfig{{Deployment: "redis", Condition: "ready"}}
+	assert.Error(t, config.isValid())
+
+	config.DependsOn = []DependencyConfig{
+		{Deployment: "redis", Condition: StartedDependency},
+		{Deployment: "redis", Condition: HealthyDependency},
+	}
+	assert.Error(t, config.isValid())
+}
+
+func TestValidateDependencyCycles(t *testing.T) {
+	others := []Config{
+		{Name: "redis"},
+		{Name: "worker", DependsOn: []DependencyConfig{{Deployment: "api"}}},
+		{Name: "api", DependsOn: []DependencyConfig{{Deployment: "redis"}}},
+	}
+
+	// api -> redis, worker -> api
+	assert.Nil(t, validateDependencyCycles(Config{Name: "web", DependsOn: []DependencyConfig{{Deployment: "api"}, {Deployment: "worker"}}}, others))
+
+	// redis -> worker -> api -> redis
+	err := validateDependencyCycles(Config{Name: "redis", DependsOn: []DependencyConfig{{Deployment: "worker"}}}, others)
+	assert.EqualError(t, err, "dependency cycle redis -> worker -> api -> redis")
+
+	// the saved configuration replaces the stored one, api no longer depends on redis
+	assert.Nil(t, validateDependencyCycles(Config{Name: "api"}, append(others, Config{Name: "redis", DependsOn: []DependencyConfig{{Deployment: "worker"}}})))
+}
+
+func TestSortByDependencies(t *testing.T) {
+	configs := []Config{
+		{Name: "web", DependsOn: []DependencyConfig{{Deployment: "api"}}},
+		{Name: "api", DependsOn: []DependencyConfig{{Deployment: "redis"}, {Deployment: "postgres"}}},
+		{Name: "postgres"},
+		{Name: "redis", DependsOn: []DependencyConfig{{Deployment: "external"}}},
+	}
+
+	names := make([]string, 0)
+	for _, config := range SortByDependencies(configs) {
+		names = append(names, config.Name)
+	}
+	assert.Equal(t, []string{"postgres", "redis", "api", "web"}, names)
+}
@@ -85,6 +85,26 @@ func Run(deployment string) error {
 		return err
 	}
 
+	go enqueueAfterDependencies(config, newDeploymentJob(config))
+	return nil
+}
+
+// newDeploymentJob returns the job running a deployment configuration according to its strategy
+func newDeploymentJob(config Config) job.Job {
+	// new versions of deployments rolled out as a canary run next to the current containers
+	if config.Canary != nil && (HasCanary(config.Name) || isNewCanaryVersion(config)) {
+		return newCanaryJob(config)
+	}
+
+	if config.Strategy == BlueGreenStrategy {
+		return newBlueGreenJob(config)
+	}
+
+	return newRunJob(config)
+}
+
+// newRunJob returns the job creating or re-creating container resources for a deployment configuration
+func newRunJob(config Config) job.Job {
 	type RunDeploymentJobArgs struct {
 		Config             Config
 		ContainersToRemove []KraneContainer
@@ -92,7 +112,7 @@ func Run(deployment string) error {
 
 	jobID := uuid.Generate().String()
 	e := createEventEmitter(config.Name, jobID)
-	go enqueue(job.Job{
+	return job.Job{
 		ID:          jobID,
 		Deployment:  config.Name,
 		Type:        string(RunDeploymentJobType),
@@ -133,45 +153,89 @@ func Run(deployment string) error {
 			jobArgs := args.(*RunDeploymentJobArgs)
 			config := jobArgs.Config
 
-			// pull image
-			logger.Debugf("Pulling image for deployment %s", config.Name)
-			pullImageReader, err := docker.GetClient().PullImage(config.Registry, config.Image, config.Tag)
-			if err != nil {
-				logger.Errorf("unable to pull image %v", err)
+			if err := prepareContainers(config, e); err != nil {
+				return err
+			}
+
+			// pre-deploy hook, a failing hook leaves the current containers untouched
+			if err := runHook(config, config.Hooks.PreDeploy, PreDeployHookPhase, e); err != nil {
+				logger.Errorf("pre-deploy hook failed %v", err)
 				return err
 			}
-			e.emitStream(pullImageReader)
 
-			// create containers
+			// create containers, from here on a failure removes the new containers and restarts the current containers
 			containersCreated := make([]KraneContainer, 0)
 			for i := 0; i < config.Scale; i++ {
-				c, err := ContainerCreate(config)
+				c, err := ContainerCreate(config, i)
 				if err != nil {
 					logger.Errorf("unable to create container %v", err)
+					rollbackContainers(containersCreated, jobArgs.ContainersToRemove)
 					return err
 				}
 				containersCreated = append(containersCreated, c)
 			}
 			logger.Debugf("%d/%d container(s) for deployment %s created", config.Scale, len(containersCreated), config.Name)
 
-			// start containers
-			containersStarted := make([]KraneContainer, 0)
-			for _, c := range containersCreated {
-				if err := c.Start(); err != nil {
-					logger.Errorf("unable to start container %v", err)
-					return err
-				}
-				containersStarted = append(containersStarted, c)
+			// start containers, containers being replaced release their host ports to the new containers one slot at a time
+			retries := 10
+			containersStarted, err := startContainersBySlot(containersCreated, jobArgs.ContainersToRemove, retries)
+			if err != nil {
+				logger.Errorf("unable to start container %v", err)
+				rollbackContainers(containersCreated, jobArgs.ContainersToRemove)
+				return err
 			}
 			logger.Debugf("%d/%d container(s) for deployment %s started", len(containersStarted), len(containersCreated), config.Name)
 
 			// health check
-			retries := 10
 			if err := RetriableContainersHealthCheck(containersStarted, retries); err != nil {
 				logger.Errorf("containers did not pass health check %v", err)
+				rollbackContainers(containersCreated, jobArgs.ContainersToRemove)
 				return err
 			}
 			logger.Debugf("Deployment %s health check complete", config.Name)
+
+			// post-deploy hook, when the hook fails the new containers are removed and the current containers are kept
+			if err := runHook(config, config.Hooks.PostDeploy, PostDeployHookPhase, e); err != nil {
+				logger.Errorf("post-deploy hook failed %v", err)
+				rollbackContainers(containersCreated, jobArgs.ContainersToRemove)
+				return err
+			}
+
+			// the new containers replace the canary (if any), route all traffic back to the deployment
+			if err := clearCanary(config.Name); err != nil {
+				logger.Errorf("unable to clear canary %v", err)
+				rollbackContainers(containersCreated, jobArgs.ContainersToRemove)
+				return err
+			}
+
+			// the new containers replace the colors of a blue/green deployment (if any)
+			if err := clearBlueGreen(config.Name); err != nil {
+				logger.Errorf("unable to clear colors %v", err)
+				rollbackContainers(containersCreated, jobArgs.ContainersToRemove)
+				return err
+			}
+
+			// the next new version of a deployment rolled out as a canary is compared to the new containers
+			if err := saveDeployedConfig(config); err != nil {
+				logger.Errorf("unable to record deployed configuration %v", err)
+				rollbackContainers(containersCreated, jobArgs.ContainersToRemove)
+				return err
+			}
+
+			// a successful run ends the maintenance of the deployment (if any)
+			if err := clearMaintenance(config.Name); err != nil {
+				logger.Errorf("unable to clear maintenance %v", err)
+				rollbackContainers(containersCreated, jobArgs.ContainersToRemove)
+				return err
+			}
+
+			// route requests to the new containers on proxies configured with files
+			if err := SyncProxy(); err != nil {
+				logger.Errorf("unable to write proxy configuration %v", err)
+				rollbackContainers(containersCreated, jobArgs.ContainersToRemove)
+				return err
+			}
+
 			return nil
 		},
 		Finally: func(args interface{}) error {
@@ -186,27 +250,88 @@ func Run(deployment string) error {
 				}
 			}
 
+			// release host ports no longer used by the deployment
+			if err := releaseUnusedPortAllocations(jobArgs.Config); err != nil {
+				logger.Errorf("unable to release port allocations %v", err)
+				return err
+			}
+
 			return nil
 		},
-	})
+	}
+}
+
+// prepareContainers pulls the images and creates the volumes the containers of a deployment are created from,
+// then checks the dependencies and basic auth secrets are ready. Jobs call it before touching any container.
+func prepareContainers(config Config, e *EventEmitter) error {
+	// pull image
+	logger.Debugf("Pulling image for deployment %s", config.Name)
+	pullImageReader, err := docker.GetClient().PullImage(config.Registry, config.Image, config.Tag)
+	if err != nil {
+		logger.Errorf("unable to pull image %v", err)
+		return err
+	}
+	e.emitStream(pullImageReader)
+
+	// pull sidecar images
+	if err := PullSidecarImages(config, e); err != nil {
+		logger.Errorf("unable to pull sidecar image %v", err)
+		return err
+	}
+
+	// ensure named volumes
+	if err := EnsureVolumes(config); err != nil {
+		logger.Errorf("unable to create volumes %v", err)
+		return err
+	}
+
+	// jobs are queued once dependencies are ready, fail if they are no longer ready
+	if err := CheckDependencies(config); err != nil {
+		logger.Errorf("dependencies not ready %v", err)
+		return err
+	}
+
+	// containers are never created without the basic auth users protecting them
+	if err := validateBasicAuthSecrets(config); err != nil {
+		logger.Errorf("basic auth not ready %v", err)
+		return err
+	}
 
 	return nil
 }
 
 // Delete removes a deployments container resources and configuration.
 // Note: This will also remove any existing collections created for the deployment (Secrets, Jobs, Config etc...)
-func Delete(deployment string) error {
+// Named volumes are kept unless removeVolumes is true, in which case volumes not shared with other deployments are removed.
+func Delete(deployment string, removeVolumes bool) error {
+	config, err := GetDeploymentConfig(deployment)
+	if err != nil {
+		return fmt.Errorf("unable to get configuration for deployment %s", deployment)
+	}
+
+	go enqueue(newDeleteJob(config, removeVolumes))
+	return nil
+}
+
+// newDeleteJob returns the job removing a deployments container resources and configuration
+func newDeleteJob(config Config, removeVolumes bool) job.Job {
+	deployment := config.Name
+
 	type DeleteDeploymentJobArgs struct {
-		Deployment string
+		Deployment    string
+		Config        Config
+		RemoveVolumes bool
 	}
 
-	go enqueue(job.Job{
+	return job.Job{
 		ID:          uuid.Generate().String(),
 		Deployment:  deployment,
 		Type:        string(DeleteDeploymentJobType),
 		RetryPolicy: utils.UIntEnv(constants.EnvDeploymentRetryPolicy),
 		Args: DeleteDeploymentJobArgs{
-			Deployment: deployment,
+			Deployment:    deployment,
+			Config:        config,
+			RemoveVolumes: removeVolumes,
 		},
 		Run: func(args interface{}) error {
 			jobArgs := args.(DeleteDeploymentJobArgs)
@@ -228,6 +353,14 @@ func Delete(deployment string) error {
 			}
 			logger.Debugf("%d container(s) for deployment %s removed", len(containers), deploymentName)
 
+			// remove named volumes only when explicitly requested
+			if jobArgs.RemoveVolumes {
+				if err := removeDeploymentVolumes(jobArgs.Config); err != nil {
+					logger.Errorf("unable to remove volumes %v", err)
+					return err
+				}
+			}
+
 			return nil
 		},
 		Finally: func(args interface{}) error {
@@ -241,6 +374,27 @@ func Delete(deployment string) error {
 				return err
 			}
 
+			// delete canary
+			logger.Debugf("removing canary for deployment %s", deploymentName)
+			if err := clearCanary(deploymentName); err != nil {
+				logger.Errorf("unable to remove canary %v", err)
+				return err
+			}
+
+			// delete blue/green colors
+			logger.Debugf("removing colors for deployment %s", deploymentName)
+			if err := clearBlueGreen(deploymentName); err != nil {
+				logger.Errorf("unable to remove colors %v", err)
+				return err
+			}
+
+			// delete basic auth credentials
+			logger.Debugf("removing basic auth credentials for deployment %s", deploymentName)
+			if err := DeleteCredentials(deploymentName); err != nil {
+				logger.Errorf("unable to remove basic auth credentials %v", err)
+				return err
+			}
+
 			// delete jobs collection
 			logger.Debugf("removing jobs collection for deployment %s", deploymentName)
 			if err := DeleteJobsCollection(deploymentName); err != nil {
@@ -248,6 +402,13 @@ func Delete(deployment string) error {
 				return err
 			}
 
+			// release host port allocations
+			logger.Debugf("releasing port allocations for deployment %s", deploymentName)
+			if err := ReleasePortAllocations(deploymentName); err != nil {
+				logger.Errorf("unable to release port allocations %v", err)
+				return err
+			}
+
 			// delete deployment configuration
 			logger.Debugf("removing config for deployment %s", deploymentName)
 			if err := DeleteConfig(deploymentName); err != nil {
@@ -255,21 +416,43 @@ func Delete(deployment string) error {
 				return err
 			}
 
+			if err := deleteDeployedConfig(deploymentName); err != nil {
+				logger.Errorf("unable to remove deployed configuration %v", err)
+				return err
+			}
+
+			// delete maintenance
+			logger.Debugf("removing maintenance for deployment %s", deploymentName)
+			if err := clearMaintenance(deploymentName); err != nil {
+				logger.Errorf("unable to remove maintenance %v", err)
+				return err
+			}
+
+			// stop routing requests to the deployment on proxies configured with files
+			logger.Debugf("removing proxy configuration for deployment %s", deploymentName)
+			if err := SyncProxy(); err != nil {
+				logger.Errorf("unable to write proxy configuration %v", err)
+				return err
+			}
+
 			return nil
 		},
-	})
-
-	return nil
+	}
 }
 
 // StartContainers starts current existing containers (if any) for a deployment
 // Note: this does not re-create container resources, only start existing ones
 func StartContainers(deployment string) error {
+	config, err := GetDeploymentConfig(deployment)
+	if err != nil {
+		return fmt.Errorf("unable to get configuration for deployment %s", deployment)
+	}
+
 	type StartContainersJobArgs struct {
 		Deployment string
 	}
 
-	go enqueue(job.Job{
+	go enqueueAfterDependencies(config, job.Job{
 		ID:          uuid.Generate().String(),
 		Deployment:  deployment,
 		Type:        string(StartContainersJobType),
@@ -292,6 +475,12 @@ func StartContainers(deployment string) error {
 				return fmt.Errorf("deployment %s has 0 containers to start", deploymentName)
 			}
 
+			// jobs are queued once dependencies are ready, fail if they are no longer ready
+			if err := CheckDependencies(config); err != nil {
+				logger.Errorf("dependencies not ready %v", err)
+				return err
+			}
+
 			// start containers
 			for _, c := range containers {
 				logger.Debugf("Starting container %s", c.Name)
@@ -369,7 +558,7 @@ func RestartContainers(deployment string) error {
 
 	jobID := uuid.Generate().String()
 	e := createEventEmitter(config.Name, jobID)
-	go enqueue(job.Job{
+	go enqueueAfterDependencies(config, job.Job{
 		ID:          jobID,
 		Deployment:  deployment,
 		Type:        string(RestartContainersJobType),
@@ -396,41 +585,36 @@ func RestartContainers(deployment string) error {
 			jobArgs := args.(*RestartContainersJobArgs)
 			config := jobArgs.Config
 
-			// pull image
-			logger.Debugf("Pulling image for deployment %s", config.Name)
-			pullImageReader, err := docker.GetClient().PullImage(config.Registry, config.Image, config.Tag)
-			if err != nil {
-				logger.Errorf("unable to pull image %v", err)
+			if err := prepareContainers(config, e); err != nil {
 				return err
 			}
-			e.emitStream(pullImageReader)
 
-			// create containers
+			// create containers, from here on a failure removes the new containers and restarts the current containers
 			containersCreated := make([]KraneContainer, 0)
 			for i := 0; i < config.Scale; i++ {
-				c, err := ContainerCreate(config)
+				c, err := ContainerCreate(config, i)
 				if err != nil {
 					logger.Errorf("unable to create container %v", err)
+					rollbackContainers(containersCreated, jobArgs.ContainersToRemove)
 					return err
 				}
 				containersCreated = append(containersCreated, c)
 			}
 			logger.Debugf("%d/%d container(s) for deployment %s created", len(containersCreated), config.Scale, config.Name)
 
-			// start containers
-			containersStarted := make([]KraneContainer, 0)
-			for _, c := range containersCreated {
-				if err := c.Start(); err != nil {
-					logger.Errorf("unable to start container %v", err)
-					return err
-				}
-				containersStarted = append(containersStarted, c)
+			// start containers, containers being replaced release their host ports to the new containers one slot at a time
+			retries := 10
+			containersStarted, err := startContainersBySlot(containersCreated, jobArgs.ContainersToRemove, retries)
+			if err != nil {
+				logger.Errorf("unable to start container %v", err)
+				rollbackContainers(containersCreated, jobArgs.ContainersToRemove)
+				return err
 			}
 			logger.Debugf("%d/%d container(s) for deployment %s started", len(containersStarted), len(containersCreated), config.Name)
 
-			retries := 10
 			if err := RetriableContainersHealthCheck(containersStarted, retries); err != nil {
 				logger.Errorf("containers did not pass health check %v", err)
+				rollbackContainers(containersCreated, jobArgs.ContainersToRemove)
 				return err
 			}
 			logger.Debugf("Deployment %s health check complete", config.Name)
@@ -447,6 +631,12 @@ func RestartContainers(deployment string) error {
 				}
 			}
 
+			// release host ports no longer used by the deployment
+			if err := releaseUnusedPortAllocations(jobArgs.Config); err != nil {
+				logger.Errorf("unable to release port allocations %v", err)
+				return err
+			}
+
 			return nil
 		},
 	})
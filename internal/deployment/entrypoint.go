@@ -0,0 +1,184 @@
+package deployment
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/krane/krane/internal/proxy"
+)
+
+// TCPRouteConfig represents raw TCP connections received on a proxy entrypoint forwarded to the containers,
+// for databases or other services which don't speak HTTP
+type TCPRouteConfig struct {
+	EntryPoint string   `json:"entrypoint"` // proxy entrypoint receiving the connections (see PROXY_ENTRYPOINTS)
+	Port       string   `json:"port"`       // container port connections are forwarded to
+	Hosts      []string `json:"hosts"`      // server names (SNI) matched, requires tls (default any host)
+	TLS        string   `json:"tls"`        // terminate, passthrough or empty for unencrypted connections
+}
+
+// UDPRouteConfig represents UDP datagrams received on a proxy entrypoint forwarded to the containers
+type UDPRouteConfig struct {
+	EntryPoint string `json:"entrypoint"` // proxy entrypoint receiving the datagrams (see PROXY_ENTRYPOINTS)
+	Port       string `json:"port"`       // container port datagrams are forwarded to
+}
+
+// applyDefaults applies default TCP route configuration values
+func (route *TCPRouteConfig) applyDefaults() {
+	route.EntryPoint = strings.ToLower(route.EntryPoint)
+	route.TLS = strings.ToLower(route.TLS)
+
+	if route.Hosts == nil {
+		route.Hosts = make([]string, 0)
+	}
+
+	for i := range route.Hosts {
+		route.Hosts[i] = strings.ToLower(route.Hosts[i])
+	}
+}
+
+// isValid returns an error if a TCP route configuration is not valid
+func (route TCPRouteConfig) isValid() error {
+	if err := isValidEntryPoint(route.EntryPoint, "tcp"); err != nil {
+		return err
+	}
+
+	if !isValidPortNumber(route.Port) {
+		return fmt.Errorf("invalid port %s for tcp entrypoint %s", route.Port, route.EntryPoint)
+	}
+
+	switch route.TLS {
+	case proxy.TCPTLSNone, proxy.TCPTLSTerminate, proxy.TCPTLSPassthrough:
+	default:
+		return fmt.Errorf("invalid tls %s for tcp entrypoint %s, must be terminate or passthrough", route.TLS, route.EntryPoint)
+	}
+
+	// the server name is only sent with TLS, unencrypted connections can't be matched by host
+	if len(route.Hosts) > 0 && route.TLS == proxy.TCPTLSNone {
+		return fmt.Errorf("tcp entrypoint %s matches hosts but has no tls", route.EntryPoint)
+	}
+
+	for _, host := range route.Hosts {
+		if host == "" || strings.ContainsAny(host, "`/, ") {
+			return fmt.Errorf("invalid host %s for tcp entrypoint %s", host, route.EntryPoint)
+		}
+	}
+
+	return nil
+}
+
+// isValid returns an error if a UDP route configuration is not valid
+func (route UDPRouteConfig) isValid() error {
+	if err := isValidEntryPoint(route.EntryPoint, "udp"); err != nil {
+		return err
+	}
+
+	if !isValidPortNumber(route.Port) {
+		return fmt.Errorf("invalid port %s for udp entrypoint %s", route.Port, route.EntryPoint)
+	}
+
+	return nil
+}
+
+// isValidEntryPoint returns an error if an entrypoint is not exposed by the proxy for a protocol
+func isValidEntryPoint(name string, protocol string) error {
+	if name == "" {
+		return fmt.Errorf("%s entrypoint required", protocol)
+	}
+
+	ep, err := proxy.GetEntryPoint(name)
+	if err != nil {
+		return err
+	}
+
+	if ep.Protocol != protocol {
+		return fmt.Errorf("entrypoint %s is a %s entrypoint", name, ep.Protocol)
+	}
+	return nil
+}
+
+// proxyTCPRoutes returns the proxy TCP routes of a deployment
+func (config Config) proxyTCPRoutes() []proxy.TCPRoute {
+	routes := make([]proxy.TCPRoute, 0)
+	for _, route := range config.TCP {
+		routes = append(routes, proxy.TCPRoute{
+			EntryPoint: route.EntryPoint,
+			Port:       route.Port,
+			Hosts:      route.Hosts,
+			TLS:        route.TLS,
+		})
+	}
+	return routes
+}
+
+// proxyUDPRoutes returns the proxy UDP routes of a deployment
+func (config Config) proxyUDPRoutes() []proxy.UDPRoute {
+	routes := make([]proxy.UDPRoute, 0)
+	for _, route := range config.UDP {
+		routes = append(routes, proxy.UDPRoute{EntryPoint: route.EntryPoint, Port: route.Port})
+	}
+	return routes
+}
+
+// claimedEntryPoints returns the entrypoints and hosts a deployment receives connections for,
+// entrypoints matching any host (and UDP entrypoints) claim the whole entrypoint as *
+func (config Config) claimedEntryPoints() map[string][]string {
+	claimed := make(map[string][]string, 0)
+	for _, route := range config.TCP {
+		if len(route.Hosts) == 0 {
+			claimed[route.EntryPoint] = append(claimed[route.EntryPoint], "*")
+			continue
+		}
+		claimed[route.EntryPoint] = append(claimed[route.EntryPoint], route.Hosts...)
+	}
+
+	for _, route := range config.UDP {
+		claimed[route.EntryPoint] = append(claimed[route.EntryPoint], "*")
+	}
+	return claimed
+}
+
+// validateEntryPointConflicts returns an error if a deployment claims an entrypoint host more than once
+// or already claimed by another deployment. Claiming a whole entrypoint conflicts with any other claim.
+func validateEntryPointConflicts(config Config, others []Config) error {
+	claimed := config.claimedEntryPoints()
+	for entryPoint, hosts := range claimed {
+		if err := conflictingHosts(hosts, hosts, true); err != nil {
+			return fmt.Errorf("entrypoint %s %v is declared more than once", entryPoint, err)
+		}
+	}
+
+	for _, d := range others {
+		if d.Name == config.Name {
+			continue
+		}
+
+		for entryPoint, otherHosts := range d.claimedEntryPoints() {
+			if err := conflictingHosts(claimed[entryPoint], otherHosts, false); err != nil {
+				return fmt.Errorf("entrypoint %s %v is already claimed by deployment %s", entryPoint, err, d.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// conflictingHosts returns an error naming the first host claimed by both lists, * conflicts with any host.
+// When both lists are the same list, a host only conflicts with another occurrence of itself.
+func conflictingHosts(a []string, b []string, same bool) error {
+	for i, host := range a {
+		for j, other := range b {
+			if same && i == j {
+				continue
+			}
+
+			if host == other || host == "*" || other == "*" {
+				if host == "*" {
+					return errors.New("for any host")
+				}
+				return fmt.Errorf("for host %s", host)
+			}
+		}
+	}
+	return nil
+}
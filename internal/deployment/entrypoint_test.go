@@ -0,0 +1,70 @@
+package deployment
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/krane/krane/internal/constants"
+)
+
+func TestTCPAndUDPRoutes(t *testing.T) {
+	_ = os.Setenv(constants.EnvProxyEntryPoints, "postgres:5432,dns:53/udp")
+	defer os.Unsetenv(constants.EnvProxyEntryPoints)
+
+	config := storedConfig(Config{
+		Name:  "db",
+		Image: "postgres",
+		TCP:   []TCPRouteConfig{{EntryPoint: "Postgres", Port: "5432", Hosts: []string{"DB.example.com"}, TLS: "passthrough"}},
+		UDP:   []UDPRouteConfig{{EntryPoint: "dns", Port: "53"}},
+	})
+	assert.Nil(t, config.isValid())
+	assert.Equal(t, "postgres", config.TCP[0].EntryPoint)
+	assert.Equal(t, []string{"db.example.com"}, config.TCP[0].Hosts)
+
+	config.ApplyProxyLabels()
+	assert.Equal(t, "HostSNI(`db.example.com`)", config.Labels["traefik.tcp.routers.db-tcp-0.rule"])
+	assert.Equal(t, "dns", config.Labels["traefik.udp.routers.db-udp-0.entrypoints"])
+
+	invalid := []Config{
+		{Name: "db", Image: "postgres", TCP: []TCPRouteConfig{{EntryPoint: "mysql", Port: "3306"}}},
+		{Name: "db", Image: "postgres", TCP: []TCPRouteConfig{{EntryPoint: "dns", Port: "53"}}},
+		{Name: "db", Image: "postgres", TCP: []TCPRouteConfig{{EntryPoint: "postgres", Port: "pg"}}},
+		{Name: "db", Image: "postgres", TCP: []TCPRouteConfig{{EntryPoint: "postgres", Port: "5432", TLS: "mutual"}}},
+		{Name: "db", Image: "postgres", TCP: []TCPRouteConfig{{EntryPoint: "postgres", Port: "5432", Hosts: []string{"db.example.com"}}}},
+		{Name: "db", Image: "postgres", UDP: []UDPRouteConfig{{EntryPoint: "postgres", Port: "5432"}}},
+	}
+
+	for _, c := range invalid {
+		assert.Error(t, storedConfig(c).isValid())
+	}
+}
+
+func TestValidateEntryPointConflicts(t *testing.T) {
+	passthrough := func(hosts ...string) TCPRouteConfig {
+		return TCPRouteConfig{EntryPoint: "postgres", Port: "5432", Hosts: hosts, TLS: "passthrough"}
+	}
+
+	config := Config{Name: "db", TCP: []TCPRouteConfig{passthrough("db.example.com")}}
+	others := []Config{
+		{Name: "db", TCP: []TCPRouteConfig{passthrough("db.example.com")}},
+		{Name: "analytics", TCP: []TCPRouteConfig{passthrough("analytics.example.com")}},
+		{Name: "dns", UDP: []UDPRouteConfig{{EntryPoint: "dns", Port: "53"}}},
+	}
+	assert.Nil(t, validateEntryPointConflicts(config, others))
+
+	// the same host can't be routed to two deployments
+	others = append(others, Config{Name: "replica", TCP: []TCPRouteConfig{passthrough("db.example.com")}})
+	assert.Error(t, validateEntryPointConflicts(config, others))
+
+	// an entrypoint matching any host can't be shared
+	any := Config{Name: "redis", TCP: []TCPRouteConfig{{EntryPoint: "postgres", Port: "6379"}}}
+	assert.Error(t, validateEntryPointConflicts(any, others[:3]))
+
+	duplicate := Config{Name: "db", TCP: []TCPRouteConfig{passthrough("db.example.com"), passthrough("db.example.com")}}
+	assert.Error(t, validateEntryPointConflicts(duplicate, []Config{}))
+
+	udp := Config{Name: "resolver", UDP: []UDPRouteConfig{{EntryPoint: "dns", Port: "53"}}}
+	assert.Error(t, validateEntryPointConflicts(udp, others))
+}
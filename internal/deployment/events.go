@@ -15,6 +15,10 @@ type EventEmitter struct {
 	JobID      string
 	Phase      Phase
 	Clients    []*websocket.Conn
+
+	// publish is called with each event, in the order events are emitted, to reach
+	// subscribers other than the deployment clients (ie. clients subscribed to a task)
+	publish func(data []byte)
 }
 
 type Event struct {
@@ -37,6 +41,15 @@ func createEventEmitter(deployment string, jobID string) *EventEmitter {
 // In order to allow clients to filter events for specific deployment runs, the job id
 // was added into the event payload, the job id is returned when triggering a deployment run.
 func (e EventEmitter) emit(message string) {
+	if e.publish != nil {
+		data, _ := json.Marshal(Event{
+			JobID:   e.JobID,
+			Message: message,
+			Phase:   e.Phase,
+		})
+		e.publish(data)
+	}
+
 	go func(clients []*websocket.Conn, jobID string, deployment string, phase Phase) {
 		for _, client := range clients {
 			bytes, _ := json.Marshal(Event{
@@ -71,6 +84,10 @@ func (e EventEmitter) emitStream(reader io.Reader) {
 			Message: string(bytes),
 			Phase:   e.Phase,
 		})
+		if e.publish != nil {
+			e.publish(data)
+		}
+
 		for _, client := range e.Clients {
 			if err := client.WriteMessage(websocket.TextMessage, data); err != nil {
 				// this will log when a client has disconnected at which point the
@@ -0,0 +1,394 @@
+package deployment
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/krane/krane/internal/constants"
+	"github.com/krane/krane/internal/logger"
+	"github.com/krane/krane/internal/session"
+	"github.com/krane/krane/internal/store"
+)
+
+// ExportVersion is the version of the export format
+const ExportVersion = "1"
+
+// exportKeyLabel is the RSA-OAEP label used when encrypting the export key to a recipient
+var exportKeyLabel = []byte("krane-export")
+
+// recipientKeyName is the store key of the server recipient key used to decrypt imported secrets
+const recipientKeyName = "export-recipient"
+
+var recipientKeyMu sync.Mutex
+
+// Export represents a portable bundle of deployments moved between Krane servers
+type Export struct {
+	Metadata    ExportMetadata       `json:"metadata"`
+	Deployments []ExportedDeployment `json:"deployments"`
+
+	// EncryptedKey is the key encrypting secret values, itself encrypted to the recipient key (base64)
+	EncryptedKey string `json:"encrypted_key,omitempty"`
+}
+
+// ExportMetadata describes where and when an export was created
+type ExportMetadata struct {
+	Version   string `json:"version"`
+	Source    string `json:"source"`                          // hostname of the exporting server
+	CreatedAt int64  `json:"created_at_epoch"`                // epoch in seconds since 1970
+	Recipient string `json:"recipient_fingerprint,omitempty"` // sha256 fingerprint of the recipient key secret values are encrypted to
+}
+
+// ExportedDeployment represents a deployment configuration and its secrets
+type ExportedDeployment struct {
+	Config  Config           `json:"config"`
+	Secrets []ExportedSecret `json:"secrets"`
+}
+
+// ExportedSecret represents a deployment secret, the value is only included when encrypted to a recipient
+type ExportedSecret struct {
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"` // AES-GCM nonce and ciphertext (base64)
+}
+
+// ImportResult represents the outcome of importing an export
+type ImportResult struct {
+	Imported       []string          `json:"imported"`        // deployments saved
+	Conflicts      []string          `json:"conflicts"`       // deployments skipped because a deployment with the same name exists
+	Failed         map[string]string `json:"failed"`          // deployments which could not be saved with the reason
+	MissingSecrets []string          `json:"missing_secrets"` // secrets exported without a value which must be set (deployment/key)
+}
+
+// ExportDeployments exports every deployment configuration and secret names. When a recipient key
+// (PEM or ssh-rsa public key) is provided, secret values are included encrypted to the recipient.
+func ExportDeployments(recipientKey string) (Export, error) {
+	configs, err := GetAllDeploymentConfigs()
+	if err != nil {
+		return Export{}, err
+	}
+
+	hostname, _ := os.Hostname()
+	export := Export{
+		Metadata: ExportMetadata{
+			Version:   ExportVersion,
+			Source:    hostname,
+			CreatedAt: time.Now().Unix(),
+		},
+		Deployments: make([]ExportedDeployment, 0),
+	}
+
+	var gcm cipher.AEAD
+	if recipientKey != "" {
+		recipient, err := parseRecipientKey(recipientKey)
+		if err != nil {
+			return Export{}, err
+		}
+
+		key := make([]byte, 32)
+		if _, err := io.ReadFull(rand.Reader, key); err != nil {
+			return Export{}, err
+		}
+
+		encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, recipient, key, exportKeyLabel)
+		if err != nil {
+			return Export{}, fmt.Errorf("unable to encrypt export key, %v", err)
+		}
+
+		if gcm, err = newGCM(key); err != nil {
+			return Export{}, err
+		}
+
+		export.EncryptedKey = base64.StdEncoding.EncodeToString(encryptedKey)
+		export.Metadata.Recipient = keyFingerprint(recipient)
+	}
+
+	for _, config := range configs {
+		// internal deployments (ie. krane-proxy) are managed by each server
+		if config.Internal {
+			continue
+		}
+
+		secrets, err := GetAllSecrets(config.Name)
+		if err != nil {
+			return Export{}, err
+		}
+
+		exported := ExportedDeployment{Config: config, Secrets: make([]ExportedSecret, 0)}
+		for _, secret := range secrets {
+			s := ExportedSecret{Key: secret.Key}
+			if gcm != nil {
+				if s.Value, err = seal(gcm, secret.Value, secretAAD(config.Name, secret.Key)); err != nil {
+					return Export{}, err
+				}
+			}
+			exported.Secrets = append(exported.Secrets, s)
+		}
+
+		export.Deployments = append(export.Deployments, exported)
+	}
+
+	return export, nil
+}
+
+// ImportDeployments recreates the deployments of an export. Deployments with the name of an existing deployment
+// are reported as conflicts unless overwrite is true. Encrypted secret values are decrypted with the server recipient key.
+func ImportDeployments(export Export, overwrite bool) (ImportResult, error) {
+	if export.Metadata.Version != ExportVersion {
+		return ImportResult{}, fmt.Errorf("unsupported export version %s", export.Metadata.Version)
+	}
+
+	var gcm cipher.AEAD
+	if export.EncryptedKey != "" {
+		var err error
+		if gcm, err = openExportKey(export); err != nil {
+			return ImportResult{}, err
+		}
+	}
+
+	result := ImportResult{
+		Imported:       make([]string, 0),
+		Conflicts:      make([]string, 0),
+		Failed:         make(map[string]string, 0),
+		MissingSecrets: make([]string, 0),
+	}
+
+	for _, exported := range export.Deployments {
+		config := exported.Config
+		if Exist(config.Name) && !overwrite {
+			result.Conflicts = append(result.Conflicts, config.Name)
+			continue
+		}
+
+		// secrets are decrypted and saved before the configuration, which may reference them
+		secrets, missing, err := decryptSecrets(config.Name, exported.Secrets, gcm)
+		if err != nil {
+			result.Failed[config.Name] = err.Error()
+			continue
+		}
+
+		restore, err := importSecrets(config.Name, secrets)
+		if err != nil {
+			restore()
+			result.Failed[config.Name] = err.Error()
+			continue
+		}
+
+		if err := SaveConfig(config); err != nil {
+			restore()
+			result.Failed[config.Name] = err.Error()
+			continue
+		}
+
+		result.Imported = append(result.Imported, config.Name)
+		result.MissingSecrets = append(result.MissingSecrets, missing...)
+	}
+
+	sort.Strings(result.MissingSecrets)
+	return result, nil
+}
+
+// decryptSecrets decrypts the secrets of an imported deployment, secrets without a value are returned as missing
+func decryptSecrets(deployment string, secrets []ExportedSecret, gcm cipher.AEAD) (map[string]string, []string, error) {
+	decrypted := make(map[string]string, 0)
+	missing := make([]string, 0)
+	for _, secret := range secrets {
+		if secret.Value == "" || gcm == nil {
+			missing = append(missing, fmt.Sprintf("%s/%s", deployment, secret.Key))
+			continue
+		}
+
+		value, err := open(gcm, secret.Value, secretAAD(deployment, secret.Key))
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to decrypt secret %s, %v", secret.Key, err)
+		}
+		decrypted[secret.Key] = value
+	}
+	return decrypted, missing, nil
+}
+
+// importSecrets saves the decrypted secrets of an imported deployment. The returned function
+// restores the secrets as they were before the import, for when the import fails
+func importSecrets(deployment string, secrets map[string]string) (func(), error) {
+	previous := make(map[string]*Secret, 0)
+	restore := func() {
+		for key, secret := range previous {
+			if secret == nil {
+				_ = DeleteSecret(deployment, key)
+				continue
+			}
+			_, _ = AddSecret(deployment, key, secret.Value)
+		}
+	}
+
+	for key, value := range secrets {
+		secret, err := GetSecret(deployment, key)
+		if err != nil {
+			secret = nil
+		}
+		previous[key] = secret
+
+		if _, err := AddSecret(deployment, key, value); err != nil {
+			return restore, err
+		}
+	}
+	return restore, nil
+}
+
+// openExportKey decrypts the key encrypting the secret values of an export with the server recipient key
+func openExportKey(export Export) (cipher.AEAD, error) {
+	privateKey, err := getRecipientKey()
+	if err != nil {
+		return nil, err
+	}
+
+	if export.Metadata.Recipient != keyFingerprint(&privateKey.PublicKey) {
+		return nil, errors.New("export secrets are encrypted to another recipient key")
+	}
+
+	encryptedKey, err := base64.StdEncoding.DecodeString(export.EncryptedKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid export key, %v", err)
+	}
+
+	key, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, privateKey, encryptedKey, exportKeyLabel)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt export key, %v", err)
+	}
+
+	return newGCM(key)
+}
+
+// GetRecipientPublicKey returns the PEM encoded public key exports are encrypted to when moving deployments to this server
+func GetRecipientPublicKey() (string, error) {
+	privateKey, err := getRecipientKey()
+	if err != nil {
+		return "", err
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		return "", err
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+// getRecipientKey returns the server recipient key, the key is generated the first time it is used
+func getRecipientKey() (*rsa.PrivateKey, error) {
+	recipientKeyMu.Lock()
+	defer recipientKeyMu.Unlock()
+
+	bytes, err := store.Client().Get(constants.KeysCollectionName, recipientKeyName)
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes != nil {
+		block, _ := pem.Decode(bytes)
+		if block == nil {
+			return nil, errors.New("invalid recipient key")
+		}
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+
+	logger.Info("Generating recipient key for importing deployments")
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
+	if err := store.Client().Put(constants.KeysCollectionName, recipientKeyName, encoded); err != nil {
+		return nil, err
+	}
+
+	return privateKey, nil
+}
+
+// parseRecipientKey parses a PEM (PKIX or PKCS1) or ssh-rsa encoded RSA public key
+func parseRecipientKey(key string) (*rsa.PublicKey, error) {
+	key = strings.TrimSpace(key)
+	if strings.HasPrefix(key, "ssh-rsa") {
+		return session.DecodePublicKey(key)
+	}
+
+	block, _ := pem.Decode([]byte(key))
+	if block == nil {
+		return nil, errors.New("recipient key must be a PEM or ssh-rsa encoded RSA public key")
+	}
+
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("recipient key must be an RSA public key")
+	}
+	return publicKey, nil
+}
+
+// keyFingerprint returns the sha256 fingerprint of a public key
+func keyFingerprint(key *rsa.PublicKey) string {
+	sum := sha256.Sum256(x509.MarshalPKCS1PublicKey(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts a value returning the nonce and ciphertext encoded in base64
+func seal(gcm cipher.AEAD, value string, aad []byte) (string, error) {
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(value), aad)), nil
+}
+
+// open decrypts a value encrypted with seal
+func open(gcm cipher.AEAD, value string, aad []byte) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", err
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("ciphertext too short")
+	}
+
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], aad)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// secretAAD binds an encrypted secret value to its deployment and key
+func secretAAD(deployment, key string) []byte {
+	return []byte(fmt.Sprintf("%s/%s", deployment, key))
+}
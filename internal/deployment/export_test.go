@@ -0,0 +1,152 @@
+package deployment
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	config := Config{Name: "export-api", Image: "biensupernice/api", Secrets: map[string]string{"DB_PASSWORD": "@DB_PASSWORD"}}
+	assert.Nil(t, SaveConfig(config))
+	_, err := AddSecret("export-api", "DB_PASSWORD", "hunter2")
+	assert.Nil(t, err)
+
+	recipient, err := GetRecipientPublicKey()
+	assert.Nil(t, err)
+
+	export, err := ExportDeployments(recipient)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, export.EncryptedKey)
+	assert.Equal(t, ExportVersion, export.Metadata.Version)
+
+	var exported ExportedDeployment
+	for _, d := range export.Deployments {
+		if d.Config.Name == "export-api" {
+			exported = d
+		}
+	}
+	assert.Equal(t, "DB_PASSWORD", exported.Secrets[0].Key)
+	assert.NotContains(t, exported.Secrets[0].Value, "hunter2")
+
+	// existing deployments are reported as conflicts
+	result, err := ImportDeployments(Export{Metadata: export.Metadata, Deployments: []ExportedDeployment{exported}, EncryptedKey: export.EncryptedKey}, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"export-api"}, result.Conflicts)
+
+	assert.Nil(t, DeleteSecret("export-api", "DB_PASSWORD"))
+	assert.Nil(t, DeleteConfig("export-api"))
+
+	result, err = ImportDeployments(Export{Metadata: export.Metadata, Deployments: []ExportedDeployment{exported}, EncryptedKey: export.EncryptedKey}, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"export-api"}, result.Imported)
+	assert.Empty(t, result.MissingSecrets)
+
+	secret, err := GetSecret("export-api", "DB_PASSWORD")
+	assert.Nil(t, err)
+	assert.Equal(t, "hunter2", secret.Value)
+
+	assert.Nil(t, DeleteConfig("export-api"))
+}
+
+func TestImportInvalidConfigKeepsSecrets(t *testing.T) {
+	assert.Nil(t, SaveConfig(Config{Name: "export-worker", Image: "biensupernice/worker"}))
+	_, err := AddSecret("export-worker", "QUEUE_URL", "amqp://old")
+	assert.Nil(t, err)
+
+	recipient, err := GetRecipientPublicKey()
+	assert.Nil(t, err)
+
+	export, err := ExportDeployments(recipient)
+	assert.Nil(t, err)
+
+	var exported ExportedDeployment
+	for _, d := range export.Deployments {
+		if d.Config.Name == "export-worker" {
+			exported = d
+		}
+	}
+
+	// a secret changed after the export is restored when the import fails
+	_, err = AddSecret("export-worker", "QUEUE_URL", "amqp://new")
+	assert.Nil(t, err)
+
+	exported.Config.Image = ""
+	result, err := ImportDeployments(Export{Metadata: export.Metadata, Deployments: []ExportedDeployment{exported}, EncryptedKey: export.EncryptedKey}, true)
+	assert.Nil(t, err)
+	assert.Contains(t, result.Failed, "export-worker")
+	assert.Empty(t, result.Imported)
+
+	secret, err := GetSecret("export-worker", "QUEUE_URL")
+	assert.Nil(t, err)
+	assert.Equal(t, "amqp://new", secret.Value)
+
+	// a secret which did not exist before the import is removed
+	assert.Nil(t, DeleteSecret("export-worker", "QUEUE_URL"))
+	assert.Nil(t, DeleteConfig("export-worker"))
+
+	result, err = ImportDeployments(Export{Metadata: export.Metadata, Deployments: []ExportedDeployment{exported}, EncryptedKey: export.EncryptedKey}, false)
+	assert.Nil(t, err)
+	assert.Contains(t, result.Failed, "export-worker")
+	assert.False(t, secretExists("export-worker", "QUEUE_URL"))
+}
+
+func TestExportWithoutRecipient(t *testing.T) {
+	assert.Nil(t, SaveConfig(Config{Name: "export-web", Image: "biensupernice/web"}))
+	_, err := AddSecret("export-web", "API_TOKEN", "secret")
+	assert.Nil(t, err)
+
+	export, err := ExportDeployments("")
+	assert.Nil(t, err)
+	assert.Empty(t, export.EncryptedKey)
+
+	for _, d := range export.Deployments {
+		for _, s := range d.Secrets {
+			assert.Empty(t, s.Value)
+		}
+	}
+
+	assert.Nil(t, DeleteConfig("export-web"))
+	result, err := ImportDeployments(export, false)
+	assert.Nil(t, err)
+	assert.Contains(t, result.Imported, "export-web")
+	assert.Contains(t, result.MissingSecrets, "export-web/API_TOKEN")
+
+	assert.Nil(t, DeleteConfig("export-web"))
+}
+
+func TestImportEncryptedToAnotherRecipient(t *testing.T) {
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	der, _ := x509.MarshalPKIXPublicKey(&other.PublicKey)
+
+	export, err := ExportDeployments(string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})))
+	assert.Nil(t, err)
+
+	_, err = ImportDeployments(export, false)
+	assert.EqualError(t, err, "export secrets are encrypted to another recipient key")
+
+	_, err = ImportDeployments(Export{Metadata: ExportMetadata{Version: "0"}}, false)
+	assert.Error(t, err)
+
+	_, err = ExportDeployments("not a key")
+	assert.Error(t, err)
+}
+
+func TestSealBindsSecretToDeployment(t *testing.T) {
+	gcm, err := newGCM(make([]byte, 32))
+	assert.Nil(t, err)
+
+	sealed, err := seal(gcm, "hunter2", secretAAD("api", "DB_PASSWORD"))
+	assert.Nil(t, err)
+
+	value, err := open(gcm, sealed, secretAAD("api", "DB_PASSWORD"))
+	assert.Nil(t, err)
+	assert.Equal(t, "hunter2", value)
+
+	_, err = open(gcm, sealed, secretAAD("web", "DB_PASSWORD"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,104 @@
+package deployment
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/krane/krane/internal/docker"
+	"github.com/krane/krane/internal/logger"
+)
+
+// HooksConfig represents the containers run at specific points of a deployment run
+type HooksConfig struct {
+	PreDeploy  *HookConfig `json:"pre_deploy"`  // runs before new containers are created (ie. database migrations)
+	PostDeploy *HookConfig `json:"post_deploy"` // runs after new containers pass the health check (ie. smoke tests)
+}
+
+// HookConfig represents a one-off container run during a deployment. Hooks run with the
+// deployment environment variables and secrets and fail the deployment if they exit non-zero.
+type HookConfig struct {
+	Image      string            `json:"image"`      // container image (default deployment image and tag at the time the hook runs)
+	Registry   string            `json:"registry"`   // container registry (default deployment registry)
+	Tag        string            `json:"tag"`        // container image tag (default deployment tag)
+	Env        map[string]string `json:"env"`        // environment variables added to the deployment environment variables
+	Command    Args              `json:"command"`    // container start command (list of arguments or shell form string)
+	Entrypoint Args              `json:"entrypoint"` // container entrypoint (list of arguments or shell form string)
+	Timeout    uint              `json:"timeout"`    // seconds to wait for the hook to exit (default 0, which means no timeout)
+}
+
+// applyDefaults applies default hook configuration values. Hooks without an image are not
+// given the deployment image here, it's resolved when the hook runs so hooks follow the deployment tag.
+func (hook *HookConfig) applyDefaults() {
+	if hook.Image != "" && hook.Registry == "" {
+		hook.Registry = "docker.io"
+	}
+
+	if hook.Image != "" && hook.Tag == "" {
+		hook.Tag = "latest"
+	}
+
+	if hook.Env == nil {
+		hook.Env = make(map[string]string, 0)
+	}
+}
+
+// image returns the registry, image and tag a hook runs, the deployment image when the hook has no image
+func (hook HookConfig) image(config Config) (string, string, string) {
+	if hook.Image == "" {
+		return config.Registry, config.Image, config.Tag
+	}
+	return hook.Registry, hook.Image, hook.Tag
+}
+
+// runHook runs a hook container to completion streaming its output to the deployment event
+// emitter under the hook phase. An error is returned if the hook does not exit successfully.
+func runHook(config Config, hook *HookConfig, phase Phase, e *EventEmitter) error {
+	if hook == nil {
+		return nil
+	}
+
+	hookEmitter := *e
+	hookEmitter.Phase = phase
+	hookEmitter.emit(fmt.Sprintf("Running %s hook for deployment %s", hookName(phase), config.Name))
+
+	// the deployment image has already been pulled by the deployment run
+	registry, image, tag := hook.image(config)
+	if image != config.Image || registry != config.Registry || tag != config.Tag {
+		logger.Debugf("Pulling image for %s hook of deployment %s", hookName(phase), config.Name)
+		reader, err := docker.GetClient().PullImage(registry, image, tag)
+		if err != nil {
+			return fmt.Errorf("unable to pull image for %s hook, %v", hookName(phase), err)
+		}
+		hookEmitter.emitStream(reader)
+	}
+
+	container := oneOffContainer{
+		Kind:       strings.Replace(hookName(phase), "_", "-", -1),
+		Image:      docker.ImageRef(registry, image, tag),
+		Env:        hook.Env,
+		Command:    hook.Command,
+		Entrypoint: hook.Entrypoint,
+		Timeout:    hook.Timeout,
+	}
+
+	exitCode, err := container.run(config, hookEmitter)
+	if err != nil {
+		return fmt.Errorf("%s hook failed, %v", hookName(phase), err)
+	}
+
+	if exitCode != 0 {
+		hookEmitter.emit(fmt.Sprintf("%s hook failed with exit code %d", hookName(phase), exitCode))
+		return fmt.Errorf("%s hook exited with code %d", hookName(phase), exitCode)
+	}
+
+	hookEmitter.emit(fmt.Sprintf("%s hook completed", hookName(phase)))
+	return nil
+}
+
+// hookName returns the configuration name of the hook running in a phase
+func hookName(phase Phase) string {
+	if phase == PreDeployHookPhase {
+		return "pre_deploy"
+	}
+	return "post_deploy"
+}
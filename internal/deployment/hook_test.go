@@ -0,0 +1,52 @@
+package deployment
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHooksDefaultToDeploymentImage(t *testing.T) {
+	var config Config
+	err := json.Unmarshal([]byte(`{
+		"name": "api",
+		"image": "biensupernice/api",
+		"tag": "1.2.0",
+		"hooks": {
+			"pre_deploy": {"command": "npm run migrate"},
+			"post_deploy": {"image": "curlimages/curl", "command": ["curl", "-f", "http://api/health"], "timeout": 30}
+		}
+	}`), &config)
+	assert.Nil(t, err)
+
+	config.applyDefaults()
+
+	pre := config.Hooks.PreDeploy
+	registry, image, tag := pre.image(config)
+	assert.Equal(t, "docker.io", registry)
+	assert.Equal(t, "biensupernice/api", image)
+	assert.Equal(t, "1.2.0", tag)
+	assert.Equal(t, Args{"npm", "run", "migrate"}, pre.Command)
+
+	// the deployment image is resolved when the hook runs, not when the configuration is saved
+	assert.Equal(t, "", pre.Image)
+	config.Tag = "1.3.0"
+	_, _, tag = pre.image(config)
+	assert.Equal(t, "1.3.0", tag)
+
+	post := config.Hooks.PostDeploy
+	registry, image, tag = post.image(config)
+	assert.Equal(t, "docker.io", registry)
+	assert.Equal(t, "curlimages/curl", image)
+	assert.Equal(t, "latest", tag)
+	assert.Equal(t, uint(30), post.Timeout)
+}
+
+func TestHooksAreOptional(t *testing.T) {
+	config := Config{Name: "api", Image: "biensupernice/api"}
+	config.applyDefaults()
+	assert.Nil(t, config.Hooks.PreDeploy)
+	assert.Nil(t, config.Hooks.PostDeploy)
+	assert.Nil(t, runHook(config, config.Hooks.PreDeploy, PreDeployHookPhase, createEventEmitter(config.Name, "job")))
+}
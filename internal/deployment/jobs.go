@@ -12,23 +12,28 @@ import (
 type JobType string
 
 const (
-	RunDeploymentJobType     JobType = "RUN_DEPLOYMENT"
-	DeleteDeploymentJobType  JobType = "DELETE_DEPLOYMENT"
-	StopContainersJobType    JobType = "STOP_CONTAINERS"
-	StartContainersJobType   JobType = "START_CONTAINERS"
-	RestartContainersJobType JobType = "RESTART_CONTAINERS"
+	RunDeploymentJobType       JobType = "RUN_DEPLOYMENT"
+	DeleteDeploymentJobType    JobType = "DELETE_DEPLOYMENT"
+	StopContainersJobType      JobType = "STOP_CONTAINERS"
+	StartContainersJobType     JobType = "START_CONTAINERS"
+	RestartContainersJobType   JobType = "RESTART_CONTAINERS"
+	TaskJobType                JobType = "TASK"
+	ScheduledTaskJobType       JobType = "SCHEDULED_TASK"
+	CanaryJobType              JobType = "CANARY"
+	AbortCanaryJobType         JobType = "ABORT_CANARY"
+	RemovePreviousColorJobType JobType = "REMOVE_PREVIOUS_COLOR"
 )
 
 // enqueue queues up deployment job for processing
-func enqueue(j job.Job) {
+func enqueue(j job.Job) error {
 	enqueuer := job.NewEnqueuer(job.Queue())
 	queuedJob, err := enqueuer.Enqueue(j)
 	if err != nil {
 		logger.Errorf("Error enqueuing deployment job %v", err)
-		return
+		return err
 	}
 	logger.Debugf("Deployment job %s queued for processing", queuedJob.Deployment)
-	return
+	return nil
 }
 
 // CreateCollection create the job collection for a deployment
@@ -22,7 +22,7 @@ func SubscribeToDeploymentLogs(client *websocket.Conn, deployment string) {
 		return
 	}
 
-	for _, container := range containers {
+	for _, container := range withSidecars(containers) {
 		if err := docker.GetClient().StreamContainerLogs(container.ID, data, done); err != nil {
 			logger.Warnf("error grabbing container reader, %v", err)
 			if err := client.Close(); err != nil {
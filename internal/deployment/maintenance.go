@@ -0,0 +1,204 @@
+package deployment
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/krane/krane/internal/constants"
+	"github.com/krane/krane/internal/proxy"
+	"github.com/krane/krane/internal/store"
+	"github.com/krane/krane/internal/utils"
+)
+
+// defaultMaintenanceRetryAfter is the default seconds clients are asked to wait before retrying
+const defaultMaintenanceRetryAfter = 300
+
+// maxMaintenancePageSize is the largest maintenance page accepted, in bytes
+const maxMaintenancePageSize = 512 * 1024
+
+// Maintenance represents a deployment in maintenance, the routes of the deployment are kept but
+// requests are answered by the maintenance responder instead of the containers
+type Maintenance struct {
+	Deployment string `json:"deployment"`
+	Page       string `json:"page"`             // HTML page served with a 503 status
+	RetryAfter int64  `json:"retry_after"`      // seconds clients are asked to wait before retrying (Retry-After header)
+	EnabledAt  int64  `json:"enabled_at_epoch"` // epoch in seconds since 1970
+}
+
+// EnableMaintenance puts a deployment in maintenance, serving a page instead of the containers until maintenance
+// is disabled or the next run of the deployment succeeds. Without a page a default page is served.
+func EnableMaintenance(deployment string, page string, retryAfter int64) (Maintenance, error) {
+	if !Exist(deployment) {
+		return Maintenance{}, fmt.Errorf("deployment %s does not exist", deployment)
+	}
+
+	if !utils.BoolEnv(constants.EnvProxyEnabled) {
+		return Maintenance{}, errors.New("maintenance requires the network proxy")
+	}
+
+	if retryAfter < 0 {
+		return Maintenance{}, fmt.Errorf("invalid retry after %d", retryAfter)
+	}
+
+	if len(page) > maxMaintenancePageSize {
+		return Maintenance{}, fmt.Errorf("maintenance page must be at most %d bytes", maxMaintenancePageSize)
+	}
+
+	if retryAfter == 0 {
+		retryAfter = defaultMaintenanceRetryAfter
+	}
+
+	if page == "" {
+		page = defaultMaintenancePage(deployment)
+	}
+
+	maintenance := Maintenance{
+		Deployment: deployment,
+		Page:       page,
+		RetryAfter: retryAfter,
+		EnabledAt:  time.Now().Unix(),
+	}
+
+	bytes, _ := json.Marshal(maintenance)
+	if err := store.Client().Put(constants.MaintenanceCollectionName, deployment, bytes); err != nil {
+		return Maintenance{}, err
+	}
+
+	if err := SyncProxy(); err != nil {
+		return Maintenance{}, err
+	}
+
+	if err := ensureMaintenanceResponder(); err != nil {
+		return Maintenance{}, err
+	}
+
+	return maintenance, nil
+}
+
+// DisableMaintenance routes the requests of a deployment back to its containers
+func DisableMaintenance(deployment string) error {
+	if _, err := GetMaintenance(deployment); err != nil {
+		return err
+	}
+
+	if err := clearMaintenance(deployment); err != nil {
+		return err
+	}
+	return SyncProxy()
+}
+
+// GetMaintenance returns the maintenance of a deployment
+func GetMaintenance(deployment string) (Maintenance, error) {
+	bytes, err := store.Client().Get(constants.MaintenanceCollectionName, deployment)
+	if err != nil {
+		return Maintenance{}, err
+	}
+
+	if bytes == nil {
+		return Maintenance{}, fmt.Errorf("deployment %s is not in maintenance", deployment)
+	}
+
+	var maintenance Maintenance
+	if err := json.Unmarshal(bytes, &maintenance); err != nil {
+		return Maintenance{}, err
+	}
+	return maintenance, nil
+}
+
+// getAllMaintenance returns the deployments in maintenance sorted by name
+func getAllMaintenance() ([]Maintenance, error) {
+	bytes, err := store.Client().GetAll(constants.MaintenanceCollectionName)
+	if err != nil {
+		return make([]Maintenance, 0), err
+	}
+
+	all := make([]Maintenance, 0)
+	for _, b := range bytes {
+		var maintenance Maintenance
+		if err := json.Unmarshal(b, &maintenance); err != nil {
+			return make([]Maintenance, 0), err
+		}
+		all = append(all, maintenance)
+	}
+
+	sort.Slice(all, func(i, j int) bool { return all[i].Deployment < all[j].Deployment })
+	return all, nil
+}
+
+// clearMaintenance removes the maintenance of a deployment (if any), the proxy configuration
+// is written on the next SyncProxy
+func clearMaintenance(deployment string) error {
+	return store.Client().Remove(constants.MaintenanceCollectionName, deployment)
+}
+
+// defaultMaintenancePage returns the page served for a deployment in maintenance without a custom page
+func defaultMaintenancePage(deployment string) string {
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>Under maintenance</title>
+</head>
+<body>
+  <h1>Under maintenance</h1>
+  <p>%s is down for maintenance, please check back soon.</p>
+</body>
+</html>
+`, html.EscapeString(deployment))
+}
+
+// maintenanceResponderConfig returns the deployment answering requests for deployments in maintenance,
+// a Caddy server loading its Caddyfile and pages from the proxy config directory
+func maintenanceResponderConfig() Config {
+	return Config{
+		Name:       proxy.MaintenanceResponderName,
+		Image:      "caddy",
+		Tag:        "2",
+		Scale:      1,
+		Internal:   true,
+		TargetPort: proxy.MaintenanceResponderPort,
+		Command: Args{
+			"caddy", "run",
+			"--config", proxy.MaintenanceResponderCaddyfilePath(),
+			"--adapter", "caddyfile",
+			"--watch",
+		},
+		Volumes: VolumeConfigs{
+			{
+				Type:     BindVolume,
+				Source:   os.Getenv(constants.EnvProxyConfigDir),
+				Target:   proxy.FileProviderDirectory,
+				ReadOnly: true,
+			},
+		},
+	}
+}
+
+// ensureMaintenanceResponder runs the maintenance responder unless it is already running
+func ensureMaintenanceResponder() error {
+	config := maintenanceResponderConfig()
+
+	containers, err := GetContainersByDeployment(config.Name)
+	if err != nil {
+		return err
+	}
+
+	running := len(containers) > 0
+	for _, c := range containers {
+		running = running && c.State.Running
+	}
+
+	if running {
+		return nil
+	}
+
+	if err := SaveConfig(config); err != nil {
+		return err
+	}
+	return Run(config.Name)
+}
@@ -0,0 +1,86 @@
+package deployment
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/krane/krane/internal/constants"
+	"github.com/krane/krane/internal/proxy"
+	"github.com/krane/krane/internal/store"
+)
+
+func TestEnableMaintenanceInvalid(t *testing.T) {
+	_, err := EnableMaintenance("maintenance-missing", "", 0)
+	assert.Error(t, err)
+
+	assert.Nil(t, SaveConfig(Config{Name: "maintenance-api", Image: "biensupernice/api"}))
+	defer DeleteConfig("maintenance-api")
+
+	_ = os.Setenv(constants.EnvProxyEnabled, "false")
+	_, err = EnableMaintenance("maintenance-api", "", 0)
+	assert.EqualError(t, err, "maintenance requires the network proxy")
+
+	_ = os.Setenv(constants.EnvProxyEnabled, "true")
+	defer os.Unsetenv(constants.EnvProxyEnabled)
+
+	_, err = EnableMaintenance("maintenance-api", "", -1)
+	assert.Error(t, err)
+
+	_, err = EnableMaintenance("maintenance-api", string(make([]byte, maxMaintenancePageSize+1)), 0)
+	assert.Error(t, err)
+
+	assert.Error(t, DisableMaintenance("maintenance-api"))
+}
+
+func TestSyncProxyMaintenance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "krane-proxy")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	_ = os.Setenv(constants.EnvProxyConfigDir, dir)
+	defer os.Unsetenv(constants.EnvProxyConfigDir)
+
+	assert.Nil(t, SaveConfig(Config{Name: "maintenance-web", Image: "biensupernice/web", Alias: []string{"maintenance.example.com"}, TargetPort: "80"}))
+	defer DeleteConfig("maintenance-web")
+
+	maintenance := Maintenance{Deployment: "maintenance-web", Page: "<h1>Back soon</h1>", RetryAfter: 120}
+	bytes, _ := json.Marshal(maintenance)
+	assert.Nil(t, store.Client().Put(constants.MaintenanceCollectionName, maintenance.Deployment, bytes))
+
+	stored, err := GetMaintenance("maintenance-web")
+	assert.Nil(t, err)
+	assert.Equal(t, maintenance, stored)
+
+	assert.Nil(t, SyncProxy())
+
+	page, err := ioutil.ReadFile(filepath.Join(dir, "maintenance", "maintenance-web.html"))
+	assert.Nil(t, err)
+	assert.Equal(t, "<h1>Back soon</h1>", string(page))
+
+	config, err := ioutil.ReadFile(filepath.Join(dir, proxy.MaintenanceConfigName+".yml"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(config), "Host(`maintenance.example.com`)")
+
+	// turning maintenance off routes requests back to the containers
+	assert.Nil(t, DisableMaintenance("maintenance-web"))
+
+	_, err = GetMaintenance("maintenance-web")
+	assert.Error(t, err)
+
+	_, err = os.Stat(filepath.Join(dir, proxy.MaintenanceConfigName+".yml"))
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = os.Stat(filepath.Join(dir, "maintenance", "maintenance-web.html"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDefaultMaintenancePage(t *testing.T) {
+	page := defaultMaintenancePage("api")
+	assert.Contains(t, page, "<title>Under maintenance</title>")
+	assert.Contains(t, page, "api is down for maintenance")
+}
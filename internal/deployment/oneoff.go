@@ -0,0 +1,111 @@
+package deployment
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types/mount"
+	"github.com/lithammer/shortuuid/v3"
+
+	"github.com/krane/krane/internal/docker"
+	"github.com/krane/krane/internal/logger"
+)
+
+// oneOffContainer represents a short lived container run to completion with a deployment configuration (ie. hooks and tasks)
+type oneOffContainer struct {
+	Kind       string            // kind of container used for naming, ie. pre-deploy or task
+	Image      string            // container image reference, already pulled
+	Env        map[string]string // environment variables added to the deployment environment variables
+	Command    Args              // container start command
+	Entrypoint Args              // container entrypoint
+	Mounts     []mount.Mount     // volumes mounted into the container
+	Timeout    uint              // seconds to wait for the container to exit (0 means no timeout)
+
+	// onStart is called with the container id once the container is started
+	onStart func(containerID string)
+}
+
+// DockerConfig returns the docker configuration for creating a one-off container for a deployment. One-off
+// containers join the Krane network and receive the deployment environment variables and secrets.
+func (o oneOffContainer) DockerConfig(config Config) docker.DockerConfig {
+	kraneNetwork, err := docker.GetClient().GetNetworkByName(docker.KraneNetworkName)
+	if err != nil {
+		return docker.DockerConfig{}
+	}
+
+	env := make(map[string]string, 0)
+	for k, v := range config.Env {
+		env[k] = v
+	}
+	for k, v := range o.Env {
+		env[k] = v
+	}
+
+	volumeSet := make(map[string]struct{}, 0)
+	for _, m := range o.Mounts {
+		volumeSet[m.Target] = struct{}{}
+	}
+
+	return docker.DockerConfig{
+		ContainerName: fmt.Sprintf("%s-%s-%s", config.Name, o.Kind, shortuuid.New()),
+		Image:         o.Image,
+		NetworkID:     kraneNetwork.ID,
+		Labels:        map[string]string{docker.OneOffContainerLabel: config.Name},
+		VolumeMounts:  o.Mounts,
+		VolumeSet:     volumeSet,
+		Env:           dockerEnvs(config.Name, env, config.Secrets),
+		Command:       o.Command,
+		Entrypoint:    o.Entrypoint,
+		WorkingDir:    config.WorkingDir,
+		User:          config.User,
+	}
+}
+
+// run creates and starts a one-off container streaming its output to an event emitter until it exits.
+// The container is removed once it exits, the exit code of the container is returned.
+func (o oneOffContainer) run(config Config, e EventEmitter) (int64, error) {
+	ctx := context.Background()
+	defer ctx.Done()
+
+	body, err := docker.GetClient().CreateContainer(ctx, o.DockerConfig(config))
+	if err != nil {
+		return -1, fmt.Errorf("unable to create %s container, %v", o.Kind, err)
+	}
+	defer func() {
+		if err := docker.GetClient().RemoveContainer(ctx, body.ID, true); err != nil {
+			logger.Warnf("unable to remove %s container %v", o.Kind, err)
+		}
+	}()
+
+	if err := docker.GetClient().StartContainer(ctx, body.ID); err != nil {
+		return -1, fmt.Errorf("unable to start %s container, %v", o.Kind, err)
+	}
+
+	if o.onStart != nil {
+		o.onStart(body.ID)
+	}
+
+	waitCtx := ctx
+	if o.Timeout > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, time.Duration(o.Timeout)*time.Second)
+		defer cancel()
+	}
+
+	output, err := docker.GetClient().FollowContainerOutput(waitCtx, body.ID)
+	if err != nil {
+		return -1, fmt.Errorf("unable to read %s output, %v", o.Kind, err)
+	}
+
+	// closing the output unblocks the copy when the emitter stops reading early
+	e.emitStream(output)
+	_ = output.Close()
+
+	exitCode, err := docker.GetClient().WaitContainer(waitCtx, body.ID)
+	if err != nil {
+		return -1, fmt.Errorf("%s did not complete, %v", o.Kind, err)
+	}
+
+	return exitCode, nil
+}
@@ -12,4 +12,10 @@ const (
 	PullImagePhase       Phase = "PULL_IMAGE"
 	CreateContainerPhase Phase = "CREATE_CONTAINER"
 	StartContainerPhase  Phase = "START_CONTAINER"
+	PreDeployHookPhase   Phase = "PRE_DEPLOY_HOOK"
+	PostDeployHookPhase  Phase = "POST_DEPLOY_HOOK"
+	TaskPhase            Phase = "TASK"
+	DependenciesPhase    Phase = "DEPENDENCIES"
+	CanaryPhase          Phase = "CANARY"
+	SwitchPhase          Phase = "SWITCH"
 )
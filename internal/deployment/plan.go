@@ -0,0 +1,289 @@
+package deployment
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/krane/krane/internal/docker"
+)
+
+// DeploymentPlan represents the changes running a candidate deployment configuration would make
+type DeploymentPlan struct {
+	Deployment string         `json:"deployment"`
+	Action     PlanAction     `json:"action"`          // create, update or unchanged
+	Changed    []string       `json:"changed"`         // configuration fields changed
+	Image      *ValueChange   `json:"image,omitempty"` // set when the image changes
+	Tag        *ValueChange   `json:"tag,omitempty"`   // set when the image tag changes
+	Env        KeysDiff       `json:"env"`
+	Secrets    KeysDiff       `json:"secrets"`
+	Ports      ListDiff       `json:"ports"`
+	Alias      ListDiff       `json:"alias"`
+	Scale      ScaleChange    `json:"scale"`
+	Containers ContainersPlan `json:"containers"`
+}
+
+// ValueChange represents a value before and after a change
+type ValueChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// KeysDiff represents the keys added, removed or with a different value in a map
+type KeysDiff struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Changed []string `json:"changed"`
+}
+
+// ListDiff represents the values added or removed from a list
+type ListDiff struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+// ScaleChange represents the change of a deployment scale
+type ScaleChange struct {
+	From  int `json:"from"`
+	To    int `json:"to"`
+	Delta int `json:"delta"`
+}
+
+// ContainersPlan represents the effect of a change on the live containers of a deployment
+type ContainersPlan struct {
+	Live     int      `json:"live"`     // containers currently part of the deployment
+	Running  int      `json:"running"`  // live containers in a running state
+	Recreate bool     `json:"recreate"` // whether running the deployment replaces containers, runs always recreate every container
+	Reasons  []string `json:"reasons"`  // why containers are recreated
+}
+
+// alwaysRecreatedReason is the reason containers are recreated when neither the configuration nor the containers changed
+const alwaysRecreatedReason = "containers are always recreated when the deployment runs"
+
+// fieldsNotRecreatingContainers are configuration fields which do not change the containers of a deployment,
+// containers are still recreated when the deployment runs but they are not listed as a reason
+var fieldsNotRecreatingContainers = map[string]bool{
+	"schedules":  true,
+	"depends_on": true,
+	"hooks":      true,
+	"template":   true,
+	"canary":     true,
+	"blue_green": true,
+}
+
+// PlanConfig returns the changes running a candidate configuration would make to a deployment,
+// compared to its stored configuration and live containers. Nothing is saved or run.
+func PlanConfig(deployment string, candidate Config) (DeploymentPlan, error) {
+	if candidate.Name == "" {
+		candidate.Name = deployment
+	}
+
+	if candidate.Name != deployment {
+		return DeploymentPlan{}, fmt.Errorf("config name %s does not match deployment %s", candidate.Name, deployment)
+	}
+
+	candidate.applyDefaults()
+	if err := candidate.isValid(); err != nil {
+		return DeploymentPlan{}, err
+	}
+
+	others, err := GetAllDeploymentConfigs()
+	if err != nil {
+		return DeploymentPlan{}, err
+	}
+
+	if err := validateDependencyCycles(candidate, others); err != nil {
+		return DeploymentPlan{}, err
+	}
+
+	if err := validatePortConflicts(candidate, others); err != nil {
+		return DeploymentPlan{}, err
+	}
+
+	if err := validateRouteConflicts(candidate, others); err != nil {
+		return DeploymentPlan{}, err
+	}
+
+	if err := validateEntryPointConflicts(candidate, others); err != nil {
+		return DeploymentPlan{}, err
+	}
+
+	if err := validateCertificate(candidate); err != nil {
+		return DeploymentPlan{}, err
+	}
+
+	if !Exist(deployment) {
+		return planConfig(candidate, nil, make([]KraneContainer, 0)), nil
+	}
+
+	current, err := GetDeploymentConfig(deployment)
+	if err != nil {
+		return DeploymentPlan{}, err
+	}
+
+	containers, err := GetContainersByDeployment(deployment)
+	if err != nil {
+		return DeploymentPlan{}, err
+	}
+
+	return planConfig(candidate, &current, ActiveContainers(deployment, containers)), nil
+}
+
+// planConfig diffs a candidate configuration against the current configuration (nil when
+// the deployment does not exist) and the live containers of a deployment
+func planConfig(candidate Config, current *Config, containers []KraneContainer) DeploymentPlan {
+	plan := DeploymentPlan{
+		Deployment: candidate.Name,
+		Action:     CreateAction,
+		Changed:    make([]string, 0),
+		Containers: ContainersPlan{Live: len(containers), Reasons: make([]string, 0)},
+	}
+
+	previous := Config{}
+	if current != nil {
+		previous = *current
+		previous.applyDefaults()
+		plan.Action = UnchangedAction
+	}
+
+	plan.Changed = changedFields(previous, candidate)
+	if current != nil && len(plan.Changed) > 0 {
+		plan.Action = UpdateAction
+	}
+
+	if current != nil && previous.Image != candidate.Image {
+		plan.Image = &ValueChange{From: previous.Image, To: candidate.Image}
+	}
+
+	if current != nil && previous.Tag != candidate.Tag {
+		plan.Tag = &ValueChange{From: previous.Tag, To: candidate.Tag}
+	}
+
+	plan.Env = diffKeys(previous.Env, candidate.Env)
+	plan.Secrets = diffKeys(previous.Secrets, candidate.Secrets)
+	plan.Ports = diffList(portStrings(previous.Ports), portStrings(candidate.Ports))
+	plan.Alias = diffList(previous.Alias, candidate.Alias)
+	plan.Scale = ScaleChange{From: previous.Scale, To: candidate.Scale, Delta: candidate.Scale - previous.Scale}
+
+	// reasons containers are recreated, either the configuration changed or the live containers drifted from it
+	reasons := make([]string, 0)
+	if current == nil {
+		reasons = append(reasons, "deployment does not exist")
+	}
+
+	recreatingFields := make([]string, 0)
+	for _, field := range plan.Changed {
+		if !fieldsNotRecreatingContainers[field] {
+			recreatingFields = append(recreatingFields, field)
+		}
+	}
+	if current != nil && len(recreatingFields) > 0 {
+		reasons = append(reasons, fmt.Sprintf("configuration changed (%s)", strings.Join(recreatingFields, ", ")))
+	}
+
+	if len(containers) != candidate.Scale {
+		reasons = append(reasons, fmt.Sprintf("%d live container(s) for a scale of %d", len(containers), candidate.Scale))
+	}
+
+	// containers are created from the full image reference, including the registry and tag
+	image := docker.ImageRef(candidate.Registry, candidate.Image, candidate.Tag)
+	for _, c := range containers {
+		if c.State.Running {
+			plan.Containers.Running++
+		} else {
+			reasons = append(reasons, fmt.Sprintf("container %s is not running", c.Name))
+		}
+
+		if c.Image != image {
+			reasons = append(reasons, fmt.Sprintf("container %s runs image %s", c.Name, c.Image))
+		}
+	}
+
+	// a run removes and recreates every container, even when nothing changed
+	if len(reasons) == 0 {
+		reasons = append(reasons, alwaysRecreatedReason)
+	}
+
+	plan.Containers.Reasons = reasons
+	plan.Containers.Recreate = true
+
+	return plan
+}
+
+// changedFields returns the json names of the configuration fields which differ between two configurations
+func changedFields(a, b Config) []string {
+	changed := make([]string, 0)
+
+	aValue, bValue := reflect.ValueOf(a), reflect.ValueOf(b)
+	for i := 0; i < aValue.NumField(); i++ {
+		if reflect.DeepEqual(aValue.Field(i).Interface(), bValue.Field(i).Interface()) {
+			continue
+		}
+
+		name := strings.Split(aValue.Type().Field(i).Tag.Get("json"), ",")[0]
+		changed = append(changed, name)
+	}
+
+	return changed
+}
+
+// diffKeys returns the keys added, removed or with a different value from a to b
+func diffKeys(a, b map[string]string) KeysDiff {
+	diff := KeysDiff{Added: make([]string, 0), Removed: make([]string, 0), Changed: make([]string, 0)}
+
+	for k, v := range b {
+		previous, ok := a[k]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, k)
+		case previous != v:
+			diff.Changed = append(diff.Changed, k)
+		}
+	}
+
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			diff.Removed = append(diff.Removed, k)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return diff
+}
+
+// diffList returns the values added or removed from a to b
+func diffList(a, b []string) ListDiff {
+	diff := ListDiff{Added: make([]string, 0), Removed: make([]string, 0)}
+
+	inA := make(map[string]bool, 0)
+	for _, v := range a {
+		inA[v] = true
+	}
+
+	inB := make(map[string]bool, 0)
+	for _, v := range b {
+		inB[v] = true
+		if !inA[v] {
+			diff.Added = append(diff.Added, v)
+		}
+	}
+
+	for _, v := range a {
+		if !inB[v] {
+			diff.Removed = append(diff.Removed, v)
+		}
+	}
+
+	return diff
+}
+
+func portStrings(ports PortConfigs) []string {
+	specs := make([]string, 0)
+	for _, p := range ports {
+		specs = append(specs, p.String())
+	}
+	return specs
+}
@@ -0,0 +1,88 @@
+package deployment
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanConfigCreate(t *testing.T) {
+	candidate := storedConfig(Config{Name: "api", Image: "biensupernice/api", Scale: 2, Alias: []string{"api.example.com"}})
+
+	plan := planConfig(candidate, nil, []KraneContainer{})
+	assert.Equal(t, CreateAction, plan.Action)
+	assert.Nil(t, plan.Image)
+	assert.Equal(t, []string{"api.example.com"}, plan.Alias.Added)
+	assert.Equal(t, ScaleChange{From: 0, To: 2, Delta: 2}, plan.Scale)
+	assert.True(t, plan.Containers.Recreate)
+}
+
+func TestPlanConfigUpdate(t *testing.T) {
+	current := Config{
+		Name:  "api",
+		Image: "biensupernice/api",
+		Tag:   "1.0.0",
+		Scale: 1,
+		Env:   map[string]string{"LOG_LEVEL": "info", "LEGACY": "true"},
+		Ports: PortConfigs{{HostPort: "8080", ContainerPort: "80", Protocol: TCP}},
+		Alias: []string{"api.example.com"},
+	}
+
+	candidate := storedConfig(Config{
+		Name:  "api",
+		Image: "biensupernice/api",
+		Tag:   "1.1.0",
+		Scale: 3,
+		Env:   map[string]string{"LOG_LEVEL": "debug", "FEATURE_X": "on"},
+		Ports: PortConfigs{{HostPort: "8081", ContainerPort: "80", Protocol: TCP}},
+		Alias: []string{"api.example.com", "api.example.org"},
+	})
+
+	containers := []KraneContainer{{Name: "api-1", Image: "docker.io/biensupernice/api:1.0.0", State: ContainerState{Running: true}}}
+
+	plan := planConfig(candidate, &current, containers)
+	assert.Equal(t, UpdateAction, plan.Action)
+	assert.Equal(t, []string{"tag", "alias", "env", "ports", "scale"}, plan.Changed)
+	assert.Nil(t, plan.Image)
+	assert.Equal(t, &ValueChange{From: "1.0.0", To: "1.1.0"}, plan.Tag)
+	assert.Equal(t, KeysDiff{Added: []string{"FEATURE_X"}, Removed: []string{"LEGACY"}, Changed: []string{"LOG_LEVEL"}}, plan.Env)
+	assert.Equal(t, ListDiff{Added: []string{"8081:80/tcp"}, Removed: []string{"8080:80/tcp"}}, plan.Ports)
+	assert.Equal(t, ListDiff{Added: []string{"api.example.org"}, Removed: []string{}}, plan.Alias)
+	assert.Equal(t, ScaleChange{From: 1, To: 3, Delta: 2}, plan.Scale)
+	assert.Equal(t, 1, plan.Containers.Running)
+	assert.True(t, plan.Containers.Recreate)
+}
+
+func TestPlanConfigUnchanged(t *testing.T) {
+	current := storedConfig(Config{Name: "api", Image: "biensupernice/api", Scale: 1})
+	containers := []KraneContainer{{Name: "api-1", Image: "docker.io/biensupernice/api:latest", State: ContainerState{Running: true}}}
+
+	// running an unchanged configuration still recreates every container
+	plan := planConfig(current, &current, containers)
+	assert.Equal(t, UnchangedAction, plan.Action)
+	assert.Empty(t, plan.Changed)
+	assert.True(t, plan.Containers.Recreate)
+	assert.Equal(t, []string{alwaysRecreatedReason}, plan.Containers.Reasons)
+
+	// schedules do not change containers
+	candidate := current
+	candidate.Schedules = []ScheduleConfig{{Name: "nightly", Schedule: "@daily"}}
+	plan = planConfig(candidate, &current, containers)
+	assert.Equal(t, UpdateAction, plan.Action)
+	assert.Equal(t, []string{"schedules"}, plan.Changed)
+	assert.True(t, plan.Containers.Recreate)
+	assert.Equal(t, []string{alwaysRecreatedReason}, plan.Containers.Reasons)
+
+	// live containers drifted from the configuration
+	containers[0].State.Running = false
+	plan = planConfig(current, &current, containers)
+	assert.Equal(t, UnchangedAction, plan.Action)
+	assert.True(t, plan.Containers.Recreate)
+	assert.Equal(t, []string{"container api-1 is not running"}, plan.Containers.Reasons)
+
+	// containers are compared against the full image reference
+	containers[0].State.Running = true
+	containers[0].Image = "docker.io/biensupernice/api:1.0.0"
+	plan = planConfig(current, &current, containers)
+	assert.Equal(t, []string{"container api-1 runs image docker.io/biensupernice/api:1.0.0"}, plan.Containers.Reasons)
+}
@@ -1,8 +1,12 @@
 package deployment
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net"
 	"strconv"
+	"strings"
 
 	"github.com/docker/go-connections/nat"
 )
@@ -18,8 +22,214 @@ type PortProtocol string
 
 const (
 	TCP PortProtocol = "tcp"
+	UDP PortProtocol = "udp"
 )
 
+// PortConfig represents a port mapping declared in a deployment configuration
+type PortConfig struct {
+	HostIP        string       // host ip to bind to (default all interfaces)
+	HostPort      string       // host port, when empty a free port on the host is assigned
+	ContainerPort string       // container port
+	Protocol      PortProtocol // tcp or udp (default tcp)
+}
+
+// PortConfigs is the list of port mappings declared in a deployment configuration
+type PortConfigs []PortConfig
+
+// ParsePortConfig parses a port mapping in the format [[host_ip:]host_port:]container_port[/protocol]
+// ie. 8080, 80:8080, 53:53/udp, 127.0.0.1:5432:5432, [::1]:5432:5432
+func ParsePortConfig(raw string) (PortConfig, error) {
+	p := PortConfig{Protocol: TCP}
+
+	spec := raw
+	if i := strings.LastIndex(spec, "/"); i != -1 {
+		p.Protocol = PortProtocol(strings.ToLower(spec[i+1:]))
+		spec = spec[:i]
+	}
+
+	parts := strings.Split(spec, ":")
+
+	// IPv6 host ips are bracketed since they contain colons, ie. [::1]:8080:80
+	if strings.HasPrefix(spec, "[") {
+		end := strings.Index(spec, "]:")
+		if end == -1 {
+			return PortConfig{}, fmt.Errorf("invalid port mapping %s", raw)
+		}
+
+		parts = append([]string{spec[1:end]}, strings.Split(spec[end+2:], ":")...)
+		if len(parts) != 3 {
+			return PortConfig{}, fmt.Errorf("invalid port mapping %s", raw)
+		}
+	}
+
+	switch len(parts) {
+	case 1:
+		p.ContainerPort = parts[0]
+	case 2:
+		p.HostPort = parts[0]
+		p.ContainerPort = parts[1]
+	case 3:
+		p.HostIP = parts[0]
+		p.HostPort = parts[1]
+		p.ContainerPort = parts[2]
+	default:
+		return PortConfig{}, fmt.Errorf("invalid port mapping %s", raw)
+	}
+
+	if err := p.isValid(); err != nil {
+		return PortConfig{}, fmt.Errorf("invalid port mapping %s, %v", raw, err)
+	}
+
+	return p, nil
+}
+
+// String returns the port mapping in the format [[host_ip:]host_port:]container_port/protocol
+func (p PortConfig) String() string {
+	spec := fmt.Sprintf("%s/%s", p.ContainerPort, p.protocol())
+	if p.HostPort != "" || p.HostIP != "" {
+		spec = fmt.Sprintf("%s:%s", p.HostPort, spec)
+	}
+	if strings.Contains(p.HostIP, ":") {
+		spec = fmt.Sprintf("[%s]:%s", p.HostIP, spec)
+	} else if p.HostIP != "" {
+		spec = fmt.Sprintf("%s:%s", p.HostIP, spec)
+	}
+	return spec
+}
+
+// MarshalJSON serializes a port mapping as a string
+func (p PortConfig) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.String())
+}
+
+// UnmarshalJSON parses a port mapping from a string
+func (p *PortConfig) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return errors.New("port mapping must be a string")
+	}
+
+	parsed, err := ParsePortConfig(raw)
+	if err != nil {
+		return err
+	}
+
+	*p = parsed
+	return nil
+}
+
+// UnmarshalJSON parses ports as a list of port mappings. For backwards compatibility ports can
+// also be declared as a map of host ports ([host_ip:]host_port) to container ports (container_port[/protocol]).
+func (ports *PortConfigs) UnmarshalJSON(data []byte) error {
+	var hostToContainer map[string]string
+	if err := json.Unmarshal(data, &hostToContainer); err == nil {
+		parsed := make(PortConfigs, 0)
+		for hostPort, containerPort := range hostToContainer {
+			raw := containerPort
+			if hostPort != "" {
+				raw = fmt.Sprintf("%s:%s", hostPort, containerPort)
+			}
+
+			p, err := ParsePortConfig(raw)
+			if err != nil {
+				return err
+			}
+			parsed = append(parsed, p)
+		}
+		*ports = parsed
+		return nil
+	}
+
+	var list []PortConfig
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*ports = list
+	return nil
+}
+
+// isValid returns an error if a port mapping is not valid
+func (p PortConfig) isValid() error {
+	if !isValidPortNumber(p.ContainerPort) {
+		return fmt.Errorf("invalid container port %s", p.ContainerPort)
+	}
+
+	if p.HostPort != "" && !isValidPortNumber(p.HostPort) {
+		return fmt.Errorf("invalid host port %s", p.HostPort)
+	}
+
+	if p.HostIP != "" && net.ParseIP(p.HostIP) == nil {
+		return fmt.Errorf("invalid host ip %s", p.HostIP)
+	}
+
+	if p.protocol() != TCP && p.protocol() != UDP {
+		return fmt.Errorf("invalid protocol %s, must be tcp or udp", p.Protocol)
+	}
+
+	return nil
+}
+
+// protocol returns the port protocol defaulting to tcp
+func (p PortConfig) protocol() PortProtocol {
+	if p.Protocol == "" {
+		return TCP
+	}
+	return p.Protocol
+}
+
+// conflictsWith returns true if two port mappings claim the same host port
+func (p PortConfig) conflictsWith(other PortConfig) bool {
+	if p.HostPort == "" || other.HostPort == "" {
+		return false
+	}
+
+	if p.HostPort != other.HostPort || p.protocol() != other.protocol() {
+		return false
+	}
+
+	return isAllInterfaces(p.HostIP) || isAllInterfaces(other.HostIP) || p.HostIP == other.HostIP
+}
+
+// dockerPort returns the Docker formatted container port
+func (p PortConfig) dockerPort() (nat.Port, error) {
+	return nat.NewPort(string(p.protocol()), p.ContainerPort)
+}
+
+// validatePortConflicts returns an error if a deployment claims a host port more than
+// once or claims a host port already claimed by another deployment
+func validatePortConflicts(config Config, others []Config) error {
+	for i, p := range config.Ports {
+		for _, other := range config.Ports[i+1:] {
+			if p.conflictsWith(other) {
+				return fmt.Errorf("host port %s/%s is mapped more than once", p.HostPort, p.protocol())
+			}
+		}
+
+		for _, d := range others {
+			if d.Name == config.Name {
+				continue
+			}
+
+			for _, other := range d.Ports {
+				if p.conflictsWith(other) {
+					return fmt.Errorf("host port %s/%s is already claimed by deployment %s", p.HostPort, p.protocol(), d.Name)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func isValidPortNumber(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n <= 65535
+}
+
+func isAllInterfaces(ip string) bool {
+	return ip == "" || ip == "0.0.0.0" || ip == "::"
+}
+
 func fromPortMapToPortList(pMap nat.PortMap) []Port {
 	bindings := make([]Port, 0)
 
@@ -36,20 +246,3 @@ func fromPortMapToPortList(pMap nat.PortMap) []Port {
 
 	return bindings
 }
-
-// findFreePort returns a free port on the host machine
-func findFreePort() (string, error) {
-	addr, err := net.ResolveTCPAddr(string(TCP), "localhost:0")
-	if err != nil {
-		return "", err
-	}
-
-	listener, err := net.ListenTCP(string(TCP), addr)
-	if err != nil {
-		return "", err
-	}
-	defer listener.Close()
-
-	port := listener.Addr().(*net.TCPAddr).Port
-	return strconv.Itoa(port), nil
-}
@@ -0,0 +1,278 @@
+package deployment
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/krane/krane/internal/constants"
+	"github.com/krane/krane/internal/docker"
+	"github.com/krane/krane/internal/logger"
+	"github.com/krane/krane/internal/store"
+)
+
+// PortAllocation represents a host port assigned to a container port of a deployment container slot.
+// Allocations are stored so a deployment keeps the same host ports across runs and restarts.
+type PortAllocation struct {
+	Deployment    string       `json:"deployment"`
+	Slot          int          `json:"slot"`
+	ContainerPort string       `json:"container_port"`
+	Protocol      PortProtocol `json:"protocol"`
+	HostPort      string       `json:"host_port"`
+}
+
+// allocationMu serializes port allocations across workers
+var allocationMu sync.Mutex
+
+// AllocateHostPort returns the host port allocated to a container port for a deployment container slot.
+// If no allocation exists, or the allocated port collides with a container not managed by Krane, a new
+// host port is allocated from the host port range.
+func AllocateHostPort(deployment string, slot int, p PortConfig) (string, error) {
+	allocationMu.Lock()
+	defer allocationMu.Unlock()
+
+	allocations, err := GetPortAllocations()
+	if err != nil {
+		return "", err
+	}
+
+	boundPorts, externalPorts, err := getBoundHostPorts()
+	if err != nil {
+		return "", err
+	}
+
+	// reuse the existing allocation for the slot
+	for _, a := range allocations {
+		if a.Deployment != deployment || a.Slot != slot || a.ContainerPort != p.ContainerPort || a.Protocol != p.protocol() {
+			continue
+		}
+
+		if !externalPorts[allocationKey(a.HostPort, a.Protocol)] {
+			return a.HostPort, nil
+		}
+
+		logger.Warnf("Host port %s allocated to deployment %s is in use by a container not managed by Krane, re-allocating", a.HostPort, deployment)
+		if err := store.Client().Remove(constants.PortAllocationsCollectionName, allocationKey(a.HostPort, a.Protocol)); err != nil {
+			return "", err
+		}
+	}
+
+	claimed, err := getClaimedHostPorts(allocations)
+	if err != nil {
+		return "", err
+	}
+
+	start, end := hostPortRange()
+	for port := start; port <= end; port++ {
+		hostPort := strconv.Itoa(port)
+		key := allocationKey(hostPort, p.protocol())
+
+		if claimed[key] || boundPorts[key] {
+			continue
+		}
+
+		allocation := PortAllocation{
+			Deployment:    deployment,
+			Slot:          slot,
+			ContainerPort: p.ContainerPort,
+			Protocol:      p.protocol(),
+			HostPort:      hostPort,
+		}
+
+		bytes, _ := json.Marshal(allocation)
+		if err := store.Client().Put(constants.PortAllocationsCollectionName, key, bytes); err != nil {
+			return "", err
+		}
+
+		logger.Debugf("Allocated host port %s to deployment %s slot %d", hostPort, deployment, slot)
+		return hostPort, nil
+	}
+
+	return "", fmt.Errorf("no free host ports left in range %d-%d", start, end)
+}
+
+// GetPortAllocations returns all host port allocations
+func GetPortAllocations() ([]PortAllocation, error) {
+	bytes, err := store.Client().GetAll(constants.PortAllocationsCollectionName)
+	if err != nil {
+		return make([]PortAllocation, 0), err
+	}
+
+	allocations := make([]PortAllocation, 0)
+	for _, b := range bytes {
+		var a PortAllocation
+		if err := store.Deserialize(b, &a); err != nil {
+			return make([]PortAllocation, 0), err
+		}
+		allocations = append(allocations, a)
+	}
+
+	return allocations, nil
+}
+
+// GetPortAllocationsByDeployment returns the host port allocations for a deployment
+func GetPortAllocationsByDeployment(deployment string) ([]PortAllocation, error) {
+	allocations, err := GetPortAllocations()
+	if err != nil {
+		return make([]PortAllocation, 0), err
+	}
+
+	filtered := make([]PortAllocation, 0)
+	for _, a := range allocations {
+		if a.Deployment == deployment {
+			filtered = append(filtered, a)
+		}
+	}
+
+	return filtered, nil
+}
+
+// ReleasePortAllocations removes all host port allocations for a deployment
+func ReleasePortAllocations(deployment string) error {
+	return releasePortAllocations(deployment, func(PortAllocation) bool { return true })
+}
+
+// releaseUnusedPortAllocations removes host port allocations for container slots or
+// ports which are no longer part of a deployment configuration
+func releaseUnusedPortAllocations(config Config) error {
+	return releasePortAllocations(config.Name, func(a PortAllocation) bool {
+		if a.Slot >= config.Scale {
+			return true
+		}
+
+		for _, p := range config.Ports {
+			if p.HostPort == "" && p.ContainerPort == a.ContainerPort && p.protocol() == a.Protocol {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// releasePortAllocations removes the host port allocations for a deployment matching a filter
+func releasePortAllocations(deployment string, shouldRelease func(PortAllocation) bool) error {
+	allocationMu.Lock()
+	defer allocationMu.Unlock()
+
+	allocations, err := GetPortAllocationsByDeployment(deployment)
+	if err != nil {
+		return err
+	}
+
+	for _, a := range allocations {
+		if !shouldRelease(a) {
+			continue
+		}
+
+		logger.Debugf("Releasing host port %s allocated to deployment %s", a.HostPort, deployment)
+		if err := store.Client().Remove(constants.PortAllocationsCollectionName, allocationKey(a.HostPort, a.Protocol)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateAllocatedPortConflicts returns an error if a deployment explicitly claims a host port allocated to another deployment
+func validateAllocatedPortConflicts(config Config, allocations []PortAllocation) error {
+	for _, p := range config.Ports {
+		if p.HostPort == "" {
+			continue
+		}
+
+		for _, a := range allocations {
+			if a.Deployment != config.Name && a.HostPort == p.HostPort && a.Protocol == p.protocol() {
+				return fmt.Errorf("host port %s/%s is allocated to deployment %s", p.HostPort, p.protocol(), a.Deployment)
+			}
+		}
+	}
+
+	return nil
+}
+
+// getClaimedHostPorts returns the host ports allocated by Krane or explicitly declared in a deployment configuration
+func getClaimedHostPorts(allocations []PortAllocation) (map[string]bool, error) {
+	claimed := make(map[string]bool, 0)
+	for _, a := range allocations {
+		claimed[allocationKey(a.HostPort, a.Protocol)] = true
+	}
+
+	configs, err := GetAllDeploymentConfigs()
+	if err != nil {
+		return claimed, err
+	}
+
+	for _, config := range configs {
+		for _, p := range config.Ports {
+			if p.HostPort != "" {
+				claimed[allocationKey(p.HostPort, p.protocol())] = true
+			}
+		}
+	}
+
+	return claimed, nil
+}
+
+// getBoundHostPorts returns the host ports bound by containers, and the host ports bound by containers not
+// managed by Krane. Bindings are read from Docker rather than probing ports, since Krane may not share the
+// network namespace of the host.
+func getBoundHostPorts() (map[string]bool, map[string]bool, error) {
+	ctx := context.Background()
+	defer ctx.Done()
+
+	bound := make(map[string]bool, 0)
+	external := make(map[string]bool, 0)
+
+	containers, err := docker.GetClient().GetAllContainers(&ctx)
+	if err != nil {
+		return bound, external, err
+	}
+
+	for _, c := range containers {
+		ports := make([]Port, 0)
+		if c.HostConfig != nil {
+			ports = append(ports, fromPortMapToPortList(c.HostConfig.PortBindings)...)
+		}
+
+		// host ports assigned by Docker are only part of the network settings
+		if c.NetworkSettings != nil {
+			ports = append(ports, fromPortMapToPortList(c.NetworkSettings.Ports)...)
+		}
+
+		for _, p := range ports {
+			if p.HostPort == "" {
+				continue
+			}
+
+			key := allocationKey(p.HostPort, PortProtocol(p.Type))
+			bound[key] = true
+			if !isKraneManagedContainer(c) {
+				external[key] = true
+			}
+		}
+	}
+
+	return bound, external, nil
+}
+
+// hostPortRange returns the range of host ports Krane allocates from
+func hostPortRange() (int, int) {
+	start, err := strconv.Atoi(os.Getenv(constants.EnvHostPortRangeStart))
+	if err != nil || start <= 0 {
+		start = 30000
+	}
+
+	end, err := strconv.Atoi(os.Getenv(constants.EnvHostPortRangeEnd))
+	if err != nil || end > 65535 || end < start {
+		end = 32767
+	}
+
+	return start, end
+}
+
+func allocationKey(hostPort string, protocol PortProtocol) string {
+	return fmt.Sprintf("%s/%s", hostPort, protocol)
+}
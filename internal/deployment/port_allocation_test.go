@@ -0,0 +1,79 @@
+package deployment
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/krane/krane/internal/constants"
+	"github.com/krane/krane/internal/store"
+)
+
+func TestValidateAllocatedPortConflicts(t *testing.T) {
+	allocations := []PortAllocation{{Deployment: "api", Slot: 0, ContainerPort: "8080", Protocol: TCP, HostPort: "30000"}}
+
+	assert.Error(t, validateAllocatedPortConflicts(Config{Name: "web", Ports: PortConfigs{{HostPort: "30000", ContainerPort: "80", Protocol: TCP}}}, allocations))
+	assert.Nil(t, validateAllocatedPortConflicts(Config{Name: "web", Ports: PortConfigs{{HostPort: "30000", ContainerPort: "53", Protocol: UDP}}}, allocations))
+	assert.Nil(t, validateAllocatedPortConflicts(Config{Name: "api", Ports: PortConfigs{{HostPort: "30000", ContainerPort: "8080", Protocol: TCP}}}, allocations))
+}
+
+func TestHostPortRange(t *testing.T) {
+	_ = os.Setenv(constants.EnvHostPortRangeStart, "40000")
+	_ = os.Setenv(constants.EnvHostPortRangeEnd, "40010")
+	start, end := hostPortRange()
+	assert.Equal(t, 40000, start)
+	assert.Equal(t, 40010, end)
+
+	_ = os.Unsetenv(constants.EnvHostPortRangeStart)
+	_ = os.Unsetenv(constants.EnvHostPortRangeEnd)
+	start, end = hostPortRange()
+	assert.Equal(t, 30000, start)
+	assert.Equal(t, 32767, end)
+}
+
+func TestReleaseUnusedPortAllocations(t *testing.T) {
+	deployment := "port-allocation-test"
+	allocations := []PortAllocation{
+		{Deployment: deployment, Slot: 0, ContainerPort: "8080", Protocol: TCP, HostPort: "30100"},
+		{Deployment: deployment, Slot: 1, ContainerPort: "8080", Protocol: TCP, HostPort: "30101"},
+		{Deployment: deployment, Slot: 0, ContainerPort: "9090", Protocol: TCP, HostPort: "30102"},
+	}
+	for _, a := range allocations {
+		bytes, _ := json.Marshal(a)
+		assert.Nil(t, store.Client().Put(constants.PortAllocationsCollectionName, allocationKey(a.HostPort, a.Protocol), bytes))
+	}
+
+	// scaled down to 1 container and port 9090 removed from the configuration
+	config := Config{Name: deployment, Scale: 1, Ports: PortConfigs{{ContainerPort: "8080", Protocol: TCP}}}
+	assert.Nil(t, releaseUnusedPortAllocations(config))
+
+	remaining, err := GetPortAllocationsByDeployment(deployment)
+	assert.Nil(t, err)
+	assert.Equal(t, []PortAllocation{allocations[0]}, remaining)
+
+	assert.Nil(t, ReleasePortAllocations(deployment))
+	remaining, err = GetPortAllocationsByDeployment(deployment)
+	assert.Nil(t, err)
+	assert.Empty(t, remaining)
+}
+
+func TestDockerPortsFailsWhenRangeIsExhausted(t *testing.T) {
+	connectFakeDocker(t, &fakeDocker{})
+
+	_ = os.Setenv(constants.EnvHostPortRangeStart, "40100")
+	_ = os.Setenv(constants.EnvHostPortRangeEnd, "40100")
+	defer os.Unsetenv(constants.EnvHostPortRangeStart)
+	defer os.Unsetenv(constants.EnvHostPortRangeEnd)
+	defer ReleasePortAllocations("port-range-test")
+
+	config := Config{Name: "port-range-test", Scale: 2, Ports: PortConfigs{{ContainerPort: "8080", Protocol: TCP}}}
+	ports, err := config.DockerPorts(0)
+	assert.Nil(t, err)
+	assert.Equal(t, "40100", ports["8080/tcp"][0].HostPort)
+
+	// the only host port of the range is taken by the first slot, the second slot can't start without it
+	_, err = config.DockerPorts(1)
+	assert.Error(t, err)
+}
@@ -0,0 +1,101 @@
+package deployment
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePortConfig(t *testing.T) {
+	p, err := ParsePortConfig("8080")
+	assert.Nil(t, err)
+	assert.Equal(t, PortConfig{ContainerPort: "8080", Protocol: TCP}, p)
+
+	p, err = ParsePortConfig("80:8080")
+	assert.Nil(t, err)
+	assert.Equal(t, PortConfig{HostPort: "80", ContainerPort: "8080", Protocol: TCP}, p)
+
+	p, err = ParsePortConfig("53:53/udp")
+	assert.Nil(t, err)
+	assert.Equal(t, PortConfig{HostPort: "53", ContainerPort: "53", Protocol: UDP}, p)
+
+	p, err = ParsePortConfig("127.0.0.1:5432:5432")
+	assert.Nil(t, err)
+	assert.Equal(t, PortConfig{HostIP: "127.0.0.1", HostPort: "5432", ContainerPort: "5432", Protocol: TCP}, p)
+
+	p, err = ParsePortConfig("127.0.0.1::5432")
+	assert.Nil(t, err)
+	assert.Equal(t, PortConfig{HostIP: "127.0.0.1", ContainerPort: "5432", Protocol: TCP}, p)
+
+	p, err = ParsePortConfig("[::1]:8080:80")
+	assert.Nil(t, err)
+	assert.Equal(t, PortConfig{HostIP: "::1", HostPort: "8080", ContainerPort: "80", Protocol: TCP}, p)
+
+	p, err = ParsePortConfig("[::]:53:53/udp")
+	assert.Nil(t, err)
+	assert.Equal(t, PortConfig{HostIP: "::", HostPort: "53", ContainerPort: "53", Protocol: UDP}, p)
+}
+
+func TestParseInvalidPortConfig(t *testing.T) {
+	invalid := []string{"", "http", "0", "65536", "80:http", "localhost:80:80", "80:80/sctp", "1:2:3:4", "::1:8080:80", "[::1]:80", "[::1:8080:80", "[localhost]:80:80"}
+	for _, raw := range invalid {
+		_, err := ParsePortConfig(raw)
+		assert.Error(t, err, raw)
+	}
+}
+
+func TestPortConfigJSONRoundTrip(t *testing.T) {
+	ports := PortConfigs{
+		{HostIP: "127.0.0.1", HostPort: "5432", ContainerPort: "5432", Protocol: TCP},
+		{ContainerPort: "53", Protocol: UDP},
+		{HostIP: "::1", HostPort: "8080", ContainerPort: "80", Protocol: TCP},
+	}
+
+	bytes, err := json.Marshal(ports)
+	assert.Nil(t, err)
+	assert.Equal(t, `["127.0.0.1:5432:5432/tcp","53/udp","[::1]:8080:80/tcp"]`, string(bytes))
+
+	var parsed PortConfigs
+	assert.Nil(t, json.Unmarshal(bytes, &parsed))
+	assert.Equal(t, ports, parsed)
+}
+
+func TestUnmarshalLegacyPortMap(t *testing.T) {
+	var config Config
+	err := json.Unmarshal([]byte(`{"ports": {"80": "9000", "127.0.0.1:53": "53/udp"}}`), &config)
+	assert.Nil(t, err)
+	assert.Len(t, config.Ports, 2)
+	assert.Contains(t, config.Ports, PortConfig{HostPort: "80", ContainerPort: "9000", Protocol: TCP})
+	assert.Contains(t, config.Ports, PortConfig{HostIP: "127.0.0.1", HostPort: "53", ContainerPort: "53", Protocol: UDP})
+}
+
+func TestPortConflicts(t *testing.T) {
+	api := Config{Name: "api", Ports: PortConfigs{{HostPort: "8080", ContainerPort: "8080", Protocol: TCP}}}
+
+	// same host port and protocol
+	assert.Error(t, validatePortConflicts(Config{Name: "web", Ports: PortConfigs{{HostPort: "8080", ContainerPort: "80", Protocol: TCP}}}, []Config{api}))
+
+	// same host port but different protocol
+	assert.Nil(t, validatePortConflicts(Config{Name: "dns", Ports: PortConfigs{{HostPort: "8080", ContainerPort: "53", Protocol: UDP}}}, []Config{api}))
+
+	// same host port bound to different interfaces
+	local := Config{Name: "db", Ports: PortConfigs{{HostIP: "127.0.0.1", HostPort: "5432", ContainerPort: "5432", Protocol: TCP}}}
+	assert.Nil(t, validatePortConflicts(Config{Name: "db2", Ports: PortConfigs{{HostIP: "10.0.0.1", HostPort: "5432", ContainerPort: "5432", Protocol: TCP}}}, []Config{local}))
+	assert.Error(t, validatePortConflicts(Config{Name: "db3", Ports: PortConfigs{{HostPort: "5432", ContainerPort: "5432", Protocol: TCP}}}, []Config{local}))
+
+	// the IPv6 unspecified address binds all interfaces
+	assert.Error(t, validatePortConflicts(Config{Name: "db4", Ports: PortConfigs{{HostIP: "::", HostPort: "5432", ContainerPort: "5432", Protocol: TCP}}}, []Config{local}))
+
+	// randomly assigned host ports never conflict
+	assert.Nil(t, validatePortConflicts(Config{Name: "web", Ports: PortConfigs{{ContainerPort: "8080", Protocol: TCP}}}, []Config{api}))
+
+	// a deployment does not conflict with its own saved configuration
+	assert.Nil(t, validatePortConflicts(api, []Config{api}))
+
+	// duplicate host ports within the same deployment
+	assert.Error(t, validatePortConflicts(Config{Name: "web", Ports: PortConfigs{
+		{HostPort: "80", ContainerPort: "80", Protocol: TCP},
+		{HostPort: "80", ContainerPort: "8080", Protocol: TCP},
+	}}, []Config{}))
+}
@@ -0,0 +1,398 @@
+package deployment
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/krane/krane/internal/proxy"
+	"github.com/krane/krane/internal/proxy/middlewares"
+)
+
+// default load balancer health check interval and timeout
+const (
+	defaultHealthCheckInterval = "10s"
+	defaultHealthCheckTimeout  = "3s"
+)
+
+// defaultHSTSMaxAge is one year, the minimum max-age accepted for HSTS preloading
+const defaultHSTSMaxAge = 31536000
+
+// headerNameRegex matches valid HTTP header names
+var headerNameRegex = regexp.MustCompile("^[A-Za-z0-9!#$%&'*+.^_`|~-]+$")
+
+// ProxyConfig represents how the proxy handles requests for a deployment
+type ProxyConfig struct {
+	Middlewares  MiddlewaresConfig  `json:"middlewares"`   // middlewares applied to requests before reaching the containers
+	LoadBalancer LoadBalancerConfig `json:"load_balancer"` // how requests are balanced between the containers
+}
+
+// MiddlewaresConfig represents the configurable proxy middlewares of a deployment
+type MiddlewaresConfig struct {
+	Headers  HeadersConfig `json:"headers"`  // custom request and response headers
+	HSTS     *HSTSConfig   `json:"hsts"`     // Strict-Transport-Security header sent with responses
+	CORS     *CORSConfig   `json:"cors"`     // cross-origin resource sharing policy
+	Compress bool          `json:"compress"` // compress responses (gzip)
+}
+
+// HeadersConfig represents headers set by the proxy, an empty value removes the header
+type HeadersConfig struct {
+	Request  map[string]string `json:"request"`  // headers set on requests before reaching the containers
+	Response map[string]string `json:"response"` // headers set on responses sent to clients
+}
+
+// HSTSConfig represents the Strict-Transport-Security header sent with responses
+type HSTSConfig struct {
+	MaxAge            int64 `json:"max_age"`            // seconds browsers only use HTTPS for the host (default 1 year)
+	IncludeSubdomains bool  `json:"include_subdomains"` // apply to all subdomains of the host
+	Preload           bool  `json:"preload"`            // allow the host to be added to browser preload lists
+}
+
+// CORSConfig represents the cross-origin resource sharing policy of a deployment
+type CORSConfig struct {
+	AllowOrigins     []string `json:"allow_origins"`     // origins allowed to make requests (* for any origin)
+	AllowMethods     []string `json:"allow_methods"`     // methods allowed in cross-origin requests
+	AllowHeaders     []string `json:"allow_headers"`     // request headers allowed in cross-origin requests
+	ExposeHeaders    []string `json:"expose_headers"`    // response headers readable by cross-origin clients
+	AllowCredentials bool     `json:"allow_credentials"` // allow cookies and authorization headers in cross-origin requests
+	MaxAge           int64    `json:"max_age"`           // seconds preflight responses can be cached
+}
+
+// LoadBalancerConfig represents how the proxy balances requests between the containers of a deployment
+type LoadBalancerConfig struct {
+	Sticky         *StickyConfig      `json:"sticky"`           // pin clients to a container with a cookie
+	HealthCheck    *HealthCheckConfig `json:"health_check"`     // stop sending requests to containers failing the check
+	PassHostHeader *bool              `json:"pass_host_header"` // forward the client Host header to the containers (default true)
+}
+
+// StickyConfig represents the cookie pinning clients to a container
+type StickyConfig struct {
+	Cookie   string `json:"cookie"`    // cookie name (default generated by the proxy)
+	Secure   bool   `json:"secure"`    // only send the cookie over HTTPS
+	HTTPOnly bool   `json:"http_only"` // hide the cookie from scripts
+	SameSite string `json:"same_site"` // none, lax or strict
+}
+
+// HealthCheckConfig represents the requests the proxy sends to containers to check they can receive traffic
+type HealthCheckConfig struct {
+	Path     string `json:"path"`     // path requested on the containers, a status outside 2xx and 3xx marks a container unhealthy
+	Interval string `json:"interval"` // time between checks (default 10s)
+	Timeout  string `json:"timeout"`  // time to wait for a response (default 3s)
+}
+
+// applyDefaults applies default proxy configuration values
+func (p *ProxyConfig) applyDefaults() {
+	headers := &p.Middlewares.Headers
+	if headers.Request == nil {
+		headers.Request = make(map[string]string, 0)
+	}
+
+	if headers.Response == nil {
+		headers.Response = make(map[string]string, 0)
+	}
+
+	if p.Middlewares.HSTS != nil && p.Middlewares.HSTS.MaxAge == 0 {
+		p.Middlewares.HSTS.MaxAge = defaultHSTSMaxAge
+	}
+
+	if cors := p.Middlewares.CORS; cors != nil {
+		for i := range cors.AllowMethods {
+			cors.AllowMethods[i] = strings.ToUpper(cors.AllowMethods[i])
+		}
+	}
+
+	if sticky := p.LoadBalancer.Sticky; sticky != nil {
+		sticky.SameSite = strings.ToLower(sticky.SameSite)
+	}
+
+	if check := p.LoadBalancer.HealthCheck; check != nil {
+		if check.Interval == "" {
+			check.Interval = defaultHealthCheckInterval
+		}
+
+		if check.Timeout == "" {
+			check.Timeout = defaultHealthCheckTimeout
+		}
+	}
+}
+
+// isValid returns an error if a proxy configuration is not valid
+func (p ProxyConfig) isValid() error {
+	mw := p.Middlewares
+
+	for _, headers := range []map[string]string{mw.Headers.Request, mw.Headers.Response} {
+		for header := range headers {
+			if !headerNameRegex.MatchString(header) {
+				return fmt.Errorf("invalid header name %s", header)
+			}
+		}
+	}
+
+	if mw.HSTS != nil && mw.HSTS.MaxAge < 0 {
+		return fmt.Errorf("invalid hsts max age %d", mw.HSTS.MaxAge)
+	}
+
+	if mw.CORS != nil {
+		if err := mw.CORS.isValid(); err != nil {
+			return err
+		}
+	}
+
+	return p.LoadBalancer.isValid()
+}
+
+// isValid returns an error if a load balancer configuration is not valid
+func (lb LoadBalancerConfig) isValid() error {
+	if sticky := lb.Sticky; sticky != nil {
+		if sticky.Cookie != "" && !headerNameRegex.MatchString(sticky.Cookie) {
+			return fmt.Errorf("invalid sticky cookie name %s", sticky.Cookie)
+		}
+
+		switch sticky.SameSite {
+		case "", "none", "lax", "strict":
+		default:
+			return fmt.Errorf("invalid sticky cookie same site %s, must be none, lax or strict", sticky.SameSite)
+		}
+	}
+
+	if check := lb.HealthCheck; check != nil {
+		if !strings.HasPrefix(check.Path, "/") {
+			return fmt.Errorf("invalid health check path %s, must start with /", check.Path)
+		}
+
+		for _, d := range []string{check.Interval, check.Timeout} {
+			if duration, err := time.ParseDuration(d); err != nil || duration <= 0 {
+				return fmt.Errorf("invalid health check duration %s", d)
+			}
+		}
+	}
+
+	return nil
+}
+
+// isValid returns an error if a CORS policy is not valid
+func (cors CORSConfig) isValid() error {
+	if len(cors.AllowOrigins) == 0 {
+		return errors.New("cors requires at least one allowed origin")
+	}
+
+	for _, origin := range cors.AllowOrigins {
+		if origin == "*" {
+			if cors.AllowCredentials {
+				return errors.New("cors can't allow credentials for any origin (*)")
+			}
+			continue
+		}
+
+		if !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+			return fmt.Errorf("invalid cors origin %s", origin)
+		}
+	}
+
+	for _, method := range cors.AllowMethods {
+		if !isHTTPMethod(method) {
+			return fmt.Errorf("invalid cors method %s", method)
+		}
+	}
+
+	for _, header := range append(cors.AllowHeaders, cors.ExposeHeaders...) {
+		if header != "*" && !headerNameRegex.MatchString(header) {
+			return fmt.Errorf("invalid cors header %s", header)
+		}
+	}
+
+	if cors.MaxAge < 0 {
+		return fmt.Errorf("invalid cors max age %d", cors.MaxAge)
+	}
+
+	return nil
+}
+
+// isHTTPMethod returns true if a method is a known HTTP method
+func isHTTPMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// hsts returns the proxy HSTS middleware options, nil if not configured
+func (mw MiddlewaresConfig) hsts() *middlewares.HSTS {
+	if mw.HSTS == nil {
+		return nil
+	}
+
+	return &middlewares.HSTS{
+		MaxAge:            mw.HSTS.MaxAge,
+		IncludeSubdomains: mw.HSTS.IncludeSubdomains,
+		Preload:           mw.HSTS.Preload,
+	}
+}
+
+// cors returns the proxy CORS middleware options, nil if not configured
+func (mw MiddlewaresConfig) cors() *middlewares.CORS {
+	if mw.CORS == nil {
+		return nil
+	}
+
+	return &middlewares.CORS{
+		AllowOrigins:     mw.CORS.AllowOrigins,
+		AllowMethods:     mw.CORS.AllowMethods,
+		AllowHeaders:     mw.CORS.AllowHeaders,
+		ExposeHeaders:    mw.CORS.ExposeHeaders,
+		AllowCredentials: mw.CORS.AllowCredentials,
+		MaxAge:           mw.CORS.MaxAge,
+	}
+}
+
+// loadBalancer returns the proxy load balancing options
+func (lb LoadBalancerConfig) loadBalancer() proxy.LoadBalancer {
+	options := proxy.LoadBalancer{PassHostHeader: lb.PassHostHeader}
+
+	if lb.Sticky != nil {
+		options.Sticky = &proxy.StickyCookie{
+			Name:     lb.Sticky.Cookie,
+			Secure:   lb.Sticky.Secure,
+			HTTPOnly: lb.Sticky.HTTPOnly,
+			SameSite: lb.Sticky.SameSite,
+		}
+	}
+
+	if lb.HealthCheck != nil {
+		options.HealthCheck = &proxy.HealthCheck{
+			Path:     lb.HealthCheck.Path,
+			Interval: lb.HealthCheck.Interval,
+			Timeout:  lb.HealthCheck.Timeout,
+		}
+	}
+
+	return options
+}
+
+// proxySite returns what the proxy needs to route requests to the containers of a deployment,
+// with the routers, middlewares and services named after name (the deployment name unless the
+// containers run as a color)
+func (config Config) proxySite(name string) proxy.Site {
+	return proxy.Site{
+		Deployment: name,
+		Aliases:    config.Alias,
+		Routes:     config.proxyRoutes(),
+		TCP:        config.proxyTCPRoutes(),
+		UDP:        config.proxyUDPRoutes(),
+		TLS: proxy.TLS{
+			Enabled:      config.Secure,
+			CertResolver: config.certResolver(),
+			Certificate:  config.Certificate,
+		},
+		Middlewares: proxy.Middlewares{
+			RateLimit:      config.RateLimit,
+			BasicAuth:      config.BasicAuth.enabled(),
+			BasicAuthUsers: config.proxyBasicAuthUsers(),
+			BasicAuthRealm: config.basicAuthRealm(),
+			IPAllowList:    config.IPAllowList,
+
+			RequestHeaders:  config.Proxy.Middlewares.Headers.Request,
+			ResponseHeaders: config.Proxy.Middlewares.Headers.Response,
+			HSTS:            config.Proxy.Middlewares.hsts(),
+			CORS:            config.Proxy.Middlewares.cors(),
+			Compress:        config.Proxy.Middlewares.Compress,
+		},
+		Upstream: proxy.Upstream{
+			Host:         config.Name,
+			Ports:        config.httpContainerPorts(),
+			TargetPort:   config.TargetPort,
+			LoadBalancer: config.Proxy.LoadBalancer.loadBalancer(),
+		},
+	}
+}
+
+// proxyFeatures returns the features of a deployment not every proxy backend can configure
+func (config Config) proxyFeatures() []proxy.Feature {
+	features := make([]proxy.Feature, 0)
+	if config.Canary != nil {
+		features = append(features, proxy.CanaryFeature)
+	}
+
+	if config.Strategy == BlueGreenStrategy {
+		features = append(features, proxy.BlueGreenFeature)
+	}
+
+	if len(config.TCP) > 0 {
+		features = append(features, proxy.TCPFeature)
+	}
+
+	if len(config.UDP) > 0 {
+		features = append(features, proxy.UDPFeature)
+	}
+
+	if config.RateLimit > 0 {
+		features = append(features, proxy.RateLimitFeature)
+	}
+
+	if config.Proxy.LoadBalancer.HealthCheck != nil {
+		features = append(features, proxy.HealthCheckFeature)
+	}
+
+	return features
+}
+
+// isValidProxyBackend returns an error if a deployment uses a feature the proxy backend can't configure
+func (config Config) isValidProxyBackend() error {
+	backend := proxy.GetBackend()
+	for _, feature := range config.proxyFeatures() {
+		if !backend.Supports(feature) {
+			return fmt.Errorf("%s is not supported by the %s proxy", feature, backend.Name())
+		}
+	}
+	return nil
+}
+
+// proxyMu guards writing the proxy configuration files of deployments
+var proxyMu sync.Mutex
+
+// SyncProxy writes the proxy configuration files of all deployments and the pages of deployments in maintenance
+func SyncProxy() error {
+	proxyMu.Lock()
+	defer proxyMu.Unlock()
+
+	configs, err := GetAllDeploymentConfigs()
+	if err != nil {
+		return err
+	}
+
+	all, err := getAllMaintenance()
+	if err != nil {
+		return err
+	}
+
+	// pages are written before requests are routed to the maintenance responder
+	pages := make([]proxy.MaintenancePage, 0)
+	inMaintenance := make(map[string]bool, 0)
+	for _, maintenance := range all {
+		pages = append(pages, proxy.MaintenancePage{
+			Deployment: maintenance.Deployment,
+			HTML:       maintenance.Page,
+			RetryAfter: maintenance.RetryAfter,
+		})
+		inMaintenance[maintenance.Deployment] = true
+	}
+
+	if err := proxy.WriteMaintenancePages(pages); err != nil {
+		return err
+	}
+
+	sites := make([]proxy.Site, 0)
+	for _, config := range configs {
+		site := config.proxySite(config.Name)
+		site.Maintenance = inMaintenance[config.Name]
+		sites = append(sites, site)
+	}
+
+	return proxy.GetBackend().WriteConfig(sites)
+}
@@ -0,0 +1,145 @@
+package deployment
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/krane/krane/internal/constants"
+	"github.com/krane/krane/internal/proxy"
+)
+
+func TestProxyConfigDefaults(t *testing.T) {
+	p := ProxyConfig{Middlewares: MiddlewaresConfig{
+		HSTS: &HSTSConfig{},
+		CORS: &CORSConfig{AllowOrigins: []string{"*"}, AllowMethods: []string{"get", "post"}},
+	}}
+	p.applyDefaults()
+
+	assert.Equal(t, int64(defaultHSTSMaxAge), p.Middlewares.HSTS.MaxAge)
+	assert.Equal(t, []string{"GET", "POST"}, p.Middlewares.CORS.AllowMethods)
+	assert.NotNil(t, p.Middlewares.Headers.Request)
+	assert.Nil(t, p.isValid())
+}
+
+func TestInvalidProxyConfig(t *testing.T) {
+	invalid := []ProxyConfig{
+		{Middlewares: MiddlewaresConfig{Headers: HeadersConfig{Response: map[string]string{"X Frame": "DENY"}}}},
+		{Middlewares: MiddlewaresConfig{HSTS: &HSTSConfig{MaxAge: -1}}},
+		{Middlewares: MiddlewaresConfig{CORS: &CORSConfig{}}},
+		{Middlewares: MiddlewaresConfig{CORS: &CORSConfig{AllowOrigins: []string{"*"}, AllowCredentials: true}}},
+		{Middlewares: MiddlewaresConfig{CORS: &CORSConfig{AllowOrigins: []string{"example.com"}}}},
+		{Middlewares: MiddlewaresConfig{CORS: &CORSConfig{AllowOrigins: []string{"https://example.com"}, AllowMethods: []string{"FETCH"}}}},
+		{LoadBalancer: LoadBalancerConfig{Sticky: &StickyConfig{Cookie: "my cookie"}}},
+		{LoadBalancer: LoadBalancerConfig{Sticky: &StickyConfig{SameSite: "always"}}},
+		{LoadBalancer: LoadBalancerConfig{HealthCheck: &HealthCheckConfig{Path: "health", Interval: "10s", Timeout: "3s"}}},
+		{LoadBalancer: LoadBalancerConfig{HealthCheck: &HealthCheckConfig{Path: "/health", Interval: "often", Timeout: "3s"}}},
+	}
+
+	for _, p := range invalid {
+		assert.Error(t, p.isValid())
+	}
+}
+
+func TestProxyMiddlewareLabels(t *testing.T) {
+	config := Config{
+		Name:  "api",
+		Image: "biensupernice/api",
+		Proxy: ProxyConfig{Middlewares: MiddlewaresConfig{HSTS: &HSTSConfig{Preload: true}, Compress: true}},
+	}
+	config.applyDefaults()
+	config.ApplyProxyLabels()
+
+	assert.Equal(t, "api-headers,api-ratelimit,api-compress", config.Labels["traefik.http.routers.api-insecure.middlewares"])
+	assert.Equal(t, "31536000", config.Labels["traefik.http.middlewares.api-headers.headers.stsseconds"])
+	assert.Equal(t, "true", config.Labels["traefik.http.middlewares.api-headers.headers.stspreload"])
+}
+
+func TestProxyLoadBalancerLabels(t *testing.T) {
+	passHostHeader := false
+	config := storedConfig(Config{
+		Name:       "api",
+		Image:      "biensupernice/api",
+		TargetPort: "8080",
+		Proxy: ProxyConfig{LoadBalancer: LoadBalancerConfig{
+			Sticky:         &StickyConfig{Cookie: "api_session", Secure: true, SameSite: "Lax"},
+			HealthCheck:    &HealthCheckConfig{Path: "/health"},
+			PassHostHeader: &passHostHeader,
+		}},
+	})
+	assert.Nil(t, config.isValid())
+	assert.Equal(t, defaultHealthCheckInterval, config.Proxy.LoadBalancer.HealthCheck.Interval)
+
+	config.ApplyProxyLabels()
+	labels := config.Labels
+
+	assert.Equal(t, "true", labels["traefik.http.services.api.loadbalancer.sticky.cookie"])
+	assert.Equal(t, "api_session", labels["traefik.http.services.api.loadbalancer.sticky.cookie.name"])
+	assert.Equal(t, "true", labels["traefik.http.services.api.loadbalancer.sticky.cookie.secure"])
+	assert.Equal(t, "lax", labels["traefik.http.services.api.loadbalancer.sticky.cookie.samesite"])
+	assert.Equal(t, "/health", labels["traefik.http.services.api.loadbalancer.healthcheck.path"])
+	assert.Equal(t, "10s", labels["traefik.http.services.api.loadbalancer.healthcheck.interval"])
+	assert.Equal(t, "3s", labels["traefik.http.services.api.loadbalancer.healthcheck.timeout"])
+	assert.Equal(t, "false", labels["traefik.http.services.api.loadbalancer.passhostheader"])
+}
+
+func TestProxyBackendFeatures(t *testing.T) {
+	_ = os.Setenv(constants.EnvProxyBackend, proxy.CaddyBackendName)
+	defer os.Unsetenv(constants.EnvProxyBackend)
+
+	config := storedConfig(Config{Name: "api", Image: "biensupernice/api", Alias: []string{"api.example.com"}})
+	assert.Nil(t, config.isValid())
+	assert.Empty(t, config.DockerLabels()["traefik.enable"])
+
+	unsupported := []Config{
+		{Name: "api", Image: "biensupernice/api", RateLimit: 10},
+		{Name: "api", Image: "biensupernice/api", Strategy: BlueGreenStrategy},
+		{Name: "api", Image: "biensupernice/api", Proxy: ProxyConfig{LoadBalancer: LoadBalancerConfig{HealthCheck: &HealthCheckConfig{Path: "/health"}}}},
+	}
+
+	for _, c := range unsupported {
+		assert.Error(t, storedConfig(c).isValid())
+	}
+}
+
+func TestSyncProxy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "krane-proxy")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	_ = os.Setenv(constants.EnvProxyConfigDir, dir)
+	defer os.Unsetenv(constants.EnvProxyConfigDir)
+
+	_ = os.Setenv(constants.EnvProxyBackend, proxy.CaddyBackendName)
+	defer os.Unsetenv(constants.EnvProxyBackend)
+
+	assert.Nil(t, SaveConfig(Config{Name: "sync-api", Image: "biensupernice/api", Alias: []string{"sync.example.com"}, TargetPort: "8080"}))
+	assert.Nil(t, SyncProxy())
+
+	caddyfile, err := ioutil.ReadFile(filepath.Join(dir, proxy.CaddyfileName))
+	assert.Nil(t, err)
+	assert.Contains(t, string(caddyfile), "http://sync.example.com {")
+	assert.Contains(t, string(caddyfile), "name sync-api\n")
+
+	assert.Nil(t, DeleteConfig("sync-api"))
+	assert.Nil(t, SyncProxy())
+
+	caddyfile, err = ioutil.ReadFile(filepath.Join(dir, proxy.CaddyfileName))
+	assert.Nil(t, err)
+	assert.NotContains(t, string(caddyfile), "sync.example.com")
+}
+
+func TestNetworkAliases(t *testing.T) {
+	config := Config{Name: "api", Alias: []string{"api.example.com"}}
+	assert.Equal(t, []string{"api.example.com"}, config.networkAliases())
+
+	// caddy resolves the deployment name to its containers
+	_ = os.Setenv(constants.EnvProxyBackend, proxy.CaddyBackendName)
+	defer os.Unsetenv(constants.EnvProxyBackend)
+
+	assert.Equal(t, []string{"api.example.com", "api"}, config.networkAliases())
+	assert.Equal(t, []string{"api.example.com"}, config.Alias)
+}
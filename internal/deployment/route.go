@@ -0,0 +1,129 @@
+package deployment
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/krane/krane/internal/proxy"
+)
+
+// RouteConfig represents a host and path prefix routed to a deployment. Routes allow
+// several deployments to share the same host, for example / and /api on example.com.
+type RouteConfig struct {
+	Host        string `json:"host"`         // host matched by the route (my-app.example.com)
+	Path        string `json:"path"`         // path prefix matched by the route (default /)
+	StripPrefix bool   `json:"strip_prefix"` // remove the path prefix before forwarding requests to the containers
+}
+
+// applyDefaults applies default route configuration values
+func (route *RouteConfig) applyDefaults() {
+	route.Host = strings.ToLower(route.Host)
+
+	if route.Path == "" {
+		route.Path = "/"
+	}
+
+	// /api/ and /api match the same requests
+	if route.Path != "/" {
+		route.Path = strings.TrimRight(route.Path, "/")
+	}
+}
+
+// isValid returns an error if a route configuration is not valid
+func (route RouteConfig) isValid() error {
+	if route.Host == "" {
+		return errors.New("route host required")
+	}
+
+	if strings.ContainsAny(route.Host, "`/ ") {
+		return fmt.Errorf("invalid route host %s", route.Host)
+	}
+
+	if !strings.HasPrefix(route.Path, "/") {
+		return fmt.Errorf("route path %s must start with /", route.Path)
+	}
+
+	if strings.ContainsAny(route.Path, "` ") {
+		return fmt.Errorf("invalid route path %s", route.Path)
+	}
+
+	if route.StripPrefix && route.Path == "/" {
+		return fmt.Errorf("route %s strips a prefix but has no path", route.Host)
+	}
+
+	return nil
+}
+
+// String returns the host and path of the route (example.com/api)
+func (route RouteConfig) String() string {
+	if route.Path == "/" {
+		return route.Host
+	}
+	return route.Host + route.Path
+}
+
+// toProxyRoute returns the proxy route for a route configuration
+func (route RouteConfig) toProxyRoute() proxy.Route {
+	return proxy.Route{
+		Host:        route.Host,
+		PathPrefix:  route.Path,
+		StripPrefix: route.StripPrefix,
+	}
+}
+
+// proxyRoutes returns the proxy routes of a deployment
+func (config Config) proxyRoutes() []proxy.Route {
+	routes := make([]proxy.Route, 0)
+	for _, route := range config.Routes {
+		routes = append(routes, route.toProxyRoute())
+	}
+	return routes
+}
+
+// claimedRoutes returns the host and paths a deployment receives traffic for,
+// aliases claim the root path of their host
+func (config Config) claimedRoutes() []RouteConfig {
+	claimed := make([]RouteConfig, 0)
+	for _, alias := range config.Alias {
+		if alias == "" {
+			continue
+		}
+		claimed = append(claimed, RouteConfig{Host: strings.ToLower(alias), Path: "/"})
+	}
+
+	for _, route := range config.Routes {
+		claimed = append(claimed, route)
+	}
+
+	return claimed
+}
+
+// validateRouteConflicts returns an error if a deployment declares a route more than once
+// or claims a host and path already routed to another deployment
+func validateRouteConflicts(config Config, others []Config) error {
+	routes := make(map[string]bool, 0)
+	for _, route := range config.Routes {
+		if routes[route.String()] {
+			return fmt.Errorf("route %s is declared more than once", route.String())
+		}
+		routes[route.String()] = true
+	}
+
+	claimed := config.claimedRoutes()
+	for _, d := range others {
+		if d.Name == config.Name {
+			continue
+		}
+
+		for _, other := range d.claimedRoutes() {
+			for _, route := range claimed {
+				if route.String() == other.String() {
+					return fmt.Errorf("route %s is already claimed by deployment %s", route.String(), d.Name)
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package deployment
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteConfigDefaults(t *testing.T) {
+	route := RouteConfig{Host: "Example.com"}
+	route.applyDefaults()
+	assert.Equal(t, RouteConfig{Host: "example.com", Path: "/"}, route)
+
+	route = RouteConfig{Host: "example.com", Path: "/api/", StripPrefix: true}
+	route.applyDefaults()
+	assert.Equal(t, RouteConfig{Host: "example.com", Path: "/api", StripPrefix: true}, route)
+	assert.Equal(t, "example.com/api", route.String())
+}
+
+func TestInvalidRouteConfig(t *testing.T) {
+	invalid := []RouteConfig{
+		{Path: "/api"},
+		{Host: "example.com/api", Path: "/"},
+		{Host: "example.com", Path: "api"},
+		{Host: "example.com", Path: "/", StripPrefix: true},
+	}
+	for _, route := range invalid {
+		assert.Error(t, route.isValid(), route.String())
+	}
+
+	assert.Nil(t, RouteConfig{Host: "example.com", Path: "/api", StripPrefix: true}.isValid())
+}
+
+func TestValidateRouteConflicts(t *testing.T) {
+	web := Config{Name: "web", Alias: []string{"example.com"}}
+	api := Config{Name: "api", Routes: []RouteConfig{{Host: "example.com", Path: "/api", StripPrefix: true}}}
+	assert.Nil(t, validateRouteConflicts(api, []Config{web, api}))
+
+	// same host and path as another deployment
+	other := Config{Name: "other", Routes: []RouteConfig{{Host: "example.com", Path: "/api"}}}
+	assert.EqualError(t, validateRouteConflicts(other, []Config{web, api}), "route example.com/api is already claimed by deployment api")
+
+	// root path of a host aliased by another deployment
+	other = Config{Name: "other", Routes: []RouteConfig{{Host: "example.com", Path: "/"}}}
+	assert.EqualError(t, validateRouteConflicts(other, []Config{web, api}), "route example.com is already claimed by deployment web")
+
+	// route declared twice
+	other = Config{Name: "other", Routes: []RouteConfig{{Host: "example.org", Path: "/"}, {Host: "example.org", Path: "/"}}}
+	assert.EqualError(t, validateRouteConflicts(other, []Config{}), "route example.org is declared more than once")
+}
+
+func TestProxyRouteLabels(t *testing.T) {
+	config := Config{
+		Name:   "api",
+		Image:  "biensupernice/api",
+		Routes: []RouteConfig{{Host: "example.com", Path: "/api/", StripPrefix: true}},
+	}
+	config.applyDefaults()
+	config.ApplyProxyLabels()
+
+	assert.Equal(t, "Host(`example.com`) && PathPrefix(`/api`)", config.Labels["traefik.http.routers.api-route-0-insecure.rule"])
+	assert.Equal(t, "1004", config.Labels["traefik.http.routers.api-route-0-insecure.priority"])
+	assert.Equal(t, "api-ratelimit,api-route-0-stripprefix", config.Labels["traefik.http.routers.api-route-0-insecure.middlewares"])
+	assert.Equal(t, "/api", config.Labels["traefik.http.middlewares.api-route-0-stripprefix.stripprefix.prefixes"])
+}
@@ -0,0 +1,327 @@
+package deployment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution/uuid"
+
+	"github.com/krane/krane/internal/constants"
+	"github.com/krane/krane/internal/docker"
+	"github.com/krane/krane/internal/job"
+	"github.com/krane/krane/internal/logger"
+	"github.com/krane/krane/internal/scheduler/cron"
+	"github.com/krane/krane/internal/store"
+)
+
+// ConcurrencyPolicy controls what happens when a schedule fires while a previous run is still queued or running
+type ConcurrencyPolicy string
+
+const (
+	AllowConcurrent   ConcurrencyPolicy = "allow"   // runs are queued regardless of previous runs
+	ForbidConcurrent  ConcurrencyPolicy = "forbid"  // the run is skipped while a previous run is active
+	ReplaceConcurrent ConcurrencyPolicy = "replace" // previous active runs are cancelled in favour of the new run
+)
+
+// MissedRunPolicy controls what happens to runs missed while Krane was not running
+type MissedRunPolicy string
+
+const (
+	RunOnceMissedRuns MissedRunPolicy = "run_once" // the latest missed run is run once Krane starts
+	SkipMissedRuns    MissedRunPolicy = "skip"     // missed runs are skipped
+)
+
+// ScheduleConfig represents a one-off command run with the deployment configuration on a cron schedule
+type ScheduleConfig struct {
+	Name              string            `json:"name"`               // schedule name, unique within the deployment
+	Schedule          string            `json:"schedule"`           // cron expression evaluated in UTC (ie. "0 2 * * *")
+	Command           Args              `json:"command"`            // task command (default deployment command)
+	Entrypoint        Args              `json:"entrypoint"`         // task entrypoint (default deployment entrypoint)
+	Env               map[string]string `json:"env"`                // environment variables added to the deployment environment variables
+	Timeout           uint              `json:"timeout"`            // seconds to wait for the task to exit (default 0, which means no timeout)
+	ConcurrencyPolicy ConcurrencyPolicy `json:"concurrency_policy"` // allow, forbid or replace (default forbid)
+	MissedRuns        MissedRunPolicy   `json:"missed_runs"`        // run_once or skip (default run_once)
+	Suspend           bool              `json:"suspend"`            // suspends the schedule without removing it
+}
+
+// ScheduleState represents the last time a schedule fired, used to detect missed runs
+type ScheduleState struct {
+	Deployment       string    `json:"deployment"`
+	Schedule         string    `json:"schedule"`
+	LastScheduleTime time.Time `json:"last_schedule_time"`
+	LastTaskID       string    `json:"last_task_id"`
+}
+
+// ScheduleStatus represents a schedule configuration with its current state
+type ScheduleStatus struct {
+	ScheduleConfig
+	LastScheduleTime *time.Time `json:"last_schedule_time"`
+	NextScheduleTime *time.Time `json:"next_schedule_time"`
+	LastTaskID       string     `json:"last_task_id"`
+	ActiveTasks      []string   `json:"active_tasks"`
+}
+
+// applyDefaults applies default schedule configuration values
+func (schedule *ScheduleConfig) applyDefaults() {
+	if schedule.ConcurrencyPolicy == "" {
+		schedule.ConcurrencyPolicy = ForbidConcurrent
+	}
+
+	if schedule.MissedRuns == "" {
+		schedule.MissedRuns = RunOnceMissedRuns
+	}
+
+	if schedule.Env == nil {
+		schedule.Env = make(map[string]string, 0)
+	}
+}
+
+// isValid returns an error if a schedule configuration is not valid
+func (schedule ScheduleConfig) isValid() error {
+	if !isValidComponentName(schedule.Name) {
+		return fmt.Errorf("invalid schedule name %s", schedule.Name)
+	}
+
+	if _, err := cron.Parse(schedule.Schedule); err != nil {
+		return fmt.Errorf("invalid schedule %s, %v", schedule.Name, err)
+	}
+
+	switch schedule.ConcurrencyPolicy {
+	case AllowConcurrent, ForbidConcurrent, ReplaceConcurrent:
+	default:
+		return fmt.Errorf("invalid concurrency policy %s for schedule %s, must be allow, forbid or replace", schedule.ConcurrencyPolicy, schedule.Name)
+	}
+
+	switch schedule.MissedRuns {
+	case RunOnceMissedRuns, SkipMissedRuns:
+	default:
+		return fmt.Errorf("invalid missed runs policy %s for schedule %s, must be run_once or skip", schedule.MissedRuns, schedule.Name)
+	}
+
+	return nil
+}
+
+// Cron returns the parsed cron schedule
+func (schedule ScheduleConfig) Cron() (cron.Schedule, error) {
+	return cron.Parse(schedule.Schedule)
+}
+
+// scheduledTask is a queued or running task started by a schedule
+type scheduledTask struct {
+	ID          string
+	ContainerID string
+	Cancelled   bool
+}
+
+// activeScheduledTasks are the queued or running tasks by schedule key
+var activeScheduledTasks = make(map[string][]*scheduledTask)
+var activeScheduledTasksMu sync.Mutex
+
+// NewScheduledTaskJob returns the job running a task for a schedule. The task is considered
+// active (see ActiveScheduledTasks) from the moment the job is created until the job completes.
+func NewScheduledTaskJob(config Config, schedule ScheduleConfig) job.Job {
+	taskID := uuid.Generate().String()
+	key := scheduleKey(config.Name, schedule.Name)
+	task := &scheduledTask{ID: taskID}
+
+	activeScheduledTasksMu.Lock()
+	activeScheduledTasks[key] = append(activeScheduledTasks[key], task)
+	activeScheduledTasksMu.Unlock()
+
+	args := &TaskJobArgs{
+		Config: config,
+		Request: TaskRequest{
+			Command:    schedule.Command,
+			Entrypoint: schedule.Entrypoint,
+			Env:        schedule.Env,
+			Timeout:    schedule.Timeout,
+		},
+		Outcome: TaskResult{ExitCode: -1, Schedule: schedule.Name},
+		onStart: func(containerID string) {
+			activeScheduledTasksMu.Lock()
+			defer activeScheduledTasksMu.Unlock()
+			task.ContainerID = containerID
+		},
+	}
+
+	j := newTaskJob(taskID, ScheduledTaskJobType, args)
+	run := j.Run
+	j.Run = func(args interface{}) error {
+		defer removeScheduledTask(key, taskID)
+
+		activeScheduledTasksMu.Lock()
+		cancelled := task.Cancelled
+		activeScheduledTasksMu.Unlock()
+
+		if cancelled {
+			closeTaskLog(taskID)
+			return fmt.Errorf("task %s was cancelled before it started", taskID)
+		}
+
+		return run(args)
+	}
+
+	return j
+}
+
+// ActiveScheduledTasks returns the ids of the queued or running tasks for a schedule
+func ActiveScheduledTasks(deployment, schedule string) []string {
+	activeScheduledTasksMu.Lock()
+	defer activeScheduledTasksMu.Unlock()
+
+	ids := make([]string, 0)
+	for _, task := range activeScheduledTasks[scheduleKey(deployment, schedule)] {
+		if !task.Cancelled {
+			ids = append(ids, task.ID)
+		}
+	}
+	return ids
+}
+
+// CancelScheduledTasks cancels the queued or running tasks for a schedule. Queued tasks
+// are skipped when picked up by a worker, running task containers are removed.
+func CancelScheduledTasks(deployment, schedule string) {
+	activeScheduledTasksMu.Lock()
+	defer activeScheduledTasksMu.Unlock()
+
+	ctx := context.Background()
+	defer ctx.Done()
+
+	for _, task := range activeScheduledTasks[scheduleKey(deployment, schedule)] {
+		task.Cancelled = true
+		if task.ContainerID == "" {
+			continue
+		}
+
+		logger.Debugf("Cancelling task %s of schedule %s for deployment %s", task.ID, schedule, deployment)
+		if err := docker.GetClient().RemoveContainer(ctx, task.ContainerID, true); err != nil {
+			logger.Warnf("unable to remove task container %s, %v", task.ContainerID, err)
+		}
+	}
+}
+
+// removeScheduledTask removes a task from the active tasks of a schedule
+func removeScheduledTask(key, taskID string) {
+	activeScheduledTasksMu.Lock()
+	defer activeScheduledTasksMu.Unlock()
+
+	tasks := activeScheduledTasks[key]
+	for i, task := range tasks {
+		if task.ID == taskID {
+			activeScheduledTasks[key] = append(tasks[:i], tasks[i+1:]...)
+			break
+		}
+	}
+
+	if len(activeScheduledTasks[key]) == 0 {
+		delete(activeScheduledTasks, key)
+	}
+}
+
+// GetScheduleState returns the state of a schedule, nil is returned if the schedule never fired
+func GetScheduleState(deployment, schedule string) (*ScheduleState, error) {
+	bytes, err := store.Client().Get(constants.SchedulesCollectionName, scheduleKey(deployment, schedule))
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes == nil {
+		return nil, nil
+	}
+
+	var state ScheduleState
+	if err := store.Deserialize(bytes, &state); err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+// SaveScheduleState stores the state of a schedule
+func SaveScheduleState(state ScheduleState) error {
+	if state.Deployment == "" || state.Schedule == "" {
+		return errors.New("deployment and schedule required to save a schedule state")
+	}
+
+	bytes, _ := json.Marshal(state)
+	return store.Client().Put(constants.SchedulesCollectionName, scheduleKey(state.Deployment, state.Schedule), bytes)
+}
+
+// RemoveStaleScheduleStates removes the state of schedules no longer declared in deployment configurations
+func RemoveStaleScheduleStates(configs []Config) error {
+	declared := make(map[string]bool, 0)
+	for _, config := range configs {
+		for _, schedule := range config.Schedules {
+			declared[scheduleKey(config.Name, schedule.Name)] = true
+		}
+	}
+
+	bytes, err := store.Client().GetAll(constants.SchedulesCollectionName)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range bytes {
+		var state ScheduleState
+		if err := store.Deserialize(b, &state); err != nil {
+			return err
+		}
+
+		key := scheduleKey(state.Deployment, state.Schedule)
+		if declared[key] {
+			continue
+		}
+
+		if err := store.Client().Remove(constants.SchedulesCollectionName, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetSchedules returns the schedules of a deployment with their current state
+func GetSchedules(deployment string) ([]ScheduleStatus, error) {
+	config, err := GetDeploymentConfig(deployment)
+	if err != nil {
+		return make([]ScheduleStatus, 0), err
+	}
+
+	now := time.Now().UTC()
+	statuses := make([]ScheduleStatus, 0)
+	for _, schedule := range config.Schedules {
+		status := ScheduleStatus{
+			ScheduleConfig: schedule,
+			ActiveTasks:    ActiveScheduledTasks(deployment, schedule.Name),
+		}
+
+		state, err := GetScheduleState(deployment, schedule.Name)
+		if err != nil {
+			return make([]ScheduleStatus, 0), err
+		}
+
+		if state != nil {
+			status.LastScheduleTime = &state.LastScheduleTime
+			status.LastTaskID = state.LastTaskID
+		}
+
+		if c, err := schedule.Cron(); err == nil && !schedule.Suspend {
+			if next := c.Next(now); !next.IsZero() {
+				status.NextScheduleTime = &next
+			}
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+func scheduleKey(deployment, schedule string) string {
+	return strings.ToLower(fmt.Sprintf("%s/%s", deployment, schedule))
+}
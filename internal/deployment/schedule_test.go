@@ -0,0 +1,74 @@
+package deployment
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduleDefaults(t *testing.T) {
+	var config Config
+	err := json.Unmarshal([]byte(`{
+		"name": "reports",
+		"image": "biensupernice/reports",
+		"schedules": [{"name": "nightly", "schedule": "0 2 * * *", "command": "bin/reports --nightly"}]
+	}`), &config)
+	assert.Nil(t, err)
+
+	config.applyDefaults()
+	assert.Nil(t, config.isValid())
+	assert.Equal(t, ForbidConcurrent, config.Schedules[0].ConcurrencyPolicy)
+	assert.Equal(t, RunOnceMissedRuns, config.Schedules[0].MissedRuns)
+	assert.Equal(t, Args{"bin/reports", "--nightly"}, config.Schedules[0].Command)
+}
+
+func TestInvalidSchedules(t *testing.T) {
+	config := Config{Name: "reports", Image: "biensupernice/reports"}
+
+	config.Schedules = []ScheduleConfig{{Name: "nightly", Schedule: "0 25 * * *", ConcurrencyPolicy: ForbidConcurrent, MissedRuns: SkipMissedRuns}}
+	assert.Error(t, config.isValid())
+
+	config.Schedules = []ScheduleConfig{{Name: "nightly", Schedule: "@daily", ConcurrencyPolicy: "queue", MissedRuns: SkipMissedRuns}}
+	assert.Error(t, config.isValid())
+
+	config.Schedules = []ScheduleConfig{{Name: "nightly", Schedule: "@daily", ConcurrencyPolicy: AllowConcurrent, MissedRuns: "all"}}
+	assert.Error(t, config.isValid())
+
+	config.Schedules = []ScheduleConfig{
+		{Name: "nightly", Schedule: "@daily", ConcurrencyPolicy: AllowConcurrent, MissedRuns: SkipMissedRuns},
+		{Name: "nightly", Schedule: "@hourly", ConcurrencyPolicy: AllowConcurrent, MissedRuns: SkipMissedRuns},
+	}
+	assert.Error(t, config.isValid())
+}
+
+func TestScheduleState(t *testing.T) {
+	last := time.Date(2021, 1, 1, 2, 0, 0, 0, time.UTC)
+	assert.Nil(t, SaveScheduleState(ScheduleState{Deployment: "reports", Schedule: "nightly", LastScheduleTime: last}))
+
+	state, err := GetScheduleState("reports", "nightly")
+	assert.Nil(t, err)
+	assert.True(t, last.Equal(state.LastScheduleTime))
+
+	// the deployment no longer declares the schedule
+	assert.Nil(t, RemoveStaleScheduleStates([]Config{{Name: "reports"}}))
+	state, err = GetScheduleState("reports", "nightly")
+	assert.Nil(t, err)
+	assert.Nil(t, state)
+}
+
+func TestScheduledTasksAreActiveUntilTheJobCompletes(t *testing.T) {
+	config := Config{Name: "reports", Image: "biensupernice/reports"}
+	schedule := ScheduleConfig{Name: "nightly", Schedule: "@daily"}
+
+	j := NewScheduledTaskJob(config, schedule)
+	assert.Equal(t, string(ScheduledTaskJobType), j.Type)
+	assert.Equal(t, []string{j.ID}, ActiveScheduledTasks("reports", "nightly"))
+
+	// cancelled before a worker picks up the job, the task container is never created
+	CancelScheduledTasks("reports", "nightly")
+	assert.Empty(t, ActiveScheduledTasks("reports", "nightly"))
+	assert.Error(t, j.Run(j.Args))
+	assert.Equal(t, "nightly", j.Args.(*TaskJobArgs).Result().(TaskResult).Schedule)
+}
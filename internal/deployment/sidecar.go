@@ -0,0 +1,200 @@
+package deployment
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/docker/docker/api/types/mount"
+
+	"github.com/krane/krane/internal/docker"
+	"github.com/krane/krane/internal/logger"
+)
+
+// SidecarConfig represents a container created alongside each container of a deployment.
+// Sidecars share the network namespace of the deployment container they are attached to,
+// meaning they can reach each other on localhost.
+type SidecarConfig struct {
+	Name       string            `json:"name"`        // sidecar name, unique within the deployment
+	Image      string            `json:"image"`       // container image
+	Registry   string            `json:"registry"`    // container registry
+	Tag        string            `json:"tag"`         // container image tag
+	Env        map[string]string `json:"env"`         // sidecar environment variables
+	Secrets    map[string]string `json:"secrets"`     // deployment secrets resolved as environment variables
+	Volumes    []string          `json:"volumes"`     // deployment volumes (by target or named volume source) mounted into the sidecar
+	Command    Args              `json:"command"`     // container start command (list of arguments or shell form string)
+	Entrypoint Args              `json:"entrypoint"`  // container entrypoint (list of arguments or shell form string)
+	WorkingDir string            `json:"working_dir"` // container working directory
+	User       string            `json:"user"`        // user (and optionally group) the container process runs as
+}
+
+// applyDefaults applies default sidecar configuration values
+func (sidecar *SidecarConfig) applyDefaults() {
+	if sidecar.Registry == "" {
+		sidecar.Registry = "docker.io"
+	}
+
+	if sidecar.Tag == "" {
+		sidecar.Tag = "latest"
+	}
+
+	if sidecar.Env == nil {
+		sidecar.Env = make(map[string]string, 0)
+	}
+
+	if sidecar.Secrets == nil {
+		sidecar.Secrets = make(map[string]string, 0)
+	}
+
+	if sidecar.Volumes == nil {
+		sidecar.Volumes = make([]string, 0)
+	}
+}
+
+// isValid returns an error if a sidecar configuration is not valid for a deployment
+func (sidecar SidecarConfig) isValid(config Config) error {
+	if !isValidComponentName(sidecar.Name) {
+		return fmt.Errorf("invalid sidecar name %s", sidecar.Name)
+	}
+
+	if sidecar.Image == "" {
+		return fmt.Errorf("image required for sidecar %s", sidecar.Name)
+	}
+
+	if sidecar.WorkingDir != "" && !strings.HasPrefix(sidecar.WorkingDir, "/") {
+		return fmt.Errorf("working directory %s for sidecar %s must be an absolute path", sidecar.WorkingDir, sidecar.Name)
+	}
+
+	for _, v := range sidecar.Volumes {
+		if _, ok := sidecar.findVolume(config, v); !ok {
+			return fmt.Errorf("sidecar %s mounts volume %s which is not declared by deployment %s", sidecar.Name, v, config.Name)
+		}
+	}
+
+	return nil
+}
+
+// findVolume returns a deployment volume by its target or named volume source
+func (sidecar SidecarConfig) findVolume(config Config, volume string) (VolumeConfig, bool) {
+	for _, v := range config.Volumes {
+		if v.Target == volume || (v.Type == NamedVolume && v.Source == volume) {
+			return v, true
+		}
+	}
+	return VolumeConfig{}, false
+}
+
+// DockerConfig returns the docker configuration for creating a sidecar attached to a deployment container
+func (sidecar SidecarConfig) DockerConfig(config Config, parent KraneContainer) docker.DockerConfig {
+	mounts := make([]mount.Mount, 0)
+	volumeSet := make(map[string]struct{}, 0)
+	for _, name := range sidecar.Volumes {
+		v, ok := sidecar.findVolume(config, name)
+		if !ok {
+			continue
+		}
+		mounts = append(mounts, v.toDockerMount())
+		volumeSet[v.Target] = struct{}{}
+	}
+
+	return docker.DockerConfig{
+		ContainerName: fmt.Sprintf("%s-%s", parent.Name, sidecar.Name),
+		Image:         docker.ImageRef(sidecar.Registry, sidecar.Image, sidecar.Tag),
+		NetworkMode:   fmt.Sprintf("container:%s", parent.ID),
+		Labels: map[string]string{
+			docker.ContainerDeploymentLabel: config.Name,
+			docker.ContainerSidecarLabel:    sidecar.Name,
+			docker.ContainerSidecarOfLabel:  parent.ID,
+		},
+		VolumeMounts: mounts,
+		VolumeSet:    volumeSet,
+		Env:          dockerEnvs(config.Name, sidecar.Env, sidecar.Secrets),
+		Command:      sidecar.Command,
+		Entrypoint:   sidecar.Entrypoint,
+		WorkingDir:   sidecar.WorkingDir,
+		User:         sidecar.User,
+	}
+}
+
+// PullSidecarImages pulls the images for the sidecars of a deployment
+func PullSidecarImages(config Config, e *EventEmitter) error {
+	for _, sidecar := range config.Sidecars {
+		logger.Debugf("Pulling image for sidecar %s of deployment %s", sidecar.Name, config.Name)
+		reader, err := docker.GetClient().PullImage(sidecar.Registry, sidecar.Image, sidecar.Tag)
+		if err != nil {
+			return fmt.Errorf("unable to pull image for sidecar %s, %v", sidecar.Name, err)
+		}
+		e.emitStream(reader)
+	}
+	return nil
+}
+
+// createSidecars creates the sidecar containers for a deployment container
+func createSidecars(config Config, parent KraneContainer) ([]KraneContainer, error) {
+	ctx := context.Background()
+	defer ctx.Done()
+
+	sidecars := make([]KraneContainer, 0)
+	for _, sidecar := range config.Sidecars {
+		body, err := docker.GetClient().CreateContainer(ctx, sidecar.DockerConfig(config, parent))
+		if err != nil {
+			return sidecars, fmt.Errorf("unable to create sidecar %s, %v", sidecar.Name, err)
+		}
+
+		json, err := docker.GetClient().GetOneContainer(ctx, body.ID)
+		if err != nil {
+			return sidecars, err
+		}
+
+		sidecars = append(sidecars, fromDockerContainerToKcontainer(json))
+	}
+
+	return sidecars, nil
+}
+
+// groupSidecars attaches sidecar containers to the containers they run alongside. Sidecars
+// whose container no longer exists are kept at the top level so they can still be cleaned up.
+func groupSidecars(containers []KraneContainer) []KraneContainer {
+	parents := make(map[string]int, 0)
+	grouped := make([]KraneContainer, 0)
+	for _, c := range containers {
+		if c.Sidecar == "" {
+			parents[c.ID] = len(grouped)
+			grouped = append(grouped, c)
+		}
+	}
+
+	for _, c := range containers {
+		if c.Sidecar == "" {
+			continue
+		}
+
+		i, ok := parents[c.Labels[docker.ContainerSidecarOfLabel]]
+		if !ok {
+			grouped = append(grouped, c)
+			continue
+		}
+		grouped[i].Sidecars = append(grouped[i].Sidecars, c)
+	}
+
+	return grouped
+}
+
+// withSidecars returns a flat list of containers including their sidecars
+func withSidecars(containers []KraneContainer) []KraneContainer {
+	flattened := make([]KraneContainer, 0)
+	for _, c := range containers {
+		flattened = append(flattened, c)
+		flattened = append(flattened, c.Sidecars...)
+	}
+	return flattened
+}
+
+// isValidComponentName returns true if the name of a deployment component (ie. a sidecar or schedule) is valid
+func isValidComponentName(name string) bool {
+	if len(name) > 50 {
+		return false
+	}
+	return regexp.MustCompile(`^[a-z][a-z0-9_-]*[0-9a-z]$`).MatchString(name)
+}
@@ -0,0 +1,91 @@
+package deployment
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/krane/krane/internal/docker"
+)
+
+func TestUnmarshalSidecars(t *testing.T) {
+	var config Config
+	err := json.Unmarshal([]byte(`{
+		"name": "api",
+		"image": "biensupernice/api",
+		"volumes": [{"type": "named", "source": "logs", "target": "/var/log/api"}],
+		"sidecars": [{"name": "log-shipper", "image": "fluent/fluent-bit", "volumes": ["logs"], "command": "fluent-bit -c /config.conf"}]
+	}`), &config)
+	assert.Nil(t, err)
+
+	config.applyDefaults()
+	assert.Nil(t, config.isValid())
+	assert.Len(t, config.Sidecars, 1)
+	assert.Equal(t, "docker.io", config.Sidecars[0].Registry)
+	assert.Equal(t, "latest", config.Sidecars[0].Tag)
+	assert.Equal(t, Args{"fluent-bit", "-c", "/config.conf"}, config.Sidecars[0].Command)
+}
+
+func TestInvalidSidecars(t *testing.T) {
+	config := Config{
+		Name:    "api",
+		Image:   "biensupernice/api",
+		Volumes: VolumeConfigs{{Type: NamedVolume, Source: "logs", Target: "/var/log/api"}},
+	}
+
+	config.Sidecars = []SidecarConfig{{Name: "Proxy", Image: "envoyproxy/envoy"}}
+	assert.Error(t, config.isValid())
+
+	config.Sidecars = []SidecarConfig{{Name: "proxy"}}
+	assert.Error(t, config.isValid())
+
+	config.Sidecars = []SidecarConfig{{Name: "proxy", Image: "envoyproxy/envoy"}, {Name: "proxy", Image: "envoyproxy/envoy"}}
+	assert.Error(t, config.isValid())
+
+	config.Sidecars = []SidecarConfig{{Name: "proxy", Image: "envoyproxy/envoy", Volumes: []string{"/data"}}}
+	assert.Error(t, config.isValid())
+
+	config.Sidecars = []SidecarConfig{{Name: "proxy", Image: "envoyproxy/envoy", Volumes: []string{"/var/log/api"}}}
+	assert.Nil(t, config.isValid())
+}
+
+func TestSidecarDockerConfig(t *testing.T) {
+	config := Config{
+		Name:    "api",
+		Image:   "biensupernice/api",
+		Volumes: VolumeConfigs{{Type: NamedVolume, Source: "logs", Target: "/var/log/api", ReadOnly: true}},
+	}
+	sidecar := SidecarConfig{Name: "log-shipper", Image: "fluent/fluent-bit", Registry: "ghcr.io", Tag: "1.9", Volumes: []string{"logs"}}
+	parent := KraneContainer{ID: "abc123", Name: "api-xyz"}
+
+	dockerConfig := sidecar.DockerConfig(config, parent)
+	assert.Equal(t, "api-xyz-log-shipper", dockerConfig.ContainerName)
+	assert.Equal(t, "ghcr.io/fluent/fluent-bit:1.9", dockerConfig.Image)
+	assert.Equal(t, "container:abc123", dockerConfig.NetworkMode)
+	assert.Equal(t, "api", dockerConfig.Labels[docker.ContainerDeploymentLabel])
+	assert.Equal(t, "log-shipper", dockerConfig.Labels[docker.ContainerSidecarLabel])
+	assert.Equal(t, "abc123", dockerConfig.Labels[docker.ContainerSidecarOfLabel])
+	assert.Len(t, dockerConfig.VolumeMounts, 1)
+	assert.Equal(t, "/var/log/api", dockerConfig.VolumeMounts[0].Target)
+	assert.True(t, dockerConfig.VolumeMounts[0].ReadOnly)
+}
+
+func TestGroupSidecars(t *testing.T) {
+	containers := []KraneContainer{
+		{ID: "sidecar-1", Sidecar: "proxy", Labels: map[string]string{docker.ContainerSidecarOfLabel: "replica-1"}},
+		{ID: "replica-1"},
+		{ID: "replica-2"},
+		{ID: "orphan", Sidecar: "proxy", Labels: map[string]string{docker.ContainerSidecarOfLabel: "removed"}},
+	}
+
+	grouped := groupSidecars(containers)
+	assert.Len(t, grouped, 3)
+	assert.Equal(t, "replica-1", grouped[0].ID)
+	assert.Len(t, grouped[0].Sidecars, 1)
+	assert.Equal(t, "sidecar-1", grouped[0].Sidecars[0].ID)
+	assert.Empty(t, grouped[1].Sidecars)
+	assert.Equal(t, "orphan", grouped[2].ID)
+
+	assert.Len(t, withSidecars(grouped), 4)
+}
@@ -0,0 +1,249 @@
+package deployment
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution/uuid"
+	"github.com/gorilla/websocket"
+
+	"github.com/krane/krane/internal/docker"
+	"github.com/krane/krane/internal/job"
+	"github.com/krane/krane/internal/logger"
+)
+
+// TaskRequest represents a one-off command run with a deployment configuration (ie. a database seed or batch script)
+type TaskRequest struct {
+	Command    Args              `json:"command"`    // task command (default deployment command)
+	Entrypoint Args              `json:"entrypoint"` // task entrypoint (default deployment entrypoint)
+	Env        map[string]string `json:"env"`        // environment variables added to the deployment environment variables
+	Timeout    uint              `json:"timeout"`    // seconds to wait for the task to exit (default 0, which means no timeout)
+}
+
+// TaskResult represents the outcome of a task, stored as the result of the task job
+type TaskResult struct {
+	ExitCode int64  `json:"exit_code"`          // exit code of the task container (-1 if the task container did not exit)
+	Duration int64  `json:"duration_ms"`        // time in milliseconds the task container ran for
+	Schedule string `json:"schedule,omitempty"` // name of the schedule which triggered the task (if any)
+}
+
+// TaskJobArgs are the arguments of a task job
+type TaskJobArgs struct {
+	Config  Config
+	Request TaskRequest
+	Outcome TaskResult
+
+	// onStart is called with the id of the task container once it is started
+	onStart func(containerID string)
+}
+
+// Result returns the task outcome stored with the task job
+func (args *TaskJobArgs) Result() interface{} {
+	return args.Outcome
+}
+
+// maxTaskLogEvents is the number of output events of a task kept for clients subscribing after the task started
+const maxTaskLogEvents = 1000
+
+// taskLog is the output of a queued or running task and the clients subscribed to it. Events are kept
+// so clients subscribing after the task started first receive the output emitted before they subscribed.
+type taskLog struct {
+	events  [][]byte
+	clients []*websocket.Conn
+}
+
+// taskLogs are the outputs of queued or running tasks by task id
+var taskLogs = make(map[string]*taskLog)
+var taskLogsMu sync.Mutex
+
+// RunTask queues a one-off task container from a deployments image with its environment variables, secrets,
+// volumes and network. The task id is returned which is also the id of the job recording the task outcome.
+func RunTask(deployment string, request TaskRequest) (string, error) {
+	config, err := GetDeploymentConfig(deployment)
+	if err != nil {
+		return "", fmt.Errorf("unable to get configuration for deployment %s", deployment)
+	}
+
+	taskID := uuid.Generate().String()
+	go enqueueTask(newTaskJob(taskID, TaskJobType, &TaskJobArgs{
+		Config:  config,
+		Request: request,
+		Outcome: TaskResult{ExitCode: -1},
+	}))
+
+	return taskID, nil
+}
+
+// newTaskJob returns the job running a task, clients can subscribe to the task output as soon as the job is created
+func newTaskJob(taskID string, jobType JobType, args *TaskJobArgs) job.Job {
+	openTaskLog(taskID)
+
+	return job.Job{
+		ID:          taskID,
+		Deployment:  args.Config.Name,
+		Type:        string(jobType),
+		RetryPolicy: 1,
+		Args:        args,
+		Run: func(args interface{}) error {
+			jobArgs := args.(*TaskJobArgs)
+			defer closeTaskLog(taskID)
+
+			// ensure jobs collections
+			if err := CreateJobsCollection(jobArgs.Config.Name); err != nil {
+				logger.Errorf("unable to create jobs collection %v", err)
+				return err
+			}
+
+			return runTask(taskID, jobArgs)
+		},
+	}
+}
+
+// enqueueTask queues a task job, the task output is closed if the job can't be queued
+func enqueueTask(j job.Job) {
+	enqueuer := job.NewEnqueuer(job.Queue())
+	if _, err := enqueuer.Enqueue(j); err != nil {
+		logger.Errorf("Error enqueuing task job %v", err)
+		closeTaskLog(j.ID)
+		return
+	}
+	logger.Debugf("Task %s queued for processing", j.ID)
+}
+
+// runTask runs a task container to completion recording its exit code and duration
+func runTask(taskID string, args *TaskJobArgs) error {
+	config := args.Config
+
+	// clients subscribed to the task receive the same events as clients subscribed to the deployment
+	e := createEventEmitter(config.Name, taskID)
+	e.Phase = TaskPhase
+	e.publish = func(data []byte) { publishTaskEvent(taskID, data) }
+
+	logger.Debugf("Pulling image for task %s of deployment %s", taskID, config.Name)
+	reader, err := docker.GetClient().PullImage(config.Registry, config.Image, config.Tag)
+	if err != nil {
+		logger.Errorf("unable to pull image %v", err)
+		return err
+	}
+	e.emitStream(reader)
+
+	if err := EnsureVolumes(config); err != nil {
+		logger.Errorf("unable to create volumes %v", err)
+		return err
+	}
+
+	command := args.Request.Command
+	if len(command) == 0 {
+		command = config.Command
+	}
+
+	entrypoint := args.Request.Entrypoint
+	if len(entrypoint) == 0 {
+		entrypoint = config.Entrypoint
+	}
+
+	container := oneOffContainer{
+		Kind:       "task",
+		Image:      docker.ImageRef(config.Registry, config.Image, config.Tag),
+		Env:        args.Request.Env,
+		Command:    command,
+		Entrypoint: entrypoint,
+		Mounts:     config.DockerVolumeMount(),
+		Timeout:    args.Request.Timeout,
+		onStart:    args.onStart,
+	}
+
+	start := time.Now()
+	exitCode, err := container.run(config, *e)
+	args.Outcome.ExitCode = exitCode
+	args.Outcome.Duration = time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.Errorf("task failed %v", err)
+		e.emit(fmt.Sprintf("Task %s failed, %v", taskID, err))
+		return err
+	}
+
+	e.emit(fmt.Sprintf("Task %s exited with code %d", taskID, exitCode))
+	if exitCode != 0 {
+		return fmt.Errorf("task exited with code %d", exitCode)
+	}
+
+	return nil
+}
+
+// SubscribeToTaskLogs allows clients to subscribe to the output of a queued or running task, the output
+// written before the client subscribed is sent first. The connection is closed once the task completes.
+func SubscribeToTaskLogs(client *websocket.Conn, taskID string) error {
+	taskLogsMu.Lock()
+	defer taskLogsMu.Unlock()
+
+	log, ok := taskLogs[taskID]
+	if !ok {
+		return fmt.Errorf("task %s is not queued or running", taskID)
+	}
+
+	for _, data := range log.events {
+		if err := client.WriteMessage(websocket.TextMessage, data); err != nil {
+			return err
+		}
+	}
+
+	log.clients = append(log.clients, client)
+	return nil
+}
+
+// openTaskLog starts recording the output of a task
+func openTaskLog(taskID string) {
+	taskLogsMu.Lock()
+	defer taskLogsMu.Unlock()
+
+	taskLogs[taskID] = &taskLog{events: make([][]byte, 0), clients: make([]*websocket.Conn, 0)}
+}
+
+// publishTaskEvent records an event of a task and sends it to the clients subscribed to the task,
+// clients which can't be written to are disconnected
+func publishTaskEvent(taskID string, data []byte) {
+	taskLogsMu.Lock()
+	defer taskLogsMu.Unlock()
+
+	log, ok := taskLogs[taskID]
+	if !ok {
+		return
+	}
+
+	log.events = append(log.events, data)
+	if len(log.events) > maxTaskLogEvents {
+		log.events = log.events[len(log.events)-maxTaskLogEvents:]
+	}
+
+	connected := make([]*websocket.Conn, 0)
+	for _, client := range log.clients {
+		if err := client.WriteMessage(websocket.TextMessage, data); err != nil {
+			logger.Debugf("client %v disconnected", client.RemoteAddr())
+			_ = client.Close()
+			continue
+		}
+		connected = append(connected, client)
+	}
+	log.clients = connected
+}
+
+// closeTaskLog closes the connections of clients subscribed to a task and discards its output
+func closeTaskLog(taskID string) {
+	taskLogsMu.Lock()
+	defer taskLogsMu.Unlock()
+
+	log, ok := taskLogs[taskID]
+	if !ok {
+		return
+	}
+
+	for _, client := range log.clients {
+		if err := client.Close(); err != nil {
+			logger.Debugf("unable to close task client connection %v", err)
+		}
+	}
+	delete(taskLogs, taskID)
+}
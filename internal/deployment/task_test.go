@@ -0,0 +1,72 @@
+package deployment
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/krane/krane/internal/job"
+)
+
+func TestTaskJobArgsReportOutcome(t *testing.T) {
+	args := &TaskJobArgs{Outcome: TaskResult{ExitCode: 2, Duration: 1500}}
+
+	var reporter job.Reporter = args
+	assert.Equal(t, TaskResult{ExitCode: 2, Duration: 1500}, reporter.Result())
+}
+
+func TestUnmarshalTaskRequest(t *testing.T) {
+	var request TaskRequest
+	err := json.Unmarshal([]byte(`{"command": "bundle exec rake db:seed", "env": {"SEED": "1"}, "timeout": 600}`), &request)
+	assert.Nil(t, err)
+	assert.Equal(t, Args{"bundle", "exec", "rake", "db:seed"}, request.Command)
+	assert.Equal(t, "1", request.Env["SEED"])
+	assert.Equal(t, uint(600), request.Timeout)
+}
+
+func TestSubscribeToUnknownTask(t *testing.T) {
+	assert.Error(t, SubscribeToTaskLogs(nil, "unknown-task"))
+}
+
+func TestTaskLogsAreReplayedToLateSubscribers(t *testing.T) {
+	taskID := "replayed-task"
+	openTaskLog(taskID)
+	publishTaskEvent(taskID, []byte("pulling image"))
+	publishTaskEvent(taskID, []byte("seeding database"))
+
+	subscribed := make(chan error, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{}
+		connection, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			subscribed <- err
+			return
+		}
+		subscribed <- SubscribeToTaskLogs(connection, taskID)
+	}))
+	defer server.Close()
+
+	client, _, err := websocket.DefaultDialer.Dial(strings.Replace(server.URL, "http", "ws", 1), nil)
+	assert.Nil(t, err)
+	defer client.Close()
+	assert.Nil(t, <-subscribed)
+
+	// output emitted before subscribing is sent first, followed by new output
+	publishTaskEvent(taskID, []byte("task exited with code 0"))
+	for _, expected := range []string{"pulling image", "seeding database", "task exited with code 0"} {
+		_, message, err := client.ReadMessage()
+		assert.Nil(t, err)
+		assert.Equal(t, expected, string(message))
+	}
+
+	// the output is discarded and subscribers disconnected once the task completes
+	closeTaskLog(taskID)
+	_, _, err = client.ReadMessage()
+	assert.Error(t, err)
+	assert.Error(t, SubscribeToTaskLogs(nil, taskID))
+}
@@ -0,0 +1,324 @@
+package deployment
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/krane/krane/internal/constants"
+	"github.com/krane/krane/internal/store"
+)
+
+// placeholderRegex matches ${VAR} and ${VAR:-default} placeholders
+var placeholderRegex = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// Template represents a deployment configuration with ${VAR} placeholders used to instantiate deployments
+type Template struct {
+	Name      string                 `json:"name"`
+	Config    map[string]interface{} `json:"config"`           // deployment configuration with placeholders in any field
+	Variables []string               `json:"variables"`        // placeholders used in the configuration
+	Revision  int                    `json:"revision"`         // incremented every time the template is saved
+	UpdatedAt int64                  `json:"updated_at_epoch"` // epoch in seconds since 1970
+}
+
+// TemplateOrigin represents the template a deployment was instantiated from
+type TemplateOrigin struct {
+	Name      string            `json:"name"`
+	Revision  int               `json:"revision"`
+	Variables map[string]string `json:"variables"`
+}
+
+// SaveTemplate creates or updates a template, the template revision is incremented on every save
+func SaveTemplate(template Template) (Template, error) {
+	if !(Config{Name: template.Name}).isValidName() {
+		return Template{}, fmt.Errorf("invalid template name %s", template.Name)
+	}
+
+	if len(template.Config) == 0 {
+		return Template{}, errors.New("template config required")
+	}
+
+	// the revision is managed by the server, a revision sent by a client is ignored
+	template.Revision = 0
+	if current, err := GetTemplate(template.Name); err == nil {
+		template.Revision = current.Revision
+	}
+
+	template.Revision++
+	template.Variables = templateVariables(template.Config)
+	template.UpdatedAt = time.Now().Unix()
+
+	bytes, _ := json.Marshal(template)
+	if err := store.Client().Put(constants.TemplatesCollectionName, template.Name, bytes); err != nil {
+		return Template{}, err
+	}
+
+	return template, nil
+}
+
+// GetTemplate returns a template
+func GetTemplate(name string) (Template, error) {
+	bytes, err := store.Client().Get(constants.TemplatesCollectionName, name)
+	if err != nil {
+		return Template{}, err
+	}
+
+	if bytes == nil {
+		return Template{}, fmt.Errorf("template %s not found", name)
+	}
+
+	var template Template
+	if err := store.Deserialize(bytes, &template); err != nil {
+		return Template{}, err
+	}
+
+	return template, nil
+}
+
+// GetAllTemplates returns all templates
+func GetAllTemplates() ([]Template, error) {
+	bytes, err := store.Client().GetAll(constants.TemplatesCollectionName)
+	if err != nil {
+		return make([]Template, 0), err
+	}
+
+	templates := make([]Template, 0)
+	for _, b := range bytes {
+		var template Template
+		if err := store.Deserialize(b, &template); err != nil {
+			return make([]Template, 0), err
+		}
+		templates = append(templates, template)
+	}
+
+	return templates, nil
+}
+
+// DeleteTemplate removes a template. Deployments instantiated from the template are kept.
+func DeleteTemplate(name string) error {
+	return store.Client().Remove(constants.TemplatesCollectionName, name)
+}
+
+// GetTemplateInstances returns the configurations of the deployments instantiated from a template
+func GetTemplateInstances(name string) ([]Config, error) {
+	configs, err := GetAllDeploymentConfigs()
+	if err != nil {
+		return make([]Config, 0), err
+	}
+
+	instances := make([]Config, 0)
+	for _, config := range configs {
+		if config.Template != nil && config.Template.Name == name {
+			instances = append(instances, config)
+		}
+	}
+
+	return instances, nil
+}
+
+// InstantiateTemplate saves and runs a deployment from a template and variables
+func InstantiateTemplate(name string, variables map[string]string) (Config, error) {
+	template, err := GetTemplate(name)
+	if err != nil {
+		return Config{}, err
+	}
+
+	config, err := template.Render(variables)
+	if err != nil {
+		return Config{}, err
+	}
+
+	if err := SaveConfig(config); err != nil {
+		return Config{}, err
+	}
+
+	if err := Run(config.Name); err != nil {
+		return Config{}, err
+	}
+
+	return config, nil
+}
+
+// ApplyTemplate re-renders every deployment instantiated from a template with the current template
+// revision and the variables each deployment was instantiated with, applying them as a batch
+func ApplyTemplate(name string) (ApplyBatch, error) {
+	template, err := GetTemplate(name)
+	if err != nil {
+		return ApplyBatch{}, err
+	}
+
+	instances, err := GetTemplateInstances(name)
+	if err != nil {
+		return ApplyBatch{}, err
+	}
+
+	if len(instances) == 0 {
+		return ApplyBatch{}, fmt.Errorf("no deployments instantiated from template %s", name)
+	}
+
+	configs := make([]Config, 0)
+	for _, instance := range instances {
+		config, err := template.Render(instance.Template.Variables)
+		if err != nil {
+			return ApplyBatch{}, fmt.Errorf("unable to render deployment %s, %v", instance.Name, err)
+		}
+
+		if config.Name != instance.Name {
+			return ApplyBatch{}, fmt.Errorf("template %s renders deployment %s as %s", name, instance.Name, config.Name)
+		}
+
+		configs = append(configs, config)
+	}
+
+	return Apply(Bundle{Deployments: configs})
+}
+
+// Render substitutes the template placeholders with variables returning the deployment configuration.
+// Placeholders without a variable use their default (ie. ${TAG:-latest}), an error is returned if neither is set.
+func (template Template) Render(variables map[string]string) (Config, error) {
+	missing := make([]string, 0)
+	rendered := substitute(template.Config, variables, &missing)
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return Config{}, fmt.Errorf("missing template variables %s", strings.Join(missing, ", "))
+	}
+
+	// placeholders are strings, fields of other types (ie. scale) are converted to their type
+	bytes, _ := json.Marshal(coerce(rendered, reflect.TypeOf(Config{})))
+
+	var config Config
+	if err := json.Unmarshal(bytes, &config); err != nil {
+		return Config{}, fmt.Errorf("invalid rendered template %s, %v", template.Name, err)
+	}
+
+	used := make(map[string]string, 0)
+	for _, v := range template.Variables {
+		if value, ok := variables[v]; ok {
+			used[v] = value
+		}
+	}
+	config.Template = &TemplateOrigin{Name: template.Name, Revision: template.Revision, Variables: used}
+
+	return config, nil
+}
+
+// substitute replaces placeholders in every string and map key of a decoded json value
+func substitute(value interface{}, variables map[string]string, missing *[]string) interface{} {
+	switch v := value.(type) {
+	case string:
+		return placeholderRegex.ReplaceAllStringFunc(v, func(placeholder string) string {
+			match := placeholderRegex.FindStringSubmatch(placeholder)
+			if value, ok := variables[match[1]]; ok {
+				return value
+			}
+			if match[2] != "" {
+				return match[3]
+			}
+			*missing = appendUnique(*missing, match[1])
+			return placeholder
+		})
+	case map[string]interface{}:
+		substituted := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			substituted[substitute(k, variables, missing).(string)] = substitute(item, variables, missing)
+		}
+		return substituted
+	case []interface{}:
+		substituted := make([]interface{}, len(v))
+		for i, item := range v {
+			substituted[i] = substitute(item, variables, missing)
+		}
+		return substituted
+	}
+	return value
+}
+
+// coerce converts strings to numbers or booleans where the matching field of t is a number or a boolean
+func coerce(value interface{}, t reflect.Type) interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch v := value.(type) {
+	case string:
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+				return n
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if n, err := strconv.ParseUint(v, 10, 64); err == nil {
+				return n
+			}
+		case reflect.Bool:
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b
+			}
+		}
+	case map[string]interface{}:
+		switch t.Kind() {
+		case reflect.Struct:
+			for i := 0; i < t.NumField(); i++ {
+				name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+				if item, ok := v[name]; ok {
+					v[name] = coerce(item, t.Field(i).Type)
+				}
+			}
+		case reflect.Map:
+			for k, item := range v {
+				v[k] = coerce(item, t.Elem())
+			}
+		}
+	case []interface{}:
+		if t.Kind() == reflect.Slice {
+			for i, item := range v {
+				v[i] = coerce(item, t.Elem())
+			}
+		}
+	}
+	return value
+}
+
+// templateVariables returns the sorted names of the placeholders used in a decoded json value
+func templateVariables(value interface{}) []string {
+	variables := make([]string, 0)
+
+	var walk func(value interface{})
+	walk = func(value interface{}) {
+		switch v := value.(type) {
+		case string:
+			for _, match := range placeholderRegex.FindAllStringSubmatch(v, -1) {
+				variables = appendUnique(variables, match[1])
+			}
+		case map[string]interface{}:
+			for k, item := range v {
+				walk(k)
+				walk(item)
+			}
+		case []interface{}:
+			for _, item := range v {
+				walk(item)
+			}
+		}
+	}
+	walk(value)
+
+	sort.Strings(variables)
+	return variables
+}
+
+func appendUnique(list []string, value string) []string {
+	for _, v := range list {
+		if v == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
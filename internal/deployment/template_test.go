@@ -0,0 +1,83 @@
+package deployment
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const serviceTemplate = `{
+	"name": "${NAME}",
+	"image": "biensupernice/${NAME}",
+	"tag": "${TAG:-latest}",
+	"alias": ["${NAME}.example.com"],
+	"scale": "${SCALE:-1}",
+	"secure": "${SECURE:-false}",
+	"env": {"${NAME}_MODE": "${MODE}", "DEBUG": "${DEBUG:-true}"},
+	"schedules": [{"name": "cleanup", "schedule": "@daily", "timeout": "${CLEANUP_TIMEOUT:-60}"}]
+}`
+
+func newTemplate(t *testing.T) Template {
+	var config map[string]interface{}
+	assert.Nil(t, json.Unmarshal([]byte(serviceTemplate), &config))
+	return Template{Name: "service", Config: config, Revision: 2, Variables: templateVariables(config)}
+}
+
+func TestTemplateVariables(t *testing.T) {
+	template := newTemplate(t)
+	assert.Equal(t, []string{"CLEANUP_TIMEOUT", "DEBUG", "MODE", "NAME", "SCALE", "SECURE", "TAG"}, template.Variables)
+}
+
+func TestRenderTemplate(t *testing.T) {
+	template := newTemplate(t)
+
+	config, err := template.Render(map[string]string{"NAME": "billing", "MODE": "worker", "SCALE": "3", "TAG": "1.4.0", "UNUSED": "x"})
+	assert.Nil(t, err)
+	assert.Equal(t, "billing", config.Name)
+	assert.Equal(t, "biensupernice/billing", config.Image)
+	assert.Equal(t, "1.4.0", config.Tag)
+	assert.Equal(t, []string{"billing.example.com"}, config.Alias)
+	assert.Equal(t, 3, config.Scale)
+	assert.False(t, config.Secure)
+	assert.Equal(t, map[string]string{"billing_MODE": "worker", "DEBUG": "true"}, config.Env)
+	assert.Equal(t, uint(60), config.Schedules[0].Timeout)
+	assert.Equal(t, &TemplateOrigin{
+		Name:      "service",
+		Revision:  2,
+		Variables: map[string]string{"NAME": "billing", "MODE": "worker", "SCALE": "3", "TAG": "1.4.0"},
+	}, config.Template)
+}
+
+func TestRenderTemplateErrors(t *testing.T) {
+	template := newTemplate(t)
+
+	_, err := template.Render(map[string]string{})
+	assert.EqualError(t, err, "missing template variables MODE, NAME")
+
+	_, err = template.Render(map[string]string{"NAME": "billing", "MODE": "worker", "SCALE": "three"})
+	assert.Error(t, err)
+}
+
+func TestSaveTemplateIncrementsRevision(t *testing.T) {
+	template := newTemplate(t)
+	template.Name = "revisioned"
+
+	saved, err := SaveTemplate(template)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, saved.Revision)
+
+	saved, err = SaveTemplate(template)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, saved.Revision)
+
+	assert.Nil(t, DeleteTemplate("revisioned"))
+	_, err = GetTemplate("revisioned")
+	assert.Error(t, err)
+
+	_, err = SaveTemplate(Template{Name: "Invalid Name", Config: template.Config})
+	assert.Error(t, err)
+
+	_, err = SaveTemplate(Template{Name: "empty"})
+	assert.Error(t, err)
+}
@@ -1,17 +1,360 @@
 package deployment
 
-import "github.com/docker/docker/api/types"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
 
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/mount"
+
+	"github.com/krane/krane/internal/docker"
+	"github.com/krane/krane/internal/logger"
+)
+
+// Volume represents a volume mounted into a Krane managed container
 type Volume struct {
+	Type            string `json:"type"`
+	Name            string `json:"name"`
 	HostVolume      string `json:"host_volume"`
 	ContainerVolume string `json:"container_volume"`
 }
 
+// KraneVolume represents a Docker volume managed by Krane
+type KraneVolume struct {
+	Name       string            `json:"name"`
+	Deployment string            `json:"deployment"`
+	Driver     string            `json:"driver"`
+	Mountpoint string            `json:"mountpoint"`
+	Options    map[string]string `json:"options"`
+	Labels     map[string]string `json:"labels"`
+}
+
+type VolumeType string
+
+const (
+	BindVolume  VolumeType = "bind"
+	NamedVolume VolumeType = "named"
+	TmpfsVolume VolumeType = "tmpfs"
+)
+
+// VolumeConfig represents a volume declared in a deployment configuration
+type VolumeConfig struct {
+	Type     VolumeType        `json:"type"`      // bind, named or tmpfs (default bind)
+	Source   string            `json:"source"`    // host path for bind volumes, volume name for named volumes, unused for tmpfs
+	Target   string            `json:"target"`    // path inside the container
+	ReadOnly bool              `json:"read_only"` // mount the volume as read-only
+	Driver   string            `json:"driver"`    // volume driver used when creating named volumes (default local)
+	Options  map[string]string `json:"options"`   // driver options for named volumes, size and mode for tmpfs
+}
+
+// VolumeConfigs is the list of volumes declared in a deployment configuration
+type VolumeConfigs []VolumeConfig
+
+// UnmarshalJSON parses volumes as a list of volume configurations. For backwards compatibility
+// volumes can also be declared as a map of host paths to container paths which are mounted as bind volumes.
+func (volumes *VolumeConfigs) UnmarshalJSON(data []byte) error {
+	var hostToContainer map[string]string
+	if err := json.Unmarshal(data, &hostToContainer); err == nil {
+		parsed := make(VolumeConfigs, 0)
+		for hostVolume, containerVolume := range hostToContainer {
+			parsed = append(parsed, VolumeConfig{
+				Type:   BindVolume,
+				Source: hostVolume,
+				Target: containerVolume,
+			})
+		}
+		*volumes = parsed
+		return nil
+	}
+
+	var list []VolumeConfig
+	if err := json.Unmarshal(data, &list); err != nil {
+		return errors.New("volumes must be a list of volume configurations")
+	}
+	*volumes = list
+	return nil
+}
+
+var volumeNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]+$`)
+
+// applyDefaults applies default volume configuration values
+func (v *VolumeConfig) applyDefaults() {
+	if v.Type == "" {
+		v.Type = BindVolume
+	}
+
+	if v.Options == nil {
+		v.Options = make(map[string]string, 0)
+	}
+}
+
+// isValid returns an error if a volume configuration is not valid
+func (v VolumeConfig) isValid() error {
+	if v.Target == "" || !path.IsAbs(v.Target) {
+		return fmt.Errorf("volume target %s must be an absolute path", v.Target)
+	}
+
+	switch v.Type {
+	case BindVolume:
+		if v.Source == "" || !path.IsAbs(v.Source) {
+			return fmt.Errorf("bind volume source %s must be an absolute path", v.Source)
+		}
+	case NamedVolume:
+		if !isValidVolumeName(v.Source) {
+			return fmt.Errorf("invalid volume name %s", v.Source)
+		}
+	case TmpfsVolume:
+		if v.Source != "" {
+			return fmt.Errorf("tmpfs volume mounted at %s cannot have a source", v.Target)
+		}
+		if _, err := v.tmpfsOptions(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid volume type %s, must be one of bind, named or tmpfs", v.Type)
+	}
+
+	return nil
+}
+
+// tmpfsOptions returns the Docker tmpfs options from the volume options
+func (v VolumeConfig) tmpfsOptions() (*mount.TmpfsOptions, error) {
+	opts := &mount.TmpfsOptions{}
+
+	if size, ok := v.Options["size"]; ok {
+		bytes, err := strconv.ParseInt(size, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tmpfs size %s, must be in bytes", size)
+		}
+		opts.SizeBytes = bytes
+	}
+
+	if mode, ok := v.Options["mode"]; ok {
+		m, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tmpfs mode %s, must be an octal file mode", mode)
+		}
+		opts.Mode = os.FileMode(m)
+	}
+
+	return opts, nil
+}
+
+// toDockerMount converts a volume configuration into a Docker mount
+func (v VolumeConfig) toDockerMount() mount.Mount {
+	m := mount.Mount{
+		Target:   v.Target,
+		ReadOnly: v.ReadOnly,
+	}
+
+	switch v.Type {
+	case NamedVolume:
+		m.Type = mount.TypeVolume
+		m.Source = v.Source
+	case TmpfsVolume:
+		m.Type = mount.TypeTmpfs
+		m.TmpfsOptions, _ = v.tmpfsOptions()
+	default:
+		m.Type = mount.TypeBind
+		m.Source = v.Source
+	}
+
+	return m
+}
+
+// NamedVolumes returns the named volumes declared in a deployment configuration
+func (config Config) NamedVolumes() []VolumeConfig {
+	named := make([]VolumeConfig, 0)
+	for _, v := range config.Volumes {
+		if v.Type == NamedVolume {
+			named = append(named, v)
+		}
+	}
+	return named
+}
+
+// EnsureVolumes creates the named volumes (if they dont already exist) declared in a deployment configuration
+func EnsureVolumes(config Config) error {
+	ctx := context.Background()
+	defer ctx.Done()
+
+	for _, v := range config.NamedVolumes() {
+		if _, err := docker.GetClient().GetOneVolume(ctx, v.Source); err == nil {
+			continue
+		}
+
+		labels := map[string]string{docker.ContainerDeploymentLabel: config.Name}
+		if _, err := docker.GetClient().CreateVolume(ctx, v.Source, v.Driver, v.Options, labels); err != nil {
+			return fmt.Errorf("unable to create volume %s, %v", v.Source, err)
+		}
+		logger.Debugf("Created volume %s for deployment %s", v.Source, config.Name)
+	}
+
+	return nil
+}
+
+// GetVolumes returns all Docker volumes managed by Krane
+func GetVolumes() ([]KraneVolume, error) {
+	ctx := context.Background()
+	defer ctx.Done()
+
+	dockerVolumes, err := docker.GetClient().GetKraneVolumes(ctx)
+	if err != nil {
+		return make([]KraneVolume, 0), err
+	}
+
+	volumes := make([]KraneVolume, 0)
+	for _, v := range dockerVolumes {
+		volumes = append(volumes, fromDockerVolumeToKvolume(*v))
+	}
+
+	return volumes, nil
+}
+
+// GetVolume returns a Docker volume managed by Krane
+func GetVolume(name string) (KraneVolume, error) {
+	ctx := context.Background()
+	defer ctx.Done()
+
+	v, err := docker.GetClient().GetOneVolume(ctx, name)
+	if err != nil {
+		return KraneVolume{}, err
+	}
+
+	if _, ok := v.Labels[docker.VolumeManagedLabel]; !ok {
+		return KraneVolume{}, fmt.Errorf("volume %s is not managed by Krane", name)
+	}
+
+	return fromDockerVolumeToKvolume(v), nil
+}
+
+// CreateVolume creates a Docker volume managed by Krane
+func CreateVolume(name, driver string, options map[string]string) (KraneVolume, error) {
+	ctx := context.Background()
+	defer ctx.Done()
+
+	if !isValidVolumeName(name) {
+		return KraneVolume{}, fmt.Errorf("invalid volume name %s", name)
+	}
+
+	if _, err := docker.GetClient().GetOneVolume(ctx, name); err == nil {
+		return KraneVolume{}, fmt.Errorf("volume %s already exists", name)
+	}
+
+	v, err := docker.GetClient().CreateVolume(ctx, name, driver, options, nil)
+	if err != nil {
+		return KraneVolume{}, err
+	}
+
+	return fromDockerVolumeToKvolume(v), nil
+}
+
+// RemoveVolume removes a Docker volume managed by Krane. Volumes still referenced
+// by a deployment configuration are protected and cannot be removed.
+func RemoveVolume(name string) error {
+	ctx := context.Background()
+	defer ctx.Done()
+
+	if _, err := GetVolume(name); err != nil {
+		return err
+	}
+
+	deployments, err := deploymentsUsingVolume(name)
+	if err != nil {
+		return err
+	}
+
+	if len(deployments) > 0 {
+		return fmt.Errorf("volume %s is in use by deployment(s) %v", name, deployments)
+	}
+
+	return docker.GetClient().RemoveVolume(ctx, name, false)
+}
+
+// removeDeploymentVolumes removes the named volumes declared in a deployment configuration
+// which are not referenced by any other deployment
+func removeDeploymentVolumes(config Config) error {
+	ctx := context.Background()
+	defer ctx.Done()
+
+	for _, v := range config.NamedVolumes() {
+		deployments, err := deploymentsUsingVolume(v.Source)
+		if err != nil {
+			return err
+		}
+
+		shared := false
+		for _, d := range deployments {
+			if d != config.Name {
+				shared = true
+				break
+			}
+		}
+
+		if shared {
+			logger.Debugf("Volume %s is shared with other deployments, skipping removal", v.Source)
+			continue
+		}
+
+		logger.Debugf("Removing volume %s for deployment %s", v.Source, config.Name)
+		if err := docker.GetClient().RemoveVolume(ctx, v.Source, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deploymentsUsingVolume returns the deployments declaring a named volume in their configuration
+func deploymentsUsingVolume(name string) ([]string, error) {
+	configs, err := GetAllDeploymentConfigs()
+	if err != nil {
+		return make([]string, 0), err
+	}
+
+	deployments := make([]string, 0)
+	for _, config := range configs {
+		for _, v := range config.NamedVolumes() {
+			if v.Source == name {
+				deployments = append(deployments, config.Name)
+				break
+			}
+		}
+	}
+
+	return deployments, nil
+}
+
+// isValidVolumeName returns if a Docker volume name is valid or not
+func isValidVolumeName(name string) bool {
+	return volumeNameRegex.MatchString(name)
+}
+
+// fromDockerVolumeToKvolume converts a Docker volume into a KraneVolume
+func fromDockerVolumeToKvolume(v types.Volume) KraneVolume {
+	return KraneVolume{
+		Name:       v.Name,
+		Deployment: v.Labels[docker.ContainerDeploymentLabel],
+		Driver:     v.Driver,
+		Mountpoint: v.Mountpoint,
+		Options:    v.Options,
+		Labels:     v.Labels,
+	}
+}
+
 // fromMountPointToVolumeList converts a list of volume MountPoints into a list of formatted Krane Volumes
 func fromMountPointToVolumeList(mounts []types.MountPoint) []Volume {
 	volumes := make([]Volume, 0)
 	for _, m := range mounts {
 		volumes = append(volumes, Volume{
+			Type:            string(m.Type),
+			Name:            m.Name,
 			HostVolume:      m.Source,
 			ContainerVolume: m.Destination,
 		})
@@ -0,0 +1,70 @@
+package deployment
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/docker/docker/api/types/mount"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnmarshalLegacyVolumesAsBindVolumes(t *testing.T) {
+	var config Config
+	err := json.Unmarshal([]byte(`{"volumes": {"/host/path": "/container/path"}}`), &config)
+	assert.Nil(t, err)
+	assert.Len(t, config.Volumes, 1)
+	assert.Equal(t, BindVolume, config.Volumes[0].Type)
+	assert.Equal(t, "/host/path", config.Volumes[0].Source)
+	assert.Equal(t, "/container/path", config.Volumes[0].Target)
+}
+
+func TestUnmarshalVolumeList(t *testing.T) {
+	var config Config
+	err := json.Unmarshal([]byte(`{"volumes": [
+		{"type": "named", "source": "pgdata", "target": "/var/lib/postgresql/data"},
+		{"type": "tmpfs", "target": "/tmp", "options": {"size": "1024", "mode": "1777"}}
+	]}`), &config)
+	assert.Nil(t, err)
+	assert.Len(t, config.Volumes, 2)
+	assert.Equal(t, NamedVolume, config.Volumes[0].Type)
+	assert.Equal(t, "pgdata", config.Volumes[0].Source)
+	assert.Equal(t, TmpfsVolume, config.Volumes[1].Type)
+}
+
+func TestValidVolumeConfigs(t *testing.T) {
+	assert.Nil(t, VolumeConfig{Type: BindVolume, Source: "/data", Target: "/data"}.isValid())
+	assert.Nil(t, VolumeConfig{Type: NamedVolume, Source: "pgdata", Target: "/data"}.isValid())
+	assert.Nil(t, VolumeConfig{Type: TmpfsVolume, Target: "/tmp"}.isValid())
+}
+
+func TestInvalidVolumeConfigs(t *testing.T) {
+	assert.Error(t, VolumeConfig{Type: BindVolume, Source: "relative", Target: "/data"}.isValid())
+	assert.Error(t, VolumeConfig{Type: BindVolume, Source: "/data", Target: "relative"}.isValid())
+	assert.Error(t, VolumeConfig{Type: NamedVolume, Source: "/pgdata", Target: "/data"}.isValid())
+	assert.Error(t, VolumeConfig{Type: TmpfsVolume, Source: "/tmp", Target: "/tmp"}.isValid())
+	assert.Error(t, VolumeConfig{Type: TmpfsVolume, Target: "/tmp", Options: map[string]string{"size": "1g"}}.isValid())
+	assert.Error(t, VolumeConfig{Type: "nfs", Source: "/data", Target: "/data"}.isValid())
+}
+
+func TestDuplicateVolumeTargetsAreInvalid(t *testing.T) {
+	config := Config{
+		Name:  "example-deployment",
+		Image: "biensupernice/krane",
+		Volumes: VolumeConfigs{
+			{Type: NamedVolume, Source: "one", Target: "/data"},
+			{Type: NamedVolume, Source: "two", Target: "/data"},
+		},
+	}
+	assert.Error(t, config.isValid())
+}
+
+func TestVolumeConfigToDockerMount(t *testing.T) {
+	named := VolumeConfig{Type: NamedVolume, Source: "pgdata", Target: "/data", ReadOnly: true}.toDockerMount()
+	assert.Equal(t, mount.TypeVolume, named.Type)
+	assert.Equal(t, "pgdata", named.Source)
+	assert.True(t, named.ReadOnly)
+
+	tmpfs := VolumeConfig{Type: TmpfsVolume, Target: "/tmp", Options: map[string]string{"size": "1024"}}.toDockerMount()
+	assert.Equal(t, mount.TypeTmpfs, tmpfs.Type)
+	assert.Equal(t, int64(1024), tmpfs.TmpfsOptions.SizeBytes)
+}
@@ -0,0 +1,67 @@
+package docker
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+)
+
+const (
+	// VolumeHelperImage is the image used for helper containers reading and writing volume contents
+	VolumeHelperImage = "busybox"
+
+	// VolumeHelperMountPath is the path a volume is mounted to inside a helper container
+	VolumeHelperMountPath = "/volume"
+)
+
+// CreateVolumeHelperContainer creates a container (which is never started) with a volume
+// mounted at VolumeHelperMountPath, used to copy files in and out of the volume
+func (c *Client) CreateVolumeHelperContainer(ctx context.Context, volume mount.Mount) (string, error) {
+	reader, err := c.PullImage("docker.io", VolumeHelperImage, "latest")
+	if err != nil {
+		return "", err
+	}
+
+	// the image is only pulled once the pull output has been read to the end
+	_, err = io.Copy(ioutil.Discard, reader)
+	if closer, ok := reader.(io.Closer); ok {
+		_ = closer.Close()
+	}
+	if err != nil {
+		return "", err
+	}
+
+	volume.Target = VolumeHelperMountPath
+	body, err := c.ContainerCreate(
+		ctx,
+		&container.Config{
+			Image:  VolumeHelperImage,
+			Cmd:    []string{"true"},
+			Labels: map[string]string{VolumeManagedLabel: "helper"},
+		},
+		&container.HostConfig{Mounts: []mount.Mount{volume}},
+		nil,
+		"",
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return body.ID, nil
+}
+
+// ArchivePath returns a tar archive of the contents of a path inside a container.
+// It's up to the caller to close the reader.
+func (c *Client) ArchivePath(ctx context.Context, containerID string, path string) (io.ReadCloser, error) {
+	reader, _, err := c.CopyFromContainer(ctx, containerID, path+"/.")
+	return reader, err
+}
+
+// ExtractToPath extracts a tar archive into a path inside a container
+func (c *Client) ExtractToPath(ctx context.Context, containerID string, path string, archive io.Reader) error {
+	return c.CopyToContainer(ctx, containerID, path, archive, types.CopyToContainerOptions{})
+}
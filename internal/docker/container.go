@@ -13,7 +13,21 @@ import (
 	"github.com/docker/go-connections/nat"
 )
 
-const ContainerDeploymentLabel = "krane.deployment"
+const (
+	ContainerDeploymentLabel = "krane.deployment"
+
+	// ContainerSidecarLabel is the name of the sidecar a container runs as
+	ContainerSidecarLabel = "krane.sidecar"
+
+	// ContainerSidecarOfLabel is the ID of the container a sidecar container is attached to
+	ContainerSidecarOfLabel = "krane.sidecar.of"
+
+	// ContainerCanaryLabel marks the containers of a deployment receiving canary traffic
+	ContainerCanaryLabel = "krane.canary"
+
+	// ContainerColorLabel is the color (blue or green) of the containers of a blue/green deployment
+	ContainerColorLabel = "krane.color"
+)
 
 // DockerConfig properties required to create a docker container
 type DockerConfig struct {
@@ -29,18 +43,30 @@ type DockerConfig struct {
 	Env           []string // Comma separated, formatted NODE_ENV=dev
 	Command       []string
 	Entrypoint    []string
+	WorkingDir    string
+	User          string // user or uid, optionally with a group or gid (user:group)
+	NetworkMode   string // when set (ie. container:<id>) the container joins the network of another container instead of NetworkID
 }
 
 // CreateContainer creates a docker container from a docker config
 func (c *Client) CreateContainer(ctx context.Context, config DockerConfig) (container.ContainerCreateCreatedBody, error) {
+	hostname := config.ContainerName
 	networkingConfig := createNetworkingConfig(config.NetworkID, config.Aliases)
-	hostConfig := createHostConfig(config.Ports, config.VolumeMounts)
-	containerConfig := createContainerConfig(config.ContainerName,
+	if config.NetworkMode != "" {
+		// containers joining the network of another container share its hostname and network endpoints
+		hostname = ""
+		networkingConfig = network.NetworkingConfig{}
+	}
+
+	hostConfig := createHostConfig(config.Ports, config.VolumeMounts, config.NetworkMode)
+	containerConfig := createContainerConfig(hostname,
 		config.Image,
 		config.Env,
 		config.Labels,
 		config.Command,
 		config.Entrypoint,
+		config.WorkingDir,
+		config.User,
 		config.VolumeSet,
 		config.PortSet)
 
@@ -179,6 +205,8 @@ func createContainerConfig(
 	labels map[string]string,
 	command []string,
 	entrypoint []string,
+	workingDir string,
+	user string,
 	volumes map[string]struct{},
 	ports nat.PortSet) container.Config {
 	config := container.Config{
@@ -188,6 +216,8 @@ func createContainerConfig(
 		Labels:       labels,
 		Volumes:      volumes,
 		ExposedPorts: ports,
+		WorkingDir:   workingDir,
+		User:         user,
 	}
 
 	if len(command) > 0 {
@@ -202,10 +232,11 @@ func createContainerConfig(
 }
 
 // createHostConfig returns the host config for a Docker container
-func createHostConfig(ports nat.PortMap, volumes []mount.Mount) container.HostConfig {
+func createHostConfig(ports nat.PortMap, volumes []mount.Mount, networkMode string) container.HostConfig {
 	return container.HostConfig{
 		PortBindings: ports,
 		AutoRemove:   false,
 		Mounts:       volumes,
+		NetworkMode:  container.NetworkMode(networkMode),
 	}
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/docker/docker/api/types"
 )
@@ -13,7 +14,7 @@ func (c *Client) PullImage(registry, image, tag string) (io.Reader, error) {
 	ctx := context.Background()
 	defer ctx.Done()
 
-	ref := createImageRef(registry, image, tag)
+	ref := ImageRef(registry, image, tag)
 	return c.ImagePull(ctx, ref, types.ImagePullOptions{
 		All:          false,
 		RegistryAuth: Base64RegistryCredentials(),
@@ -29,10 +30,18 @@ func (c *Client) RemoveImage(ctx *context.Context, imageID string) ([]types.Imag
 	return c.ImageRemove(*ctx, imageID, options)
 }
 
-// createImageRef returns a formatted docker image url
-func createImageRef(registry, image, tag string) string {
+// ImageRef returns a formatted docker image url, containers are created from the same
+// reference the image was pulled with. A tag containing a colon (sha256:...) is a digest.
+// Official Docker Hub images are referenced by their canonical library/ name.
+func ImageRef(registry, image, tag string) string {
 	if tag == "" {
 		tag = "latest"
 	}
+	if registry == "docker.io" && !strings.Contains(image, "/") {
+		image = "library/" + image
+	}
+	if strings.Contains(tag, ":") {
+		return fmt.Sprintf("%s/%s@%s", registry, image, tag)
+	}
 	return fmt.Sprintf("%s/%s:%s", registry, image, tag)
 }
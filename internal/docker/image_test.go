@@ -0,0 +1,26 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImageRef(t *testing.T) {
+	tests := []struct {
+		registry string
+		image    string
+		tag      string
+		ref      string
+	}{
+		{"docker.io", "nginx", "", "docker.io/library/nginx:latest"},
+		{"docker.io", "nginx", "1.19", "docker.io/library/nginx:1.19"},
+		{"docker.io", "biensupernice/api", "1.0.0", "docker.io/biensupernice/api:1.0.0"},
+		{"ghcr.io", "org/app", "v2", "ghcr.io/org/app:v2"},
+		{"docker.io", "nginx", "sha256:abc", "docker.io/library/nginx@sha256:abc"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.ref, ImageRef(tt.registry, tt.image, tt.tag))
+	}
+}
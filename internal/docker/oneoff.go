@@ -0,0 +1,40 @@
+package docker
+
+import (
+	"context"
+	"io"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// OneOffContainerLabel is applied to short lived containers (ie. deployment hooks) with the name of the deployment they run for
+const OneOffContainerLabel = "krane.oneoff"
+
+// FollowContainerOutput returns the stdout and stderr of a container until the container exits.
+// Unlike the raw container logs, the output is demultiplexed so it can be read line by line.
+// It's up to the caller to close the reader, which stops following the output.
+func (c *Client) FollowContainerOutput(ctx context.Context, containerID string) (io.ReadCloser, error) {
+	stream, err := c.ContainerLogs(ctx, containerID, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		defer stream.Close()
+		_, err := stdcopy.StdCopy(writer, writer, stream)
+		_ = writer.CloseWithError(err)
+	}()
+
+	return reader, nil
+}
+
+// WaitContainer blocks until a container exits returning its exit code
+func (c *Client) WaitContainer(ctx context.Context, containerID string) (int64, error) {
+	return c.ContainerWait(ctx, containerID)
+}
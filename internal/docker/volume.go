@@ -0,0 +1,54 @@
+package docker
+
+import (
+	"context"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	volumetypes "github.com/docker/docker/api/types/volume"
+)
+
+// VolumeManagedLabel is the label applied to Docker volumes managed by Krane
+const VolumeManagedLabel = "krane.volume"
+
+// CreateVolume creates a Krane managed docker volume
+func (c *Client) CreateVolume(ctx context.Context, name string, driver string, options map[string]string, labels map[string]string) (types.Volume, error) {
+	if labels == nil {
+		labels = make(map[string]string, 0)
+	}
+	labels[VolumeManagedLabel] = "true"
+
+	if driver == "" {
+		driver = "local"
+	}
+
+	return c.VolumeCreate(ctx, volumetypes.VolumesCreateBody{
+		Name:       name,
+		Driver:     driver,
+		DriverOpts: options,
+		Labels:     labels,
+	})
+}
+
+// GetOneVolume returns a docker volume if it exists
+func (c *Client) GetOneVolume(ctx context.Context, name string) (types.Volume, error) {
+	return c.VolumeInspect(ctx, name)
+}
+
+// GetKraneVolumes returns all docker volumes managed by Krane
+func (c *Client) GetKraneVolumes(ctx context.Context) ([]*types.Volume, error) {
+	args := filters.NewArgs()
+	args.Add("label", VolumeManagedLabel)
+
+	resp, err := c.VolumeList(ctx, args)
+	if err != nil {
+		return make([]*types.Volume, 0), err
+	}
+
+	return resp.Volumes, nil
+}
+
+// RemoveVolume removes a docker volume
+func (c *Client) RemoveVolume(ctx context.Context, name string, force bool) error {
+	return c.VolumeRemove(ctx, name, force)
+}
@@ -22,6 +22,7 @@ type Job struct {
 	StartTime   int64          `json:"start_time_epoch"` // Job Start time - epoch in seconds since 1970
 	EndTime     int64          `json:"end_time_epoch"`   // Job end time - epoch in seconds since 1970
 	RetryPolicy uint           `json:"retry_policy"`     // Job retry policy
+	Result      interface{}    `json:"result,omitempty"` // Result reported by the job arguments (see Reporter) once the job completes
 	Args        interface{}    `json:"-"`                // Arguments passed down to job handlers
 	Setup       GenericHandler `json:"-"`                // Setup is the initial execution fn for a job typically to setup arguments
 	Run         GenericHandler `json:"-"`                // Run is the main executor fn for a job
@@ -31,6 +32,11 @@ type Job struct {
 // GenericHandler is a generic job handler that takes in job arguments
 type GenericHandler func(args interface{}) error
 
+// Reporter is implemented by job arguments which report a result stored with the job once it completes
+type Reporter interface {
+	Result() interface{}
+}
+
 // Serialize a job into bytes
 func (j *Job) Serialize() ([]byte, error) { return json.Marshal(j) }
 
@@ -50,6 +56,9 @@ func (j *Job) end() {
 	}
 	j.EndTime = time.Now().Unix()
 	j.State = Completed
+	if r, ok := j.Args.(Reporter); ok {
+		j.Result = r.Result()
+	}
 	j.save()
 }
 
@@ -40,3 +40,22 @@ func TestJobNotStartedOnCallToJobEnd(t *testing.T) {
 	assert.Equal(t, Completed, j.State)
 	assert.True(t, time.Now().Unix() >= j.EndTime)
 }
+
+type reportingArgs struct {
+	exitCode int
+}
+
+func (args *reportingArgs) Result() interface{} {
+	return map[string]int{"exit_code": args.exitCode}
+}
+
+func TestEndJobStoresReportedResult(t *testing.T) {
+	args := &reportingArgs{}
+	j := Job{Args: args}
+
+	j.start()
+	args.exitCode = 3
+	j.end()
+
+	assert.Equal(t, map[string]int{"exit_code": 3}, j.Result)
+}
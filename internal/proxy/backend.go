@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/krane/krane/internal/constants"
+)
+
+// proxy backends selected with PROXY_BACKEND
+const (
+	TraefikBackendName = "traefik"
+	CaddyBackendName   = "caddy"
+)
+
+// Feature is a deployment feature which not every proxy backend can configure
+type Feature string
+
+const (
+	CanaryFeature      Feature = "canary"
+	BlueGreenFeature   Feature = "blue_green"
+	TCPFeature         Feature = "tcp"
+	UDPFeature         Feature = "udp"
+	RateLimitFeature   Feature = "rate_limit"
+	HealthCheckFeature Feature = "health_check"
+)
+
+// Backend generates the configuration of the network proxy routing requests to deployments
+type Backend interface {
+	// Name returns the name the backend is selected with
+	Name() string
+
+	// Supports returns true if the backend can configure a feature
+	Supports(feature Feature) bool
+
+	// Labels returns the container labels configuring the proxy for a site, backends
+	// not discovering containers from labels return no labels
+	Labels(site Site) map[string]string
+
+	// WriteConfig writes the configuration files loaded by the proxy for all sites
+	WriteConfig(sites []Site) error
+}
+
+// Site is what the proxy needs to know about a deployment to route requests to its containers
+type Site struct {
+	Deployment  string      // name of the deployment, routers, middlewares and services are named after it
+	Aliases     []string    // hosts routed to the deployment
+	Routes      []Route     // hosts and path prefixes routed to the deployment
+	TCP         []TCPRoute  // raw TCP connections forwarded to the deployment
+	UDP         []UDPRoute  // UDP datagrams forwarded to the deployment
+	TLS         TLS         // how requests are secured
+	Middlewares Middlewares // middlewares applied to requests before reaching the containers
+	Upstream    Upstream    // containers receiving the requests
+	Maintenance bool        // requests are answered by the maintenance responder instead of the containers
+}
+
+// TLS represents how the requests of a site are secured
+type TLS struct {
+	Enabled      bool   // serve the site over HTTPS and redirect HTTP requests
+	CertResolver string // resolver requesting certificates (ie. Let's Encrypt)
+	Certificate  string // uploaded certificate served instead of a resolved certificate
+}
+
+// Upstream represents the containers of a deployment receiving requests
+type Upstream struct {
+	Host         string   // network alias resolving to the containers of the deployment
+	Ports        []string // container ports receiving HTTP requests
+	TargetPort   string   // container port receiving requests when several ports are exposed
+	LoadBalancer LoadBalancer
+}
+
+// port returns the container port requests are sent to
+func (upstream Upstream) port() string {
+	if upstream.TargetPort != "" {
+		return upstream.TargetPort
+	}
+
+	if len(upstream.Ports) > 0 {
+		return upstream.Ports[0]
+	}
+	return ""
+}
+
+// GetBackend returns the proxy backend selected with PROXY_BACKEND, defaults to Traefik
+func GetBackend() Backend {
+	backend, err := ParseBackend(os.Getenv(constants.EnvProxyBackend))
+	if err != nil {
+		return Traefik{}
+	}
+	return backend
+}
+
+// ParseBackend returns the proxy backend with a name
+func ParseBackend(name string) (Backend, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", TraefikBackendName:
+		return Traefik{}, nil
+	case CaddyBackendName:
+		return Caddy{}, nil
+	default:
+		return nil, fmt.Errorf("invalid proxy backend %s, must be %s or %s", name, TraefikBackendName, CaddyBackendName)
+	}
+}
@@ -0,0 +1,60 @@
+package proxy
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/krane/krane/internal/constants"
+)
+
+func TestParseBackend(t *testing.T) {
+	backend, err := ParseBackend("")
+	assert.Nil(t, err)
+	assert.Equal(t, TraefikBackendName, backend.Name())
+
+	backend, err = ParseBackend("Caddy")
+	assert.Nil(t, err)
+	assert.Equal(t, CaddyBackendName, backend.Name())
+
+	_, err = ParseBackend("nginx")
+	assert.Error(t, err)
+}
+
+func TestGetBackend(t *testing.T) {
+	defer os.Unsetenv(constants.EnvProxyBackend)
+
+	_ = os.Setenv(constants.EnvProxyBackend, "caddy")
+	assert.Equal(t, CaddyBackendName, GetBackend().Name())
+
+	_ = os.Setenv(constants.EnvProxyBackend, "nginx")
+	assert.Equal(t, TraefikBackendName, GetBackend().Name())
+}
+
+func TestTraefikBackendLabels(t *testing.T) {
+	site := Site{
+		Deployment: "api",
+		Aliases:    []string{"api.example.com"},
+		Routes:     []Route{{Host: "example.com", PathPrefix: "/api"}},
+		TLS:        TLS{Enabled: true, CertResolver: LetsEncryptResolver},
+		Upstream:   Upstream{Host: "api", Ports: []string{"8080"}, TargetPort: "8080"},
+	}
+
+	labels := Traefik{}.Labels(site)
+	assert.Equal(t, "true", labels["traefik.enable"])
+	assert.Equal(t, "Host(`api.example.com`)", labels["traefik.http.routers.api-secure.rule"])
+	assert.Equal(t, "Host(`example.com`) && PathPrefix(`/api`)", labels["traefik.http.routers.api-route-0-secure.rule"])
+	assert.Equal(t, "redirect-to-https,api-ratelimit", labels["traefik.http.routers.api-insecure.middlewares"])
+	assert.Equal(t, "8080", labels["traefik.http.services.api.loadbalancer.server.port"])
+
+	assert.Nil(t, Traefik{}.WriteConfig([]Site{site}))
+	assert.Empty(t, Caddy{}.Labels(site))
+}
+
+func TestBackendSupports(t *testing.T) {
+	for _, feature := range []Feature{CanaryFeature, BlueGreenFeature, TCPFeature, UDPFeature, RateLimitFeature, HealthCheckFeature} {
+		assert.True(t, Traefik{}.Supports(feature))
+		assert.False(t, Caddy{}.Supports(feature))
+	}
+}
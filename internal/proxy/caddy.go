@@ -0,0 +1,321 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/krane/krane/internal/constants"
+)
+
+// CaddyfileName is the Caddyfile written to the proxy config directory and loaded by the Caddy proxy
+const CaddyfileName = "Caddyfile"
+
+// caddyRefreshInterval is how often Caddy resolves the containers of a deployment
+const caddyRefreshInterval = "5s"
+
+// Caddy is the proxy backend rendering a Caddyfile for a Caddy proxy. Caddy doesn't discover
+// containers from labels, the Caddyfile is rewritten when deployments change and reloaded by Caddy.
+// Requests are sent to the network alias of a deployment, resolving to all of its containers.
+type Caddy struct{}
+
+// Name returns the name of the Caddy backend
+func (Caddy) Name() string {
+	return CaddyBackendName
+}
+
+// Supports returns true if Caddy can configure a feature, standard Caddy builds have no rate
+// limiting or layer 4 routing, and traffic splitting relies on Traefik dynamic configuration
+func (Caddy) Supports(feature Feature) bool {
+	switch feature {
+	case CanaryFeature, BlueGreenFeature, TCPFeature, UDPFeature, RateLimitFeature, HealthCheckFeature:
+		return false
+	default:
+		return true
+	}
+}
+
+// Labels returns no labels, Caddy sites are configured in the Caddyfile
+func (Caddy) Labels(site Site) map[string]string {
+	return make(map[string]string, 0)
+}
+
+// WriteConfig writes the Caddyfile of all sites to the proxy config directory
+func (Caddy) WriteConfig(sites []Site) error {
+	dir := os.Getenv(constants.EnvProxyConfigDir)
+	if dir == "" {
+		return errors.New("proxy config directory not set")
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	caddyfile := Caddyfile(sites, os.Getenv(constants.EnvLetsEncryptEmail))
+	return writeFile(filepath.Join(dir, CaddyfileName), []byte(caddyfile), 0644)
+}
+
+// caddyHandler is a path prefix of a host handled by a site
+type caddyHandler struct {
+	site        Site
+	pathPrefix  string
+	stripPrefix bool
+}
+
+// Caddyfile returns the Caddyfile serving sites. Sites sharing a host are served by the same site
+// block, their path prefixes handled longest first. A host is served over HTTPS when any site
+// routed to it is secure.
+func Caddyfile(sites []Site, email string) string {
+	handlers := make(map[string][]caddyHandler, 0)
+	for _, site := range sites {
+		if site.Upstream.port() == "" && !site.Maintenance {
+			continue
+		}
+
+		for _, alias := range site.Aliases {
+			if alias != "" {
+				handlers[alias] = append(handlers[alias], caddyHandler{site: site, pathPrefix: "/"})
+			}
+		}
+
+		for _, route := range site.Routes {
+			handlers[route.Host] = append(handlers[route.Host], caddyHandler{
+				site:        site,
+				pathPrefix:  route.PathPrefix,
+				stripPrefix: route.StripPrefix,
+			})
+		}
+	}
+
+	hosts := make([]string, 0)
+	for host := range handlers {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	var caddyfile bytes.Buffer
+	caddyfile.WriteString("# Generated by Krane, changes to this file are overwritten\n")
+	if email != "" {
+		caddyfile.WriteString(fmt.Sprintf("{\n\temail %s\n}\n", email))
+	}
+
+	for _, host := range hosts {
+		caddyfile.WriteString("\n")
+		writeCaddySite(&caddyfile, host, handlers[host])
+	}
+
+	return caddyfile.String()
+}
+
+// writeCaddySite writes the site block of a host
+func writeCaddySite(w *bytes.Buffer, host string, handlers []caddyHandler) {
+	sort.SliceStable(handlers, func(i, j int) bool {
+		if len(handlers[i].pathPrefix) != len(handlers[j].pathPrefix) {
+			return len(handlers[i].pathPrefix) > len(handlers[j].pathPrefix)
+		}
+		return handlers[i].site.Deployment < handlers[j].site.Deployment
+	})
+
+	secure := false
+	certificate := ""
+	for _, h := range handlers {
+		secure = secure || h.site.TLS.Enabled
+		if certificate == "" && h.site.TLS.Enabled {
+			certificate = h.site.TLS.Certificate
+		}
+	}
+
+	// Caddy serves hostnames over HTTPS and redirects HTTP requests unless the address is http://
+	if secure {
+		w.WriteString(fmt.Sprintf("%s {\n", host))
+	} else {
+		w.WriteString(fmt.Sprintf("http://%s {\n", host))
+	}
+
+	if certificate != "" {
+		w.WriteString(fmt.Sprintf("\ttls %s %s\n",
+			filepath.Join(FileProviderDirectory, certificatesDirectory, fmt.Sprintf("%s.crt", certificate)),
+			filepath.Join(FileProviderDirectory, certificatesDirectory, fmt.Sprintf("%s.key", certificate))))
+	}
+
+	for _, h := range handlers {
+		switch {
+		case h.pathPrefix == "" || h.pathPrefix == "/":
+			w.WriteString("\n\thandle {\n")
+		case h.stripPrefix:
+			w.WriteString(fmt.Sprintf("\n\thandle_path %s* {\n", h.pathPrefix))
+		default:
+			w.WriteString(fmt.Sprintf("\n\thandle %s* {\n", h.pathPrefix))
+		}
+		writeCaddyHandler(w, h.site, "\t\t")
+		w.WriteString("\t}\n")
+	}
+
+	w.WriteString("}\n")
+}
+
+// writeCaddyHandler writes the middlewares of a site followed by the reverse proxy to its containers, or to
+// the maintenance responder when the site is in maintenance.
+// Directives of a route block run in the order they are written, the same order as the Traefik middlewares.
+func writeCaddyHandler(w *bytes.Buffer, site Site, indent string) {
+	mw := site.Middlewares
+	line := func(format string, a ...interface{}) {
+		w.WriteString(indent + fmt.Sprintf(format, a...) + "\n")
+	}
+
+	// sites in maintenance are answered by the maintenance responder
+	if site.Maintenance {
+		line("rewrite * %s", maintenancePath(site.Deployment))
+		line("reverse_proxy %s:%s", MaintenanceResponderName, MaintenanceResponderPort)
+		return
+	}
+
+	if len(mw.IPAllowList) > 0 {
+		line("@denied not remote_ip %s", strings.Join(mw.IPAllowList, " "))
+	}
+
+	if mw.CORS != nil {
+		line("@cors header Origin %s", strings.Join(mw.CORS.AllowOrigins, " "))
+		line("@preflight {")
+		line("\tmethod OPTIONS")
+		line("\theader Origin %s", strings.Join(mw.CORS.AllowOrigins, " "))
+		line("\theader Access-Control-Request-Method *")
+		line("}")
+	}
+
+	line("route {")
+
+	// ip allow list
+	if len(mw.IPAllowList) > 0 {
+		line("\trespond @denied 403")
+	}
+
+	// headers, applied before basic auth so CORS preflight requests are answered without credentials
+	for _, header := range sortedKeys(mw.RequestHeaders) {
+		if value := mw.RequestHeaders[header]; value != "" {
+			line("\trequest_header %s %s", header, caddyQuote(value))
+		} else {
+			line("\trequest_header -%s", header)
+		}
+	}
+
+	for _, header := range sortedKeys(mw.ResponseHeaders) {
+		if value := mw.ResponseHeaders[header]; value != "" {
+			line("\theader >%s %s", header, caddyQuote(value))
+		} else {
+			line("\theader -%s", header)
+		}
+	}
+
+	if mw.HSTS != nil {
+		hsts := fmt.Sprintf("max-age=%d", mw.HSTS.MaxAge)
+		if mw.HSTS.IncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if mw.HSTS.Preload {
+			hsts += "; preload"
+		}
+		line("\theader >Strict-Transport-Security %s", caddyQuote(hsts))
+	}
+
+	if cors := mw.CORS; cors != nil {
+		line("\theader @cors {")
+		line("\t\t>Access-Control-Allow-Origin {http.request.header.Origin}")
+		line("\t\t>Access-Control-Allow-Credentials %s", strconv.FormatBool(cors.AllowCredentials))
+		if len(cors.ExposeHeaders) > 0 {
+			line("\t\t>Access-Control-Expose-Headers %s", caddyQuote(strings.Join(cors.ExposeHeaders, ", ")))
+		}
+		line("\t\t>Vary Origin")
+		line("\t}")
+
+		line("\theader @preflight {")
+		if len(cors.AllowMethods) > 0 {
+			line("\t\tAccess-Control-Allow-Methods %s", caddyQuote(strings.Join(cors.AllowMethods, ", ")))
+		}
+		if len(cors.AllowHeaders) > 0 {
+			line("\t\tAccess-Control-Allow-Headers %s", caddyQuote(strings.Join(cors.AllowHeaders, ", ")))
+		}
+		if cors.MaxAge > 0 {
+			line("\t\tAccess-Control-Max-Age %d", cors.MaxAge)
+		}
+		line("\t}")
+		line("\trespond @preflight 204")
+	}
+
+	// basic auth, credentials are not forwarded to the containers. When no user resolves every
+	// request is denied, routes are never left without auth when it is configured
+	if mw.basicAuth() && len(mw.BasicAuthUsers) == 0 {
+		line("\trespond 401")
+	} else if mw.basicAuth() {
+		if mw.BasicAuthRealm != "" {
+			line("\tbasic_auth bcrypt %s {", caddyQuote(mw.BasicAuthRealm))
+		} else {
+			line("\tbasic_auth {")
+		}
+		for _, user := range mw.BasicAuthUsers {
+			parts := strings.SplitN(user, ":", 2)
+			if len(parts) == 2 {
+				line("\t\t%s %s", parts[0], parts[1])
+			}
+		}
+		line("\t}")
+		line("\trequest_header -Authorization")
+	}
+
+	// compression
+	if mw.Compress {
+		line("\tencode gzip zstd")
+	}
+
+	writeCaddyReverseProxy(w, site.Upstream, indent+"\t")
+	line("}")
+}
+
+// writeCaddyReverseProxy writes the reverse proxy balancing requests between the containers of a site
+func writeCaddyReverseProxy(w *bytes.Buffer, upstream Upstream, indent string) {
+	line := func(format string, a ...interface{}) {
+		w.WriteString(indent + fmt.Sprintf(format, a...) + "\n")
+	}
+
+	line("reverse_proxy {")
+	line("\tdynamic a {")
+	line("\t\tname %s", upstream.Host)
+	line("\t\tport %s", upstream.port())
+	line("\t\trefresh %s", caddyRefreshInterval)
+	line("\t}")
+
+	if sticky := upstream.LoadBalancer.Sticky; sticky != nil {
+		if sticky.Name != "" {
+			line("\tlb_policy cookie %s", sticky.Name)
+		} else {
+			line("\tlb_policy cookie")
+		}
+	}
+
+	// Caddy forwards the client Host header by default
+	if pass := upstream.LoadBalancer.PassHostHeader; pass != nil && !*pass {
+		line("\theader_up Host {upstream_hostport}")
+	}
+
+	line("}")
+}
+
+// caddyQuote returns a Caddyfile quoted token
+func caddyQuote(value string) string {
+	return fmt.Sprintf(`"%s"`, strings.ReplaceAll(value, `"`, `\"`))
+}
+
+// sortedKeys returns the keys of a map in order, so the Caddyfile only changes when sites change
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0)
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,145 @@
+package proxy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/krane/krane/internal/constants"
+	"github.com/krane/krane/internal/proxy/middlewares"
+)
+
+func TestCaddyfile(t *testing.T) {
+	passHostHeader := false
+	sites := []Site{
+		{
+			Deployment: "web",
+			Aliases:    []string{"example.com"},
+			TLS:        TLS{Enabled: true, Certificate: "example"},
+			Upstream:   Upstream{Host: "web", Ports: []string{"3000"}},
+		},
+		{
+			Deployment: "api",
+			Routes:     []Route{{Host: "example.com", PathPrefix: "/api", StripPrefix: true}},
+			Middlewares: Middlewares{
+				IPAllowList:     []string{"10.0.0.0/8"},
+				ResponseHeaders: map[string]string{"X-Frame-Options": "DENY", "Server": ""},
+				HSTS:            &middlewares.HSTS{MaxAge: 31536000, IncludeSubdomains: true},
+				BasicAuthUsers:  []string{"admin:$2a$10$hash"},
+				BasicAuthRealm:  "api",
+				Compress:        true,
+			},
+			Upstream: Upstream{
+				Host:       "api",
+				Ports:      []string{"8080", "9090"},
+				TargetPort: "9090",
+				LoadBalancer: LoadBalancer{
+					Sticky:         &StickyCookie{Name: "api_session"},
+					PassHostHeader: &passHostHeader,
+				},
+			},
+		},
+		{
+			Deployment: "docs",
+			Aliases:    []string{"docs.example.com"},
+			Upstream:   Upstream{Host: "docs", Ports: []string{"80"}},
+		},
+		{
+			// no container port to send requests to
+			Deployment: "worker",
+			Aliases:    []string{"worker.example.com"},
+		},
+	}
+
+	caddyfile := Caddyfile(sites, "admin@example.com")
+	assert.Contains(t, caddyfile, "{\n\temail admin@example.com\n}\n")
+	assert.NotContains(t, caddyfile, "worker.example.com")
+
+	// hosts are sorted, insecure hosts are only served over HTTP
+	assert.Contains(t, caddyfile, "http://docs.example.com {\n")
+	assert.Contains(t, caddyfile, "\nexample.com {\n\ttls /etc/krane/proxy/certs/example.crt /etc/krane/proxy/certs/example.key\n")
+	assert.Less(t, strings.Index(caddyfile, "docs.example.com"), strings.Index(caddyfile, "\nexample.com"))
+
+	// longer path prefixes are handled first
+	api := strings.Index(caddyfile, "\thandle_path /api* {\n")
+	web := strings.Index(caddyfile, "\thandle {\n\t\troute {\n\t\t\treverse_proxy {\n\t\t\t\tdynamic a {\n\t\t\t\t\tname web\n")
+	assert.True(t, api > 0 && web > api)
+
+	// middlewares run in order before the reverse proxy
+	expected := []string{
+		"\t\t@denied not remote_ip 10.0.0.0/8\n",
+		"\t\t\trespond @denied 403\n",
+		"\t\t\theader -Server\n",
+		"\t\t\theader >X-Frame-Options \"DENY\"\n",
+		"\t\t\theader >Strict-Transport-Security \"max-age=31536000; includeSubDomains\"\n",
+		"\t\t\tbasic_auth bcrypt \"api\" {\n\t\t\t\tadmin $2a$10$hash\n\t\t\t}\n",
+		"\t\t\trequest_header -Authorization\n",
+		"\t\t\tencode gzip zstd\n",
+		"\t\t\t\t\tname api\n\t\t\t\t\tport 9090\n",
+		"\t\t\t\tlb_policy cookie api_session\n",
+		"\t\t\t\theader_up Host {upstream_hostport}\n",
+	}
+
+	last := 0
+	for _, directive := range expected {
+		i := strings.Index(caddyfile, directive)
+		assert.Greater(t, i, last, directive)
+		last = i
+	}
+}
+
+func TestCaddyfileCORS(t *testing.T) {
+	site := Site{
+		Deployment: "api",
+		Aliases:    []string{"api.example.com"},
+		Middlewares: Middlewares{CORS: &middlewares.CORS{
+			AllowOrigins: []string{"https://example.com"},
+			AllowMethods: []string{"GET", "POST"},
+			MaxAge:       600,
+		}},
+		Upstream: Upstream{Host: "api", Ports: []string{"8080"}},
+	}
+
+	caddyfile := Caddyfile([]Site{site}, "")
+	assert.NotContains(t, caddyfile, "email")
+	assert.Contains(t, caddyfile, "\t\t@cors header Origin https://example.com\n")
+	assert.Contains(t, caddyfile, "\t\t\t\t>Access-Control-Allow-Origin {http.request.header.Origin}\n")
+	assert.Contains(t, caddyfile, "\t\t\t\tAccess-Control-Allow-Methods \"GET, POST\"\n")
+	assert.Contains(t, caddyfile, "\t\t\t\tAccess-Control-Max-Age 600\n")
+	assert.Contains(t, caddyfile, "\t\t\trespond @preflight 204\n")
+}
+
+func TestCaddyfileBasicAuthDeniesWithoutUsers(t *testing.T) {
+	site := Site{
+		Deployment:  "api",
+		Aliases:     []string{"api.example.com"},
+		Middlewares: Middlewares{BasicAuth: true, BasicAuthRealm: "api"},
+		Upstream:    Upstream{Host: "api", Ports: []string{"8080"}},
+	}
+
+	// configured basic auth without resolved users never routes to the containers
+	caddyfile := Caddyfile([]Site{site}, "")
+	assert.NotContains(t, caddyfile, "basic_auth")
+	assert.Contains(t, caddyfile, "\t\t\trespond 401\n")
+	assert.Less(t, strings.Index(caddyfile, "\t\t\trespond 401\n"), strings.Index(caddyfile, "reverse_proxy"))
+}
+
+func TestCaddyWriteConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "krane-proxy")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	_ = os.Setenv(constants.EnvProxyConfigDir, dir)
+	defer os.Unsetenv(constants.EnvProxyConfigDir)
+
+	site := Site{Deployment: "api", Aliases: []string{"api.example.com"}, Upstream: Upstream{Host: "api", Ports: []string{"8080"}}}
+	assert.Nil(t, Caddy{}.WriteConfig([]Site{site}))
+
+	bytes, err := ioutil.ReadFile(filepath.Join(dir, CaddyfileName))
+	assert.Nil(t, err)
+	assert.Equal(t, Caddyfile([]Site{site}, ""), string(bytes))
+}
@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/krane/krane/internal/constants"
+)
+
+// CertificatesConfigName is the name of the dynamic configuration listing the uploaded certificates
+const CertificatesConfigName = "certificates"
+
+// certificatesDirectory is the directory, relative to the proxy config directory, certificate files are written to.
+// The proxy file provider only loads configuration files (.yml, .toml) and leaves certificate files alone.
+const certificatesDirectory = "certs"
+
+// TraefikCertificatesConfig returns a dynamic configuration loading uploaded certificates into the proxy
+// certificate store, secure routers without a cert resolver are served the certificate matching the request
+func TraefikCertificatesConfig(names []string) DynamicConfig {
+	certificates := make([]TLSCertificate, 0)
+	for _, name := range names {
+		certificates = append(certificates, TLSCertificate{
+			CertFile: filepath.Join(FileProviderDirectory, certificatesDirectory, fmt.Sprintf("%s.crt", name)),
+			KeyFile:  filepath.Join(FileProviderDirectory, certificatesDirectory, fmt.Sprintf("%s.key", name)),
+		})
+	}
+
+	return DynamicConfig{TLS: &TLSConfig{Certificates: certificates}}
+}
+
+// WriteCertificate writes a PEM encoded certificate and private key loaded by the proxy.
+// Files are only readable by their owner, the private key is stored in plain text for the proxy to load it.
+func WriteCertificate(name string, cert []byte, key []byte) error {
+	dir := os.Getenv(constants.EnvProxyConfigDir)
+	if dir == "" {
+		return errors.New("proxy config directory not set")
+	}
+
+	certsDir := filepath.Join(dir, certificatesDirectory)
+	if err := os.MkdirAll(certsDir, 0700); err != nil {
+		return err
+	}
+
+	if err := writeFile(filepath.Join(certsDir, fmt.Sprintf("%s.crt", name)), cert, 0600); err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(certsDir, fmt.Sprintf("%s.key", name)), key, 0600)
+}
+
+// RemoveCertificate removes the certificate and private key files of a certificate
+func RemoveCertificate(name string) error {
+	dir := os.Getenv(constants.EnvProxyConfigDir)
+	if dir == "" {
+		return nil
+	}
+
+	for _, ext := range []string{"crt", "key"} {
+		err := os.Remove(filepath.Join(dir, certificatesDirectory, fmt.Sprintf("%s.%s", name, ext)))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/krane/krane/internal/constants"
+)
+
+// entryPointNameRegex matches entrypoint names, names are part of the proxy environment variables declaring them
+var entryPointNameRegex = regexp.MustCompile("^[a-z][a-z0-9]*$")
+
+// reservedEntryPoints are the entrypoints of the proxy receiving http traffic and serving its dashboard
+var reservedEntryPoints = map[string]bool{"web": true, "traefik": true}
+
+// EntryPoint is a port the proxy listens on for raw TCP or UDP connections
+type EntryPoint struct {
+	Name     string
+	Port     string
+	Protocol string // tcp or udp
+}
+
+// ParseEntryPoints parses a comma separated list of entrypoints formatted as name:port[/protocol]
+// where the protocol is either tcp (default) or udp, ie. postgres:5432,dns:53/udp
+func ParseEntryPoints(raw string) ([]EntryPoint, error) {
+	entryPoints := make([]EntryPoint, 0)
+	seen := make(map[string]bool, 0)
+	for _, spec := range splitList(raw) {
+		ep := EntryPoint{Protocol: "tcp"}
+		if i := strings.LastIndex(spec, "/"); i != -1 {
+			ep.Protocol = strings.ToLower(spec[i+1:])
+			spec = spec[:i]
+		}
+
+		parts := strings.Split(spec, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid entrypoint %s, must be name:port[/protocol]", spec)
+		}
+		ep.Name, ep.Port = strings.ToLower(parts[0]), parts[1]
+
+		if !entryPointNameRegex.MatchString(ep.Name) || reservedEntryPoints[ep.Name] {
+			return nil, fmt.Errorf("invalid entrypoint name %s", ep.Name)
+		}
+
+		if port, err := strconv.Atoi(ep.Port); err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid port %s for entrypoint %s", ep.Port, ep.Name)
+		}
+
+		if ep.Protocol != "tcp" && ep.Protocol != "udp" {
+			return nil, fmt.Errorf("invalid protocol %s for entrypoint %s, must be tcp or udp", ep.Protocol, ep.Name)
+		}
+
+		if seen[ep.Name] {
+			return nil, fmt.Errorf("entrypoint %s is declared more than once", ep.Name)
+		}
+		seen[ep.Name] = true
+
+		entryPoints = append(entryPoints, ep)
+	}
+	return entryPoints, nil
+}
+
+// EntryPoints returns the TCP and UDP entrypoints exposed by the proxy
+func EntryPoints() ([]EntryPoint, error) {
+	return ParseEntryPoints(os.Getenv(constants.EnvProxyEntryPoints))
+}
+
+// GetEntryPoint returns an entrypoint exposed by the proxy
+func GetEntryPoint(name string) (EntryPoint, error) {
+	entryPoints, err := EntryPoints()
+	if err != nil {
+		return EntryPoint{}, err
+	}
+
+	for _, ep := range entryPoints {
+		if ep.Name == name {
+			return ep, nil
+		}
+	}
+	return EntryPoint{}, fmt.Errorf("entrypoint %s is not exposed by the proxy", name)
+}
+
+// TraefikEntryPointEnv returns the environment variables declaring entrypoints in the proxy static configuration
+func TraefikEntryPointEnv(entryPoints []EntryPoint) map[string]string {
+	env := make(map[string]string, 0)
+	for _, ep := range entryPoints {
+		env[fmt.Sprintf("TRAEFIK_ENTRYPOINTS_%s_ADDRESS", strings.ToUpper(ep.Name))] = fmt.Sprintf(":%s/%s", ep.Port, ep.Protocol)
+	}
+	return env
+}
@@ -0,0 +1,34 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEntryPoints(t *testing.T) {
+	entryPoints, err := ParseEntryPoints("postgres:5432, dns:53/UDP")
+	assert.Nil(t, err)
+	assert.Equal(t, []EntryPoint{
+		{Name: "postgres", Port: "5432", Protocol: "tcp"},
+		{Name: "dns", Port: "53", Protocol: "udp"},
+	}, entryPoints)
+
+	entryPoints, err = ParseEntryPoints("")
+	assert.Nil(t, err)
+	assert.Empty(t, entryPoints)
+
+	invalid := []string{"postgres", "postgres:port", "postgres:70000", "pg-sql:5432", "web:8000", "dns:53/sctp", "db:5432,db:5433"}
+	for _, raw := range invalid {
+		_, err := ParseEntryPoints(raw)
+		assert.Error(t, err, raw)
+	}
+}
+
+func TestTraefikEntryPointEnv(t *testing.T) {
+	env := TraefikEntryPointEnv([]EntryPoint{{Name: "postgres", Port: "5432", Protocol: "tcp"}, {Name: "dns", Port: "53", Protocol: "udp"}})
+	assert.Equal(t, map[string]string{
+		"TRAEFIK_ENTRYPOINTS_POSTGRES_ADDRESS": ":5432/tcp",
+		"TRAEFIK_ENTRYPOINTS_DNS_ADDRESS":      ":53/udp",
+	}, env)
+}
@@ -0,0 +1,258 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/krane/krane/internal/constants"
+)
+
+// FileProviderDirectory is where the proxy container loads dynamic configuration files written by Krane
+const FileProviderDirectory = "/etc/krane/proxy"
+
+// DynamicConfig is a Traefik dynamic configuration loaded by the proxy file provider. Used for
+// configuration which can't be expressed with Docker labels, like weighted services.
+type DynamicConfig struct {
+	HTTP HTTPConfig `yaml:"http,omitempty"`
+	TLS  *TLSConfig `yaml:"tls,omitempty"`
+}
+
+// HTTPConfig represents the http routers, middlewares and services of a dynamic configuration
+type HTTPConfig struct {
+	Routers     map[string]Router     `yaml:"routers,omitempty"`
+	Middlewares map[string]Middleware `yaml:"middlewares,omitempty"`
+	Services    map[string]Service    `yaml:"services,omitempty"`
+}
+
+// Router represents a Traefik http router
+type Router struct {
+	Rule        string     `yaml:"rule"`
+	Priority    int        `yaml:"priority,omitempty"`
+	EntryPoints []string   `yaml:"entryPoints,omitempty"`
+	Middlewares []string   `yaml:"middlewares,omitempty"`
+	Service     string     `yaml:"service"`
+	TLS         *RouterTLS `yaml:"tls,omitempty"`
+}
+
+// RouterTLS represents the TLS configuration of a Traefik http router
+type RouterTLS struct {
+	CertResolver string `yaml:"certResolver,omitempty"`
+}
+
+// Middleware represents a Traefik http middleware
+type Middleware struct {
+	ReplacePath    *ReplacePath    `yaml:"replacePath,omitempty"`
+	RedirectScheme *RedirectScheme `yaml:"redirectScheme,omitempty"`
+}
+
+// ReplacePath replaces the path of requests
+type ReplacePath struct {
+	Path string `yaml:"path"`
+}
+
+// RedirectScheme redirects requests to another scheme
+type RedirectScheme struct {
+	Scheme    string `yaml:"scheme"`
+	Port      string `yaml:"port,omitempty"`
+	Permanent bool   `yaml:"permanent"`
+}
+
+// TLSConfig represents the certificates served by the proxy, matched to requests by server name
+type TLSConfig struct {
+	Certificates []TLSCertificate `yaml:"certificates"`
+}
+
+// TLSCertificate represents a certificate and private key file loaded by the proxy
+type TLSCertificate struct {
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+}
+
+// Service represents a Traefik http service
+type Service struct {
+	Weighted *WeightedService `yaml:"weighted,omitempty"`
+}
+
+// WeightedService splits traffic between services by weight
+type WeightedService struct {
+	Services []WeightedServiceRef `yaml:"services"`
+}
+
+// WeightedServiceRef is a service receiving part of the traffic of a weighted service
+type WeightedServiceRef struct {
+	Name   string `yaml:"name"`
+	Weight int    `yaml:"weight"`
+}
+
+// TraefikServiceName returns the name of the service the routers of a deployment forward requests to
+func TraefikServiceName(deployment string, ports []string, targetPort string) string {
+	if targetPort != "" {
+		return deployment
+	}
+
+	if len(ports) > 0 {
+		return fmt.Sprintf("%s-%s", deployment, ports[0])
+	}
+
+	return ""
+}
+
+// WeightedServiceName returns the name of the weighted service of a deployment
+func WeightedServiceName(deployment string) string {
+	return fmt.Sprintf("%s-weighted", deployment)
+}
+
+// TraefikWeightedConfig returns a dynamic configuration splitting the traffic of a deployment
+// between services. The routers declared in the Docker labels of the deployment are mirrored with a
+// higher priority and forwarded to a weighted service, Docker labels can't declare weighted services.
+func TraefikWeightedConfig(deployment string, labels map[string]string, services []WeightedServiceRef) DynamicConfig {
+	weighted := WeightedServiceName(deployment)
+
+	return DynamicConfig{
+		HTTP: HTTPConfig{
+			Routers: mirrorRouters(labels, "weighted", weighted),
+			Services: map[string]Service{
+				weighted: {Weighted: &WeightedService{Services: services}},
+			},
+		},
+	}
+}
+
+// TraefikSwitchConfig returns a dynamic configuration forwarding the routers declared in Docker labels
+// to a service, switching traffic between sets of containers is then done by rewriting the configuration
+func TraefikSwitchConfig(labels map[string]string, service string) DynamicConfig {
+	return DynamicConfig{
+		HTTP: HTTPConfig{
+			Routers: mirrorRouters(labels, "switch", service),
+		},
+	}
+}
+
+// mirrorRouters returns the routers declared in Docker labels with a higher priority, forwarded to a service
+func mirrorRouters(labels map[string]string, suffix string, service string) map[string]Router {
+	routers := make(map[string]Router, 0)
+	for name, router := range routersFromLabels(labels) {
+		router.Service = service
+		router.Priority++
+		routers[fmt.Sprintf("%s-%s", name, suffix)] = router
+	}
+	return routers
+}
+
+// routersFromLabels returns the routers declared in Docker labels. Router names and middlewares
+// are relative to the docker provider, routers without a rule are left out since their default rule
+// can't be known.
+func routersFromLabels(labels map[string]string) map[string]Router {
+	prefix := "traefik.http.routers."
+	fields := make(map[string]map[string]string, 0)
+	for k, v := range labels {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+
+		parts := strings.SplitN(strings.TrimPrefix(k, prefix), ".", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		if fields[parts[0]] == nil {
+			fields[parts[0]] = make(map[string]string, 0)
+		}
+		fields[parts[0]][parts[1]] = v
+	}
+
+	routers := make(map[string]Router, 0)
+	for name, f := range fields {
+		if f["rule"] == "" {
+			continue
+		}
+
+		// routers without an explicit priority default to the length of their rule
+		priority, err := strconv.Atoi(f["priority"])
+		if err != nil {
+			priority = len(f["rule"])
+		}
+
+		router := Router{
+			Rule:        f["rule"],
+			Priority:    priority,
+			EntryPoints: splitList(f["entrypoints"]),
+			Middlewares: make([]string, 0),
+		}
+
+		for _, mw := range splitList(f["middlewares"]) {
+			if !strings.Contains(mw, "@") {
+				mw = mw + "@docker"
+			}
+			router.Middlewares = append(router.Middlewares, mw)
+		}
+
+		if f["tls"] == "true" {
+			router.TLS = &RouterTLS{CertResolver: f["tls.certresolver"]}
+		}
+
+		routers[name] = router
+	}
+
+	return routers
+}
+
+// splitList returns the non-empty values of a comma separated list
+func splitList(list string) []string {
+	values := make([]string, 0)
+	for _, v := range strings.Split(list, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// WriteDynamicConfig writes a dynamic configuration file picked up by the proxy file provider
+func WriteDynamicConfig(name string, config DynamicConfig) error {
+	dir := os.Getenv(constants.EnvProxyConfigDir)
+	if dir == "" {
+		return errors.New("proxy config directory not set")
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	bytes, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	return writeFile(filepath.Join(dir, fmt.Sprintf("%s.yml", name)), bytes, 0644)
+}
+
+// writeFile writes to a temporary file first so the proxy never loads a partially written file
+func writeFile(path string, bytes []byte, perm os.FileMode) error {
+	tmp := filepath.Join(filepath.Dir(path), fmt.Sprintf(".%s.tmp", filepath.Base(path)))
+	if err := ioutil.WriteFile(tmp, bytes, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// RemoveDynamicConfig removes a dynamic configuration file from the proxy file provider
+func RemoveDynamicConfig(name string) error {
+	dir := os.Getenv(constants.EnvProxyConfigDir)
+	if dir == "" {
+		return nil
+	}
+
+	err := os.Remove(filepath.Join(dir, fmt.Sprintf("%s.yml", name)))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/krane/krane/internal/constants"
+)
+
+func TestTraefikWeightedConfig(t *testing.T) {
+	labels := TraefikRouterLabels("api", []string{"api.example.com"}, true, LetsEncryptResolver)
+	for k, v := range TraefikMiddlewareLabels("api", true, Middlewares{}, []Route{}) {
+		labels[k] = v
+	}
+
+	services := []WeightedServiceRef{{Name: "api@docker", Weight: 90}, {Name: "api-canary@docker", Weight: 10}}
+	config := TraefikWeightedConfig("api", labels, services)
+
+	assert.Equal(t, services, config.HTTP.Services["api-weighted"].Weighted.Services)
+	assert.Len(t, config.HTTP.Routers, 2)
+
+	insecure := config.HTTP.Routers["api-insecure-weighted"]
+	assert.Equal(t, "Host(`api.example.com`)", insecure.Rule)
+	assert.Equal(t, len("Host(`api.example.com`)")+1, insecure.Priority)
+	assert.Equal(t, []string{"web"}, insecure.EntryPoints)
+	assert.Equal(t, []string{"redirect-to-https@docker", "api-ratelimit@docker"}, insecure.Middlewares)
+	assert.Equal(t, "api-weighted", insecure.Service)
+	assert.Nil(t, insecure.TLS)
+
+	secure := config.HTTP.Routers["api-secure-weighted"]
+	assert.Equal(t, []string{"api-ratelimit@docker"}, secure.Middlewares)
+	assert.Equal(t, &RouterTLS{CertResolver: "lets-encrypt"}, secure.TLS)
+}
+
+func TestTraefikSwitchConfig(t *testing.T) {
+	labels := TraefikRouterLabels("api-blue", []string{"api.example.com"}, false, LetsEncryptResolver)
+	config := TraefikSwitchConfig(labels, "api-blue@docker")
+
+	assert.Empty(t, config.HTTP.Services)
+	assert.Len(t, config.HTTP.Routers, 1)
+
+	router := config.HTTP.Routers["api-blue-insecure-switch"]
+	assert.Equal(t, "Host(`api.example.com`)", router.Rule)
+	assert.Equal(t, len("Host(`api.example.com`)")+1, router.Priority)
+	assert.Equal(t, "api-blue@docker", router.Service)
+}
+
+func TestTraefikServiceName(t *testing.T) {
+	assert.Equal(t, "api", TraefikServiceName("api", []string{"8080"}, "8080"))
+	assert.Equal(t, "api-8080", TraefikServiceName("api", []string{"8080", "9090"}, ""))
+	assert.Equal(t, "", TraefikServiceName("api", []string{}, ""))
+}
+
+func TestWriteAndRemoveDynamicConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "krane-proxy")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	_ = os.Setenv(constants.EnvProxyConfigDir, dir)
+	defer os.Unsetenv(constants.EnvProxyConfigDir)
+
+	config := TraefikWeightedConfig("api", map[string]string{}, []WeightedServiceRef{{Name: "api@docker", Weight: 100}})
+	assert.Nil(t, WriteDynamicConfig("api-canary", config))
+
+	bytes, err := ioutil.ReadFile(filepath.Join(dir, "api-canary.yml"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(bytes), "name: api@docker")
+
+	assert.Nil(t, RemoveDynamicConfig("api-canary"))
+	assert.Nil(t, RemoveDynamicConfig("api-canary"))
+	_, err = os.Stat(filepath.Join(dir, "api-canary.yml"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestTraefikCertificatesConfig(t *testing.T) {
+	config := TraefikCertificatesConfig([]string{"wildcard"})
+	assert.Equal(t, []TLSCertificate{{
+		CertFile: "/etc/krane/proxy/certs/wildcard.crt",
+		KeyFile:  "/etc/krane/proxy/certs/wildcard.key",
+	}}, config.TLS.Certificates)
+	assert.Empty(t, config.HTTP.Routers)
+}
+
+func TestWriteAndRemoveCertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "krane-proxy")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	_ = os.Setenv(constants.EnvProxyConfigDir, dir)
+	defer os.Unsetenv(constants.EnvProxyConfigDir)
+
+	assert.Nil(t, WriteCertificate("wildcard", []byte("cert"), []byte("key")))
+
+	info, err := os.Stat(filepath.Join(dir, "certs", "wildcard.key"))
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	assert.Nil(t, RemoveCertificate("wildcard"))
+	assert.Nil(t, RemoveCertificate("wildcard"))
+
+	_, err = os.Stat(filepath.Join(dir, "certs", "wildcard.crt"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,154 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/krane/krane/internal/constants"
+)
+
+// MaintenanceResponderName is the name of the deployment answering requests for deployments in maintenance
+const MaintenanceResponderName = "krane-maintenance"
+
+// MaintenanceResponderPort is the container port the maintenance responder listens on
+const MaintenanceResponderPort = "80"
+
+// MaintenanceConfigName is the name of the dynamic configuration routing deployments in maintenance to the responder
+const MaintenanceConfigName = "maintenance"
+
+// maintenanceDirectory is the directory, relative to the proxy config directory, maintenance pages and
+// the Caddyfile of the maintenance responder are written to
+const maintenanceDirectory = "maintenance"
+
+// MaintenancePage is the page served for a deployment in maintenance
+type MaintenancePage struct {
+	Deployment string
+	HTML       string
+	RetryAfter int64 // seconds clients are asked to wait before retrying (Retry-After header)
+}
+
+// maintenancePath returns the path the maintenance responder serves the page of a deployment at
+func maintenancePath(deployment string) string {
+	return fmt.Sprintf("/%s", deployment)
+}
+
+// MaintenanceResponderCaddyfilePath returns the path of the Caddyfile loaded by the maintenance responder
+func MaintenanceResponderCaddyfilePath() string {
+	return filepath.Join(FileProviderDirectory, maintenanceDirectory, CaddyfileName)
+}
+
+// MaintenanceResponderCaddyfile returns the Caddyfile of the maintenance responder, answering the path of each
+// deployment in maintenance with its page, a 503 status and a Retry-After header
+func MaintenanceResponderCaddyfile(pages []MaintenancePage) string {
+	sort.Slice(pages, func(i, j int) bool { return pages[i].Deployment < pages[j].Deployment })
+
+	var caddyfile bytes.Buffer
+	caddyfile.WriteString("# Generated by Krane, changes to this file are overwritten\n")
+	caddyfile.WriteString(fmt.Sprintf(":%s {\n", MaintenanceResponderPort))
+	caddyfile.WriteString(fmt.Sprintf("\troot * %s\n", filepath.Join(FileProviderDirectory, maintenanceDirectory)))
+
+	for _, page := range pages {
+		caddyfile.WriteString(fmt.Sprintf("\n\thandle %s {\n", maintenancePath(page.Deployment)))
+		if page.RetryAfter > 0 {
+			caddyfile.WriteString(fmt.Sprintf("\t\theader Retry-After %d\n", page.RetryAfter))
+		}
+		caddyfile.WriteString("\t\theader Cache-Control no-store\n")
+		caddyfile.WriteString(fmt.Sprintf("\t\trewrite * /%s.html\n", page.Deployment))
+		caddyfile.WriteString("\t\tfile_server {\n\t\t\tstatus 503\n\t\t}\n")
+		caddyfile.WriteString("\t}\n")
+	}
+
+	caddyfile.WriteString("\n\thandle {\n\t\trespond 404\n\t}\n}\n")
+	return caddyfile.String()
+}
+
+// WriteMaintenancePages writes the maintenance pages and the Caddyfile of the maintenance responder,
+// pages of deployments no longer in maintenance are removed
+func WriteMaintenancePages(pages []MaintenancePage) error {
+	dir := os.Getenv(constants.EnvProxyConfigDir)
+	if dir == "" {
+		if len(pages) == 0 {
+			return nil
+		}
+		return errors.New("proxy config directory not set")
+	}
+
+	maintenanceDir := filepath.Join(dir, maintenanceDirectory)
+	if err := os.MkdirAll(maintenanceDir, 0755); err != nil {
+		return err
+	}
+
+	keep := make(map[string]bool, 0)
+	for _, page := range pages {
+		name := fmt.Sprintf("%s.html", page.Deployment)
+		if err := writeFile(filepath.Join(maintenanceDir, name), []byte(page.HTML), 0644); err != nil {
+			return err
+		}
+		keep[name] = true
+	}
+
+	files, err := ioutil.ReadDir(maintenanceDir)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if strings.HasSuffix(f.Name(), ".html") && !keep[f.Name()] {
+			if err := os.Remove(filepath.Join(maintenanceDir, f.Name())); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	return writeFile(filepath.Join(maintenanceDir, CaddyfileName), []byte(MaintenanceResponderCaddyfile(pages)), 0644)
+}
+
+// TraefikMaintenanceConfig returns a dynamic configuration routing the requests of sites in maintenance to the
+// maintenance responder. The routers of each site are mirrored with a higher priority than canary and blue/green
+// routers, they don't use the middlewares declared in Docker labels since the containers may be stopped.
+func TraefikMaintenanceConfig(sites []Site) DynamicConfig {
+	routers := make(map[string]Router, 0)
+	middlewares := make(map[string]Middleware, 0)
+
+	for _, site := range sites {
+		if !site.Maintenance {
+			continue
+		}
+
+		replacePath := fmt.Sprintf("%s-maintenance", site.Deployment)
+		redirect := fmt.Sprintf("%s-maintenance-redirect", site.Deployment)
+		middlewares[replacePath] = Middleware{ReplacePath: &ReplacePath{Path: maintenancePath(site.Deployment)}}
+
+		for name, router := range routersFromLabels(Traefik{}.Labels(site)) {
+			router.Priority += 3
+			router.Service = fmt.Sprintf("%s@docker", MaintenanceResponderName)
+			router.Middlewares = []string{replacePath}
+
+			// insecure routers of secure sites keep redirecting to HTTPS
+			if site.TLS.Enabled && router.TLS == nil {
+				middlewares[redirect] = Middleware{RedirectScheme: &RedirectScheme{Scheme: "https", Port: "443", Permanent: true}}
+				router.Middlewares = []string{redirect}
+			}
+
+			routers[fmt.Sprintf("%s-maintenance", name)] = router
+		}
+	}
+
+	return DynamicConfig{HTTP: HTTPConfig{Routers: routers, Middlewares: middlewares}}
+}
+
+// writeTraefikMaintenanceConfig writes the dynamic configuration routing sites in maintenance to the
+// maintenance responder, removed when no site is in maintenance
+func writeTraefikMaintenanceConfig(sites []Site) error {
+	config := TraefikMaintenanceConfig(sites)
+	if len(config.HTTP.Routers) == 0 {
+		return RemoveDynamicConfig(MaintenanceConfigName)
+	}
+	return WriteDynamicConfig(MaintenanceConfigName, config)
+}
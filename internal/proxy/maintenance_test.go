@@ -0,0 +1,143 @@
+package proxy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+
+	"github.com/krane/krane/internal/constants"
+)
+
+func TestMaintenanceResponderCaddyfile(t *testing.T) {
+	caddyfile := MaintenanceResponderCaddyfile([]MaintenancePage{
+		{Deployment: "web", RetryAfter: 0},
+		{Deployment: "api", RetryAfter: 600},
+	})
+
+	assert.Equal(t, `# Generated by Krane, changes to this file are overwritten
+:80 {
+	root * /etc/krane/proxy/maintenance
+
+	handle /api {
+		header Retry-After 600
+		header Cache-Control no-store
+		rewrite * /api.html
+		file_server {
+			status 503
+		}
+	}
+
+	handle /web {
+		header Cache-Control no-store
+		rewrite * /web.html
+		file_server {
+			status 503
+		}
+	}
+
+	handle {
+		respond 404
+	}
+}
+`, caddyfile)
+}
+
+func TestWriteMaintenancePages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "krane-proxy")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	_ = os.Setenv(constants.EnvProxyConfigDir, dir)
+	defer os.Unsetenv(constants.EnvProxyConfigDir)
+
+	assert.Nil(t, WriteMaintenancePages([]MaintenancePage{{Deployment: "api", HTML: "<h1>Back soon</h1>", RetryAfter: 60}}))
+	page, err := ioutil.ReadFile(filepath.Join(dir, "maintenance", "api.html"))
+	assert.Nil(t, err)
+	assert.Equal(t, "<h1>Back soon</h1>", string(page))
+
+	caddyfile, err := ioutil.ReadFile(filepath.Join(dir, "maintenance", CaddyfileName))
+	assert.Nil(t, err)
+	assert.Contains(t, string(caddyfile), "handle /api {")
+
+	// pages of deployments no longer in maintenance are removed
+	assert.Nil(t, WriteMaintenancePages([]MaintenancePage{}))
+	_, err = os.Stat(filepath.Join(dir, "maintenance", "api.html"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestTraefikMaintenanceConfig(t *testing.T) {
+	sites := []Site{
+		{
+			Deployment:  "api",
+			Aliases:     []string{"api.example.com"},
+			Routes:      []Route{{Host: "example.com", PathPrefix: "/api"}},
+			TLS:         TLS{Enabled: true, CertResolver: LetsEncryptResolver},
+			Upstream:    Upstream{Host: "api", TargetPort: "8080"},
+			Maintenance: true,
+		},
+		{
+			Deployment: "web",
+			Aliases:    []string{"example.com"},
+			Upstream:   Upstream{Host: "web", TargetPort: "3000"},
+		},
+	}
+
+	config := TraefikMaintenanceConfig(sites)
+	assert.Len(t, config.HTTP.Routers, 4)
+
+	secure := config.HTTP.Routers["api-secure-maintenance"]
+	assert.Equal(t, "Host(`api.example.com`)", secure.Rule)
+	assert.Equal(t, len("Host(`api.example.com`)")+3, secure.Priority)
+	assert.Equal(t, "krane-maintenance@docker", secure.Service)
+	assert.Equal(t, []string{"api-maintenance"}, secure.Middlewares)
+	assert.Equal(t, &RouterTLS{CertResolver: "lets-encrypt"}, secure.TLS)
+
+	insecure := config.HTTP.Routers["api-route-0-insecure-maintenance"]
+	assert.Equal(t, 1000+len("/api")+3, insecure.Priority)
+	assert.Equal(t, []string{"api-maintenance-redirect"}, insecure.Middlewares)
+
+	assert.Equal(t, "/api", config.HTTP.Middlewares["api-maintenance"].ReplacePath.Path)
+	assert.Equal(t, "https", config.HTTP.Middlewares["api-maintenance-redirect"].RedirectScheme.Scheme)
+}
+
+func TestTraefikWriteConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "krane-proxy")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	_ = os.Setenv(constants.EnvProxyConfigDir, dir)
+	defer os.Unsetenv(constants.EnvProxyConfigDir)
+
+	site := Site{Deployment: "api", Aliases: []string{"api.example.com"}, Upstream: Upstream{Host: "api", TargetPort: "8080"}, Maintenance: true}
+	assert.Nil(t, Traefik{}.WriteConfig([]Site{site}))
+
+	bytes, err := ioutil.ReadFile(filepath.Join(dir, "maintenance.yml"))
+	assert.Nil(t, err)
+
+	var config DynamicConfig
+	assert.Nil(t, yaml.Unmarshal(bytes, &config))
+	assert.Equal(t, "krane-maintenance@docker", config.HTTP.Routers["api-insecure-maintenance"].Service)
+
+	site.Maintenance = false
+	assert.Nil(t, Traefik{}.WriteConfig([]Site{site}))
+	_, err = os.Stat(filepath.Join(dir, "maintenance.yml"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCaddyfileMaintenance(t *testing.T) {
+	site := Site{
+		Deployment:  "api",
+		Aliases:     []string{"api.example.com"},
+		Middlewares: Middlewares{Compress: true},
+		Upstream:    Upstream{Host: "api", TargetPort: "8080"},
+		Maintenance: true,
+	}
+
+	caddyfile := Caddyfile([]Site{site}, "")
+	assert.Contains(t, caddyfile, "\thandle {\n\t\trewrite * /api\n\t\treverse_proxy krane-maintenance:80\n\t}\n")
+	assert.NotContains(t, caddyfile, "encode")
+}
@@ -0,0 +1,93 @@
+package middlewares
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// HSTS represents the Strict-Transport-Security header sent with responses
+type HSTS struct {
+	MaxAge            int64
+	IncludeSubdomains bool
+	Preload           bool
+}
+
+// CORS represents the cross-origin resource sharing policy of a deployment
+type CORS struct {
+	AllowOrigins     []string
+	AllowMethods     []string
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	MaxAge           int64
+}
+
+// HeadersMiddleware returns the name of the headers middleware of a deployment
+func HeadersMiddleware(deployment string) string {
+	return fmt.Sprintf("%s-headers", deployment)
+}
+
+// CustomHeadersLabels returns the labels setting custom request and response headers, an empty value removes the header
+func CustomHeadersLabels(deployment string, request map[string]string, response map[string]string) map[string]string {
+	labels := make(map[string]string, 0)
+	middleware := HeadersMiddleware(deployment)
+
+	for header, value := range request {
+		labels[fmt.Sprintf("traefik.http.middlewares.%s.headers.customrequestheaders.%s", middleware, header)] = value
+	}
+
+	for header, value := range response {
+		labels[fmt.Sprintf("traefik.http.middlewares.%s.headers.customresponseheaders.%s", middleware, header)] = value
+	}
+
+	return labels
+}
+
+// HSTSLabels returns the labels adding the Strict-Transport-Security header to responses
+func HSTSLabels(deployment string, hsts HSTS) map[string]string {
+	labels := make(map[string]string, 0)
+	middleware := HeadersMiddleware(deployment)
+
+	labels[fmt.Sprintf("traefik.http.middlewares.%s.headers.stsseconds", middleware)] = strconv.FormatInt(hsts.MaxAge, 10)
+	labels[fmt.Sprintf("traefik.http.middlewares.%s.headers.stsincludesubdomains", middleware)] = strconv.FormatBool(hsts.IncludeSubdomains)
+	labels[fmt.Sprintf("traefik.http.middlewares.%s.headers.stspreload", middleware)] = strconv.FormatBool(hsts.Preload)
+	labels[fmt.Sprintf("traefik.http.middlewares.%s.headers.forcestsheader", middleware)] = "true"
+
+	return labels
+}
+
+// CORSLabels returns the labels answering preflight requests and adding access control headers to responses
+func CORSLabels(deployment string, cors CORS) map[string]string {
+	labels := make(map[string]string, 0)
+	middleware := HeadersMiddleware(deployment)
+
+	labels[fmt.Sprintf("traefik.http.middlewares.%s.headers.accesscontrolalloworiginlist", middleware)] = strings.Join(cors.AllowOrigins, ",")
+	labels[fmt.Sprintf("traefik.http.middlewares.%s.headers.accesscontrolallowcredentials", middleware)] = strconv.FormatBool(cors.AllowCredentials)
+	labels[fmt.Sprintf("traefik.http.middlewares.%s.headers.addvaryheader", middleware)] = "true"
+
+	if len(cors.AllowMethods) > 0 {
+		labels[fmt.Sprintf("traefik.http.middlewares.%s.headers.accesscontrolallowmethods", middleware)] = strings.Join(cors.AllowMethods, ",")
+	}
+
+	if len(cors.AllowHeaders) > 0 {
+		labels[fmt.Sprintf("traefik.http.middlewares.%s.headers.accesscontrolallowheaders", middleware)] = strings.Join(cors.AllowHeaders, ",")
+	}
+
+	if len(cors.ExposeHeaders) > 0 {
+		labels[fmt.Sprintf("traefik.http.middlewares.%s.headers.accesscontrolexposeheaders", middleware)] = strings.Join(cors.ExposeHeaders, ",")
+	}
+
+	if cors.MaxAge > 0 {
+		labels[fmt.Sprintf("traefik.http.middlewares.%s.headers.accesscontrolmaxage", middleware)] = strconv.FormatInt(cors.MaxAge, 10)
+	}
+
+	return labels
+}
+
+// CompressLabels returns the labels of a middleware compressing responses
+func CompressLabels(deployment string) map[string]string {
+	labels := make(map[string]string, 0)
+	labels[fmt.Sprintf("traefik.http.middlewares.%s-compress.compress", deployment)] = "true"
+	return labels
+}
@@ -0,0 +1,34 @@
+package middlewares
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomHeadersLabels(t *testing.T) {
+	labels := CustomHeadersLabels("api", map[string]string{"X-Request-Source": "krane"}, map[string]string{"X-Frame-Options": "DENY", "Server": ""})
+	assert.Equal(t, "krane", labels["traefik.http.middlewares.api-headers.headers.customrequestheaders.X-Request-Source"])
+	assert.Equal(t, "DENY", labels["traefik.http.middlewares.api-headers.headers.customresponseheaders.X-Frame-Options"])
+	assert.Equal(t, "", labels["traefik.http.middlewares.api-headers.headers.customresponseheaders.Server"])
+}
+
+func TestHSTSLabels(t *testing.T) {
+	labels := HSTSLabels("api", HSTS{MaxAge: 31536000, IncludeSubdomains: true})
+	assert.Equal(t, "31536000", labels["traefik.http.middlewares.api-headers.headers.stsseconds"])
+	assert.Equal(t, "true", labels["traefik.http.middlewares.api-headers.headers.stsincludesubdomains"])
+	assert.Equal(t, "false", labels["traefik.http.middlewares.api-headers.headers.stspreload"])
+}
+
+func TestCORSLabels(t *testing.T) {
+	labels := CORSLabels("api", CORS{
+		AllowOrigins: []string{"https://example.com", "https://admin.example.com"},
+		AllowMethods: []string{"GET", "POST"},
+		MaxAge:       600,
+	})
+	assert.Equal(t, "https://example.com,https://admin.example.com", labels["traefik.http.middlewares.api-headers.headers.accesscontrolalloworiginlist"])
+	assert.Equal(t, "GET,POST", labels["traefik.http.middlewares.api-headers.headers.accesscontrolallowmethods"])
+	assert.Equal(t, "600", labels["traefik.http.middlewares.api-headers.headers.accesscontrolmaxage"])
+	assert.Equal(t, "false", labels["traefik.http.middlewares.api-headers.headers.accesscontrolallowcredentials"])
+	assert.NotContains(t, labels, "traefik.http.middlewares.api-headers.headers.accesscontrolallowheaders")
+}
@@ -3,6 +3,7 @@ package middlewares
 import (
 	"fmt"
 	"strconv"
+	"strings"
 )
 
 func RedirectToHTTPSLabels(deployment string) map[string]string {
@@ -21,3 +22,32 @@ func RateLimitLabels(deployment string, rateLimit uint) map[string]string {
 	labels[fmt.Sprintf("traefik.http.middlewares.%s-ratelimit.ratelimit.average", deployment)] = strconv.FormatUint(uint64(rateLimit), 10)
 	return labels
 }
+
+// DenyAllUser is a basic auth user whose password hash never matches, a basic auth middleware with
+// only this user denies every request
+const DenyAllUser = "krane:!"
+
+// BasicAuthLabels returns the labels of a middleware requiring basic auth, users are formatted as user:bcrypt-hash
+func BasicAuthLabels(deployment string, users []string, realm string) map[string]string {
+	labels := make(map[string]string, 0)
+	labels[fmt.Sprintf("traefik.http.middlewares.%s-basicauth.basicauth.users", deployment)] = strings.Join(users, ",")
+	labels[fmt.Sprintf("traefik.http.middlewares.%s-basicauth.basicauth.removeheader", deployment)] = "true"
+	if realm != "" {
+		labels[fmt.Sprintf("traefik.http.middlewares.%s-basicauth.basicauth.realm", deployment)] = realm
+	}
+	return labels
+}
+
+// IPAllowListLabels returns the labels of a middleware only allowing requests from IPs or CIDR ranges
+func IPAllowListLabels(deployment string, sourceRange []string) map[string]string {
+	labels := make(map[string]string, 0)
+	labels[fmt.Sprintf("traefik.http.middlewares.%s-ipallowlist.ipwhitelist.sourcerange", deployment)] = strings.Join(sourceRange, ",")
+	return labels
+}
+
+// StripPrefixLabels returns the labels of a middleware named name removing the path prefixes from requests
+func StripPrefixLabels(name string, prefixes []string) map[string]string {
+	labels := make(map[string]string, 0)
+	labels[fmt.Sprintf("traefik.http.middlewares.%s.stripprefix.prefixes", name)] = strings.Join(prefixes, ",")
+	return labels
+}
@@ -3,17 +3,112 @@ package proxy
 import (
 	"bytes"
 	"fmt"
+	"strconv"
 	"strings"
 
+	"github.com/krane/krane/internal/docker"
 	"github.com/krane/krane/internal/proxy/middlewares"
 )
 
+// Traefik is the proxy backend configuring Traefik with Docker labels, Traefik discovers the
+// containers of deployments from their labels. Configuration which can't be expressed with
+// labels (weighted services, uploaded certificates) is loaded by the Traefik file provider.
+type Traefik struct{}
+
+// Name returns the name of the Traefik backend
+func (Traefik) Name() string {
+	return TraefikBackendName
+}
+
+// Supports returns true, Traefik configures every feature
+func (Traefik) Supports(feature Feature) bool {
+	return true
+}
+
+// Labels returns the router, middleware and service labels of a site
+func (Traefik) Labels(site Site) map[string]string {
+	name := site.Deployment
+	labels := map[string]string{
+		"traefik.enable":         "true",
+		"traefik.docker.network": docker.KraneNetworkName,
+	}
+
+	// router labels
+	for k, v := range TraefikRouterLabels(name, site.Aliases, site.TLS.Enabled, site.TLS.CertResolver) {
+		labels[k] = v
+	}
+
+	for k, v := range TraefikRouteLabels(name, site.Routes, site.TLS.Enabled, site.TLS.CertResolver) {
+		labels[k] = v
+	}
+
+	for k, v := range TraefikTCPLabels(name, site.TCP, site.TLS.CertResolver) {
+		labels[k] = v
+	}
+
+	for k, v := range TraefikUDPLabels(name, site.UDP) {
+		labels[k] = v
+	}
+
+	// middleware labels
+	for k, v := range TraefikMiddlewareLabels(name, site.TLS.Enabled, site.Middlewares, site.Routes) {
+		labels[k] = v
+	}
+
+	// service labels
+	for k, v := range TraefikServiceLabels(name, site.Upstream.Ports, site.Upstream.TargetPort, site.Upstream.LoadBalancer) {
+		labels[k] = v
+	}
+
+	return labels
+}
+
+// WriteConfig writes the dynamic configuration routing sites in maintenance to the maintenance
+// responder, other sites are configured with labels
+func (Traefik) WriteConfig(sites []Site) error {
+	return writeTraefikMaintenanceConfig(sites)
+}
+
 type TraefikLabel struct {
 	Key   string
 	Value string
 }
 
-func TraefikRouterLabels(deployment string, aliases []string, secure bool) map[string]string {
+// LetsEncryptResolver is the certificate resolver requesting certificates from Let's Encrypt
+const LetsEncryptResolver = "lets-encrypt"
+
+// routePriorityBase is added to the priority of path based routers so they take
+// precedence over alias routers, which default to the length of their rule
+const routePriorityBase = 1000
+
+// Route is a host and path prefix routed to a deployment
+type Route struct {
+	Host        string
+	PathPrefix  string
+	StripPrefix bool
+}
+
+// rule returns the Traefik rule matching the route
+func (route Route) rule() string {
+	if route.PathPrefix == "" || route.PathPrefix == "/" {
+		return fmt.Sprintf("Host(`%s`)", route.Host)
+	}
+	return fmt.Sprintf("Host(`%s`) && PathPrefix(`%s`)", route.Host, route.PathPrefix)
+}
+
+// priority returns the router priority for the route, longer path prefixes are matched first
+func (route Route) priority() int {
+	return routePriorityBase + len(route.PathPrefix)
+}
+
+// routeRouterName returns the name of the router for the route at index i of a deployment
+func routeRouterName(deployment string, i int) string {
+	return fmt.Sprintf("%s-route-%d", deployment, i)
+}
+
+// TraefikRouterLabels returns the router labels for the aliases of a deployment. Secure routers request their
+// certificate with a cert resolver, without a resolver the proxy serves a matching uploaded certificate.
+func TraefikRouterLabels(deployment string, aliases []string, secure bool, certResolver string) map[string]string {
 	// configure aliases as Host('my-alias.example.com') labels
 	var hostRules bytes.Buffer
 	for i, alias := range aliases {
@@ -42,7 +137,9 @@ func TraefikRouterLabels(deployment string, aliases []string, secure bool) map[s
 		// https
 		labels[fmt.Sprintf("traefik.http.routers.%s-secure.tls", deployment)] = "true"
 		labels[fmt.Sprintf("traefik.http.routers.%s-secure.entrypoints", deployment)] = "web-secure"
-		labels[fmt.Sprintf("traefik.http.routers.%s-secure.tls.certresolver", deployment)] = "lets-encrypt"
+		if certResolver != "" {
+			labels[fmt.Sprintf("traefik.http.routers.%s-secure.tls.certresolver", deployment)] = certResolver
+		}
 		if hostRules.String() != "" {
 			labels[fmt.Sprintf("traefik.http.routers.%s-secure.rule", deployment)] = hostRules.String()
 		}
@@ -51,23 +148,215 @@ func TraefikRouterLabels(deployment string, aliases []string, secure bool) map[s
 	return labels
 }
 
-func TraefikServiceLabels(deployment string, ports map[string]string, targetPort string) map[string]string {
+// TraefikRouteLabels returns the router labels for the path based routes of a deployment
+func TraefikRouteLabels(deployment string, routes []Route, secure bool, certResolver string) map[string]string {
+	labels := make(map[string]string, 0)
+
+	for i, route := range routes {
+		router := routeRouterName(deployment, i)
+		priority := strconv.Itoa(route.priority())
+
+		// http
+		labels[fmt.Sprintf("traefik.http.routers.%s-insecure.rule", router)] = route.rule()
+		labels[fmt.Sprintf("traefik.http.routers.%s-insecure.priority", router)] = priority
+		labels[fmt.Sprintf("traefik.http.routers.%s-insecure.entrypoints", router)] = "web"
+
+		if secure {
+			// https
+			labels[fmt.Sprintf("traefik.http.routers.%s-secure.rule", router)] = route.rule()
+			labels[fmt.Sprintf("traefik.http.routers.%s-secure.priority", router)] = priority
+			labels[fmt.Sprintf("traefik.http.routers.%s-secure.tls", router)] = "true"
+			labels[fmt.Sprintf("traefik.http.routers.%s-secure.entrypoints", router)] = "web-secure"
+			if certResolver != "" {
+				labels[fmt.Sprintf("traefik.http.routers.%s-secure.tls.certresolver", router)] = certResolver
+			}
+		}
+	}
+
+	return labels
+}
+
+// TraefikServiceLabels returns the service labels of a deployment with its load balancing options
+func TraefikServiceLabels(deployment string, ports []string, targetPort string, options LoadBalancer) map[string]string {
 	labels := make(map[string]string, 0)
 
+	services := make(map[string]string, 0)
 	if targetPort != "" {
-		labels[fmt.Sprintf("traefik.http.services.%s.loadbalancer.server.port", deployment)] = targetPort
-		labels[fmt.Sprintf("traefik.http.services.%s.loadbalancer.server.scheme", deployment)] = "http"
+		services[deployment] = targetPort
 	} else {
 		for _, containerPort := range ports {
-			labels[fmt.Sprintf("traefik.http.services.%s-%s.loadbalancer.server.port", deployment, containerPort)] = containerPort
-			labels[fmt.Sprintf("traefik.http.services.%s-%s.loadbalancer.server.scheme", deployment, containerPort)] = "http"
+			services[fmt.Sprintf("%s-%s", deployment, containerPort)] = containerPort
+		}
+	}
+
+	for service, port := range services {
+		labels[fmt.Sprintf("traefik.http.services.%s.loadbalancer.server.port", service)] = port
+		labels[fmt.Sprintf("traefik.http.services.%s.loadbalancer.server.scheme", service)] = "http"
+
+		for k, v := range options.labels(service) {
+			labels[k] = v
 		}
 	}
 
 	return labels
 }
 
-func TraefikMiddlewareLabels(deployment string, secured bool, rateLimit uint) map[string]string {
+// LoadBalancer are the optional load balancing options of the services of a deployment
+type LoadBalancer struct {
+	Sticky         *StickyCookie // pin clients to a container with a cookie
+	HealthCheck    *HealthCheck  // stop sending requests to containers failing the check
+	PassHostHeader *bool         // forward the client Host header to the containers (Traefik default true)
+}
+
+// StickyCookie represents the cookie pinning clients to a container
+type StickyCookie struct {
+	Name     string
+	Secure   bool
+	HTTPOnly bool
+	SameSite string
+}
+
+// HealthCheck represents the requests the proxy sends to containers to check they can receive traffic
+type HealthCheck struct {
+	Path     string
+	Interval string
+	Timeout  string
+}
+
+// labels returns the load balancing labels of a service
+func (lb LoadBalancer) labels(service string) map[string]string {
+	labels := make(map[string]string, 0)
+	prefix := fmt.Sprintf("traefik.http.services.%s.loadbalancer", service)
+
+	if lb.Sticky != nil {
+		labels[prefix+".sticky.cookie"] = "true"
+		labels[prefix+".sticky.cookie.secure"] = strconv.FormatBool(lb.Sticky.Secure)
+		labels[prefix+".sticky.cookie.httponly"] = strconv.FormatBool(lb.Sticky.HTTPOnly)
+
+		if lb.Sticky.Name != "" {
+			labels[prefix+".sticky.cookie.name"] = lb.Sticky.Name
+		}
+
+		if lb.Sticky.SameSite != "" {
+			labels[prefix+".sticky.cookie.samesite"] = lb.Sticky.SameSite
+		}
+	}
+
+	if lb.HealthCheck != nil {
+		labels[prefix+".healthcheck.path"] = lb.HealthCheck.Path
+		labels[prefix+".healthcheck.interval"] = lb.HealthCheck.Interval
+		labels[prefix+".healthcheck.timeout"] = lb.HealthCheck.Timeout
+	}
+
+	if lb.PassHostHeader != nil {
+		labels[prefix+".passhostheader"] = strconv.FormatBool(*lb.PassHostHeader)
+	}
+
+	return labels
+}
+
+// TLS modes of TCP routes
+const (
+	TCPTLSNone        = ""            // connections are forwarded as is, any host is matched
+	TCPTLSTerminate   = "terminate"   // the proxy terminates TLS and forwards decrypted connections
+	TCPTLSPassthrough = "passthrough" // encrypted connections are forwarded, the containers terminate TLS
+)
+
+// TCPRoute is a proxy entrypoint forwarding raw TCP connections to a container port
+type TCPRoute struct {
+	EntryPoint string
+	Port       string
+	Hosts      []string // server names (SNI) matched, only available with TLS
+	TLS        string
+}
+
+// rule returns the Traefik rule matching the route, connections without TLS can only match any host
+func (route TCPRoute) rule() string {
+	if len(route.Hosts) == 0 || route.TLS == TCPTLSNone {
+		return "HostSNI(`*`)"
+	}
+
+	hosts := make([]string, 0)
+	for _, host := range route.Hosts {
+		hosts = append(hosts, fmt.Sprintf("`%s`", host))
+	}
+	return fmt.Sprintf("HostSNI(%s)", strings.Join(hosts, ","))
+}
+
+// UDPRoute is a proxy entrypoint forwarding UDP datagrams to a container port
+type UDPRoute struct {
+	EntryPoint string
+	Port       string
+}
+
+// TraefikTCPLabels returns the tcp router and service labels for the TCP routes of a deployment
+func TraefikTCPLabels(deployment string, routes []TCPRoute, certResolver string) map[string]string {
+	labels := make(map[string]string, 0)
+
+	for i, route := range routes {
+		name := fmt.Sprintf("%s-tcp-%d", deployment, i)
+
+		labels[fmt.Sprintf("traefik.tcp.routers.%s.rule", name)] = route.rule()
+		labels[fmt.Sprintf("traefik.tcp.routers.%s.entrypoints", name)] = route.EntryPoint
+		labels[fmt.Sprintf("traefik.tcp.routers.%s.service", name)] = name
+		labels[fmt.Sprintf("traefik.tcp.services.%s.loadbalancer.server.port", name)] = route.Port
+
+		switch route.TLS {
+		case TCPTLSTerminate:
+			labels[fmt.Sprintf("traefik.tcp.routers.%s.tls", name)] = "true"
+			if certResolver != "" {
+				labels[fmt.Sprintf("traefik.tcp.routers.%s.tls.certresolver", name)] = certResolver
+			}
+		case TCPTLSPassthrough:
+			labels[fmt.Sprintf("traefik.tcp.routers.%s.tls", name)] = "true"
+			labels[fmt.Sprintf("traefik.tcp.routers.%s.tls.passthrough", name)] = "true"
+		}
+	}
+
+	return labels
+}
+
+// TraefikUDPLabels returns the udp router and service labels for the UDP routes of a deployment
+func TraefikUDPLabels(deployment string, routes []UDPRoute) map[string]string {
+	labels := make(map[string]string, 0)
+
+	for i, route := range routes {
+		name := fmt.Sprintf("%s-udp-%d", deployment, i)
+
+		labels[fmt.Sprintf("traefik.udp.routers.%s.entrypoints", name)] = route.EntryPoint
+		labels[fmt.Sprintf("traefik.udp.routers.%s.service", name)] = name
+		labels[fmt.Sprintf("traefik.udp.services.%s.loadbalancer.server.port", name)] = route.Port
+	}
+
+	return labels
+}
+
+// Middlewares are the optional middlewares applied to the routers of a deployment
+type Middlewares struct {
+	RateLimit      uint     // requests per second (0 means no rate limit)
+	BasicAuth      bool     // basic auth is configured, every request is denied when no user resolves
+	BasicAuthUsers []string // users allowed through basic auth, formatted as user:bcrypt-hash
+	BasicAuthRealm string   // realm presented to clients by basic auth
+	IPAllowList    []string // IPs or CIDR ranges allowed to reach the deployment
+
+	RequestHeaders  map[string]string // headers set on requests before reaching the containers
+	ResponseHeaders map[string]string // headers set on responses sent to clients
+	HSTS            *middlewares.HSTS // Strict-Transport-Security header sent with responses
+	CORS            *middlewares.CORS // cross-origin resource sharing policy
+	Compress        bool              // compress responses
+}
+
+// hasHeaders returns true if the headers middleware is configured
+func (options Middlewares) hasHeaders() bool {
+	return len(options.RequestHeaders) > 0 || len(options.ResponseHeaders) > 0 || options.HSTS != nil || options.CORS != nil
+}
+
+// basicAuth returns true if requests must go through basic auth
+func (options Middlewares) basicAuth() bool {
+	return options.BasicAuth || len(options.BasicAuthUsers) > 0
+}
+
+func TraefikMiddlewareLabels(deployment string, secured bool, options Middlewares, routes []Route) map[string]string {
 	labels := make(map[string]string, 0)
 
 	allMiddlewares := make([]string, 0)
@@ -80,16 +369,90 @@ func TraefikMiddlewareLabels(deployment string, secured bool, rateLimit uint) ma
 		allMiddlewares = append(allMiddlewares, "redirect-to-https")
 	}
 
+	// ip allow list
+	if len(options.IPAllowList) > 0 {
+		for k, v := range middlewares.IPAllowListLabels(deployment, options.IPAllowList) {
+			labels[k] = v
+		}
+		allMiddlewares = append(allMiddlewares, fmt.Sprintf("%s-ipallowlist", deployment))
+	}
+
+	// headers, applied before basic auth so CORS preflight requests are answered without credentials
+	if options.hasHeaders() {
+		for k, v := range middlewares.CustomHeadersLabels(deployment, options.RequestHeaders, options.ResponseHeaders) {
+			labels[k] = v
+		}
+
+		if options.HSTS != nil {
+			for k, v := range middlewares.HSTSLabels(deployment, *options.HSTS) {
+				labels[k] = v
+			}
+		}
+
+		if options.CORS != nil {
+			for k, v := range middlewares.CORSLabels(deployment, *options.CORS) {
+				labels[k] = v
+			}
+		}
+		allMiddlewares = append(allMiddlewares, middlewares.HeadersMiddleware(deployment))
+	}
+
+	// basic auth, routes are never left without auth when it is configured
+	if options.basicAuth() {
+		users := options.BasicAuthUsers
+		if len(users) == 0 {
+			users = []string{middlewares.DenyAllUser}
+		}
+
+		for k, v := range middlewares.BasicAuthLabels(deployment, users, options.BasicAuthRealm) {
+			labels[k] = v
+		}
+		allMiddlewares = append(allMiddlewares, fmt.Sprintf("%s-basicauth", deployment))
+	}
+
 	// rate limit
-	for k, v := range middlewares.RateLimitLabels(deployment, rateLimit) {
+	for k, v := range middlewares.RateLimitLabels(deployment, options.RateLimit) {
 		labels[k] = v
 	}
 	allMiddlewares = append(allMiddlewares, fmt.Sprintf("%s-ratelimit", deployment))
 
+	// compression
+	if options.Compress {
+		for k, v := range middlewares.CompressLabels(deployment) {
+			labels[k] = v
+		}
+		allMiddlewares = append(allMiddlewares, fmt.Sprintf("%s-compress", deployment))
+	}
+
 	// attach all middlewares to the deployment
 	mw := strings.Join(allMiddlewares[:], ",")
 	labels[fmt.Sprintf("traefik.http.routers.%s-insecure.middlewares", deployment)] = mw
 	labels[fmt.Sprintf("traefik.http.routers.%s-secure.middlewares", deployment)] = strings.Replace(mw, "redirect-to-https,", " ", 1)
 
+	// attach all middlewares to the routers of path based routes, stripping
+	// the path prefix last so the other middlewares see the original request
+	for i, route := range routes {
+		router := routeRouterName(deployment, i)
+		insecure := allMiddlewares
+		secure := allMiddlewares
+		if secured {
+			secure = allMiddlewares[1:]
+		}
+
+		if route.StripPrefix && route.PathPrefix != "/" {
+			stripPrefix := fmt.Sprintf("%s-stripprefix", router)
+			for k, v := range middlewares.StripPrefixLabels(stripPrefix, []string{route.PathPrefix}) {
+				labels[k] = v
+			}
+			insecure = append(insecure[:len(insecure):len(insecure)], stripPrefix)
+			secure = append(secure[:len(secure):len(secure)], stripPrefix)
+		}
+
+		labels[fmt.Sprintf("traefik.http.routers.%s-insecure.middlewares", router)] = strings.Join(insecure, ",")
+		if secured {
+			labels[fmt.Sprintf("traefik.http.routers.%s-secure.middlewares", router)] = strings.Join(secure, ",")
+		}
+	}
+
 	return labels
 }
@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/krane/krane/internal/proxy/middlewares"
+)
+
+func TestTraefikRouteLabels(t *testing.T) {
+	routes := []Route{
+		{Host: "example.com", PathPrefix: "/"},
+		{Host: "example.com", PathPrefix: "/api/v1"},
+	}
+
+	labels := TraefikRouteLabels("api", routes, true, LetsEncryptResolver)
+	assert.Equal(t, "Host(`example.com`)", labels["traefik.http.routers.api-route-0-insecure.rule"])
+	assert.Equal(t, "1001", labels["traefik.http.routers.api-route-0-insecure.priority"])
+	assert.Equal(t, "Host(`example.com`) && PathPrefix(`/api/v1`)", labels["traefik.http.routers.api-route-1-secure.rule"])
+	assert.Equal(t, "1007", labels["traefik.http.routers.api-route-1-secure.priority"])
+	assert.Equal(t, "web-secure", labels["traefik.http.routers.api-route-1-secure.entrypoints"])
+	assert.Equal(t, "lets-encrypt", labels["traefik.http.routers.api-route-1-secure.tls.certresolver"])
+
+	labels = TraefikRouteLabels("api", routes, false, LetsEncryptResolver)
+	assert.NotContains(t, labels, "traefik.http.routers.api-route-0-secure.rule")
+}
+
+func TestTraefikMiddlewareLabelsForRoutes(t *testing.T) {
+	routes := []Route{
+		{Host: "example.com", PathPrefix: "/api", StripPrefix: true},
+		{Host: "example.com", PathPrefix: "/docs"},
+	}
+
+	labels := TraefikMiddlewareLabels("api", true, Middlewares{RateLimit: 10}, routes)
+	assert.Equal(t, "redirect-to-https,api-ratelimit,api-route-0-stripprefix", labels["traefik.http.routers.api-route-0-insecure.middlewares"])
+	assert.Equal(t, "api-ratelimit,api-route-0-stripprefix", labels["traefik.http.routers.api-route-0-secure.middlewares"])
+	assert.Equal(t, "/api", labels["traefik.http.middlewares.api-route-0-stripprefix.stripprefix.prefixes"])
+	assert.Equal(t, "api-ratelimit", labels["traefik.http.routers.api-route-1-secure.middlewares"])
+	assert.NotContains(t, labels, "traefik.http.middlewares.api-route-1-stripprefix.stripprefix.prefixes")
+}
+
+func TestTraefikAccessMiddlewareLabels(t *testing.T) {
+	options := Middlewares{
+		BasicAuthUsers: []string{"admin:$2a$10$hash"},
+		BasicAuthRealm: "staging",
+		IPAllowList:    []string{"10.0.0.0/8", "192.168.1.7"},
+	}
+
+	labels := TraefikMiddlewareLabels("api", true, options, []Route{})
+	assert.Equal(t, "redirect-to-https,api-ipallowlist,api-basicauth,api-ratelimit", labels["traefik.http.routers.api-insecure.middlewares"])
+	assert.Equal(t, "admin:$2a$10$hash", labels["traefik.http.middlewares.api-basicauth.basicauth.users"])
+	assert.Equal(t, "staging", labels["traefik.http.middlewares.api-basicauth.basicauth.realm"])
+	assert.Equal(t, "10.0.0.0/8,192.168.1.7", labels["traefik.http.middlewares.api-ipallowlist.ipwhitelist.sourcerange"])
+
+	labels = TraefikMiddlewareLabels("api", false, Middlewares{}, []Route{})
+	assert.Equal(t, "api-ratelimit", labels["traefik.http.routers.api-insecure.middlewares"])
+	assert.NotContains(t, labels, "traefik.http.middlewares.api-basicauth.basicauth.users")
+
+	// configured basic auth without resolved users denies every request
+	labels = TraefikMiddlewareLabels("api", false, Middlewares{BasicAuth: true}, []Route{})
+	assert.Equal(t, "api-basicauth,api-ratelimit", labels["traefik.http.routers.api-insecure.middlewares"])
+	assert.Equal(t, middlewares.DenyAllUser, labels["traefik.http.middlewares.api-basicauth.basicauth.users"])
+}
+
+func TestTraefikHeadersAndCompressMiddlewareLabels(t *testing.T) {
+	options := Middlewares{
+		BasicAuthUsers:  []string{"admin:$2a$10$hash"},
+		ResponseHeaders: map[string]string{"X-Frame-Options": "DENY"},
+		Compress:        true,
+	}
+
+	labels := TraefikMiddlewareLabels("api", false, options, []Route{})
+	assert.Equal(t, "api-headers,api-basicauth,api-ratelimit,api-compress", labels["traefik.http.routers.api-insecure.middlewares"])
+	assert.Equal(t, "true", labels["traefik.http.middlewares.api-compress.compress"])
+	assert.Equal(t, "DENY", labels["traefik.http.middlewares.api-headers.headers.customresponseheaders.X-Frame-Options"])
+}
+
+func TestTraefikServiceLabels(t *testing.T) {
+	labels := TraefikServiceLabels("api", []string{"8080", "9090"}, "", LoadBalancer{})
+	assert.Equal(t, "8080", labels["traefik.http.services.api-8080.loadbalancer.server.port"])
+	assert.Equal(t, "9090", labels["traefik.http.services.api-9090.loadbalancer.server.port"])
+	assert.Len(t, labels, 4)
+
+	options := LoadBalancer{HealthCheck: &HealthCheck{Path: "/health", Interval: "10s", Timeout: "3s"}}
+	labels = TraefikServiceLabels("api", []string{"8080"}, "8080", options)
+	assert.Equal(t, "8080", labels["traefik.http.services.api.loadbalancer.server.port"])
+	assert.Equal(t, "/health", labels["traefik.http.services.api.loadbalancer.healthcheck.path"])
+	assert.NotContains(t, labels, "traefik.http.services.api.loadbalancer.sticky.cookie")
+	assert.NotContains(t, labels, "traefik.http.services.api.loadbalancer.passhostheader")
+}
+
+func TestTraefikTCPLabels(t *testing.T) {
+	routes := []TCPRoute{
+		{EntryPoint: "postgres", Port: "5432"},
+		{EntryPoint: "postgres", Port: "5432", Hosts: []string{"db.example.com", "db.example.org"}, TLS: TCPTLSPassthrough},
+		{EntryPoint: "redis", Port: "6379", Hosts: []string{"cache.example.com"}, TLS: TCPTLSTerminate},
+	}
+
+	labels := TraefikTCPLabels("db", routes, LetsEncryptResolver)
+	assert.Equal(t, "HostSNI(`*`)", labels["traefik.tcp.routers.db-tcp-0.rule"])
+	assert.Equal(t, "postgres", labels["traefik.tcp.routers.db-tcp-0.entrypoints"])
+	assert.Equal(t, "db-tcp-0", labels["traefik.tcp.routers.db-tcp-0.service"])
+	assert.Equal(t, "5432", labels["traefik.tcp.services.db-tcp-0.loadbalancer.server.port"])
+	assert.NotContains(t, labels, "traefik.tcp.routers.db-tcp-0.tls")
+
+	assert.Equal(t, "HostSNI(`db.example.com`,`db.example.org`)", labels["traefik.tcp.routers.db-tcp-1.rule"])
+	assert.Equal(t, "true", labels["traefik.tcp.routers.db-tcp-1.tls.passthrough"])
+	assert.NotContains(t, labels, "traefik.tcp.routers.db-tcp-1.tls.certresolver")
+
+	assert.Equal(t, "true", labels["traefik.tcp.routers.db-tcp-2.tls"])
+	assert.Equal(t, "lets-encrypt", labels["traefik.tcp.routers.db-tcp-2.tls.certresolver"])
+}
+
+func TestTraefikUDPLabels(t *testing.T) {
+	labels := TraefikUDPLabels("dns", []UDPRoute{{EntryPoint: "dns", Port: "53"}})
+	assert.Equal(t, map[string]string{
+		"traefik.udp.routers.dns-udp-0.entrypoints":               "dns",
+		"traefik.udp.routers.dns-udp-0.service":                   "dns-udp-0",
+		"traefik.udp.services.dns-udp-0.loadbalancer.server.port": "53",
+	}, labels)
+}
@@ -0,0 +1,43 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/krane/krane/internal/deployment"
+	"github.com/krane/krane/internal/logger"
+)
+
+// colorExpirationInterval is how often the previous colors of blue/green deployments are checked for expiry
+const colorExpirationInterval = time.Minute
+
+// RunColorExpirations removes the previous color of blue/green deployments once it expires
+func (s *Scheduler) RunColorExpirations() {
+	logger.Debug("Starting blue/green color expirations")
+
+	for {
+		s.removeExpiredColors(time.Now())
+		<-time.After(colorExpirationInterval)
+	}
+}
+
+// removeExpiredColors queues the removal of every previous color kept around past its expiry for rollbacks
+func (s *Scheduler) removeExpiredColors(now time.Time) {
+	configs, err := deployment.GetAllDeploymentConfigs()
+	if err != nil {
+		logger.Error(errors.Wrap(err, "Unhandled error when removing expired colors"))
+		return
+	}
+
+	for _, config := range configs {
+		if !deployment.PreviousColorExpired(config.Name, now) {
+			continue
+		}
+
+		logger.Debugf("Removing previous color of deployment %s", config.Name)
+		if err := deployment.RemovePreviousColor(config.Name); err != nil {
+			logger.Error(errors.Wrapf(err, "unable to remove previous color of deployment %s", config.Name))
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+
+	// when both day of month and day of week are restricted, a day matching either field matches the schedule
+	domRestricted, dowRestricted bool
+}
+
+type bounds struct {
+	min, max uint
+	names    map[string]uint
+}
+
+var (
+	minutes = bounds{0, 59, nil}
+	hours   = bounds{0, 23, nil}
+	dom     = bounds{1, 31, nil}
+	months  = bounds{1, 12, map[string]uint{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dow = bounds{0, 7, map[string]uint{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses a standard 5 field cron expression (minute hour day-of-month month day-of-week)
+// ie. "30 2 * * 1-5". Fields support *, lists (1,15), ranges (1-5), steps (*/15, 0-30/10)
+// and month or weekday names (jan, mon). The descriptors @yearly, @monthly, @weekly, @daily and @hourly are also supported.
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if expanded, ok := descriptors[strings.ToLower(spec)]; ok {
+		spec = expanded
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return Schedule{}, fmt.Errorf("invalid cron expression %q, expected 5 fields but got %d", spec, len(fields))
+	}
+
+	var s Schedule
+	var err error
+
+	if s.minute, err = parseField(fields[0], minutes); err != nil {
+		return Schedule{}, fmt.Errorf("invalid minute field in cron expression %q, %v", spec, err)
+	}
+
+	if s.hour, err = parseField(fields[1], hours); err != nil {
+		return Schedule{}, fmt.Errorf("invalid hour field in cron expression %q, %v", spec, err)
+	}
+
+	if s.dom, err = parseField(fields[2], dom); err != nil {
+		return Schedule{}, fmt.Errorf("invalid day of month field in cron expression %q, %v", spec, err)
+	}
+
+	if s.month, err = parseField(fields[3], months); err != nil {
+		return Schedule{}, fmt.Errorf("invalid month field in cron expression %q, %v", spec, err)
+	}
+
+	if s.dow, err = parseField(fields[4], dow); err != nil {
+		return Schedule{}, fmt.Errorf("invalid day of week field in cron expression %q, %v", spec, err)
+	}
+
+	// 7 is an alias for sunday
+	if s.dow&(1<<7) != 0 {
+		s.dow = (s.dow | 1) &^ (1 << 7)
+	}
+
+	s.domRestricted = !strings.HasPrefix(fields[2], "*")
+	s.dowRestricted = !strings.HasPrefix(fields[4], "*")
+
+	return s, nil
+}
+
+// Next returns the first time after t matching the schedule, in the location of t.
+// The zero time is returned if the schedule does not match within the next 5 years (ie. 30 2 31 2 *).
+func (s Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+
+	// schedules have a minute precision, start from the next whole minute
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc).Add(time.Minute)
+	limit := t.Year() + 5
+
+	for t.Year() <= limit {
+		if !has(s.month, uint(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if !has(s.hour, uint(t.Hour())) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+
+		if !has(s.minute, uint(t.Minute())) {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+// dayMatches returns true if the day of t matches the day of month and day of week fields
+func (s Schedule) dayMatches(t time.Time) bool {
+	domMatch := has(s.dom, uint(t.Day()))
+	dowMatch := has(s.dow, uint(t.Weekday()))
+
+	if s.domRestricted && s.dowRestricted {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+// parseField parses a comma separated cron field into a bitset of matching values
+func parseField(field string, b bounds) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		partBits, err := parsePart(part, b)
+		if err != nil {
+			return 0, err
+		}
+		bits |= partBits
+	}
+	return bits, nil
+}
+
+// parsePart parses a single value, range or step of a cron field
+func parsePart(part string, b bounds) (uint64, error) {
+	rangeSpec, step := part, uint(1)
+	if i := strings.Index(part, "/"); i != -1 {
+		n, err := strconv.ParseUint(part[i+1:], 10, 8)
+		if err != nil || n == 0 {
+			return 0, fmt.Errorf("invalid step %q", part[i+1:])
+		}
+		rangeSpec, step = part[:i], uint(n)
+	}
+
+	var start, end uint
+	switch {
+	case rangeSpec == "*":
+		start, end = b.min, b.max
+	case strings.Contains(rangeSpec, "-"):
+		bounds := strings.SplitN(rangeSpec, "-", 2)
+		var err error
+		if start, err = parseValue(bounds[0], b); err != nil {
+			return 0, err
+		}
+		if end, err = parseValue(bounds[1], b); err != nil {
+			return 0, err
+		}
+	default:
+		value, err := parseValue(rangeSpec, b)
+		if err != nil {
+			return 0, err
+		}
+		start, end = value, value
+		if step > 1 {
+			// a value with a step (ie. 5/15) runs from the value to the end of the field
+			end = b.max
+		}
+	}
+
+	if start > end {
+		return 0, fmt.Errorf("invalid range %q", rangeSpec)
+	}
+
+	var bits uint64
+	for v := start; v <= end; v += step {
+		bits |= 1 << v
+	}
+	return bits, nil
+}
+
+// parseValue parses a numeric or named value of a cron field
+func parseValue(value string, b bounds) (uint, error) {
+	if n, ok := b.names[strings.ToLower(value)]; ok {
+		return n, nil
+	}
+
+	n, err := strconv.ParseUint(value, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", value)
+	}
+
+	if uint(n) < b.min || uint(n) > b.max {
+		return 0, fmt.Errorf("value %d out of range %d-%d", n, b.min, b.max)
+	}
+
+	return uint(n), nil
+}
+
+func has(bits uint64, v uint) bool {
+	return bits&(1<<v) != 0
+}
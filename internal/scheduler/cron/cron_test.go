@@ -0,0 +1,65 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func date(s string) time.Time {
+	t, _ := time.Parse("2006-01-02 15:04", s)
+	return t
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		spec string
+		from string
+		next string
+	}{
+		{"* * * * *", "2021-01-01 10:15", "2021-01-01 10:16"},
+		{"30 2 * * *", "2021-01-01 10:15", "2021-01-02 02:30"},
+		{"*/15 * * * *", "2021-01-01 10:16", "2021-01-01 10:30"},
+		{"0 9-17/4 * * *", "2021-01-01 10:00", "2021-01-01 13:00"},
+		{"0 0 1 * *", "2021-01-15 00:00", "2021-02-01 00:00"},
+		{"0 0 * * mon", "2021-01-01 00:00", "2021-01-04 00:00"},
+		{"0 0 * * 7", "2021-01-01 00:00", "2021-01-03 00:00"},
+		{"0 0 13 * fri", "2021-01-01 12:00", "2021-01-08 00:00"},
+		{"0 0 29 feb *", "2021-01-01 00:00", "2024-02-29 00:00"},
+		{"@hourly", "2021-12-31 23:30", "2022-01-01 00:00"},
+		{"@weekly", "2021-01-01 00:00", "2021-01-03 00:00"},
+		{"15,45 6 * jan-mar *", "2021-03-31 06:45", "2022-01-01 06:15"},
+	}
+
+	for _, tt := range tests {
+		s, err := Parse(tt.spec)
+		assert.Nil(t, err, tt.spec)
+		assert.Equal(t, date(tt.next), s.Next(date(tt.from)), tt.spec)
+	}
+}
+
+func TestNextNeverMatches(t *testing.T) {
+	s, err := Parse("0 0 31 feb *")
+	assert.Nil(t, err)
+	assert.True(t, s.Next(date("2021-01-01 00:00")).IsZero())
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * foo *",
+		"@every 5m",
+	} {
+		_, err := Parse(spec)
+		assert.Error(t, err, spec)
+	}
+}
@@ -38,6 +38,7 @@ func (s *Scheduler) Run() {
 // poll will on an interval get deployments and queue jobs if they are not
 // in a desired state. For example, if a deployment has a scale of 3 but only
 // 1 container is running, the scheduler schedules a new job to update the deployment state.
+// Deployments are visited after the deployments they depend on.
 func (s *Scheduler) poll() {
 	logger.Debug("Scheduler polling")
 
@@ -46,10 +47,30 @@ func (s *Scheduler) poll() {
 		logger.Error(errors.Wrap(err, "Unhandled error when polling"))
 	}
 
+	byName := make(map[string]deployment.Deployment, 0)
+	configs := make([]deployment.Config, 0)
 	for _, d := range deployments {
-		if hasDesiredState(d) {
+		byName[d.Config.Name] = d
+		configs = append(configs, d.Config)
+	}
+
+	for _, config := range deployment.SortByDependencies(configs) {
+		// deployments in maintenance are not expected to be in their desired state
+		if _, err := deployment.GetMaintenance(config.Name); err == nil {
+			logger.Debugf("Deployment %s is in maintenance, skipping", config.Name)
+			continue
+		}
+
+		if hasDesiredState(byName[config.Name]) {
 			continue
 		}
+
+		if err := deployment.CheckDependencies(config); err != nil {
+			logger.Debugf("Deployment %s is not in its desired state, waiting on dependencies, %v", config.Name, err)
+			continue
+		}
+
+		logger.Debugf("Deployment %s is not in its desired state", config.Name)
 	}
 
 	logger.Debugf("Next poll in %s", s.interval.String())
@@ -58,7 +79,7 @@ func (s *Scheduler) poll() {
 // hasDesiredState checks that deployments are in parity with their configurations
 func hasDesiredState(d deployment.Deployment) bool {
 	config := d.Config
-	containers := d.Containers
+	containers := deployment.ActiveContainers(config.Name, d.Containers)
 
 	if config.Scale != len(containers) {
 		return false
@@ -0,0 +1,130 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/krane/krane/internal/deployment"
+	"github.com/krane/krane/internal/logger"
+	"github.com/krane/krane/internal/scheduler/cron"
+)
+
+// missedRunGracePeriod is how late a run can fire before it is considered missed
+const missedRunGracePeriod = time.Minute
+
+// RunSchedules evaluates deployment schedules at the start of every minute
+func (s *Scheduler) RunSchedules() {
+	logger.Debug("Starting deployment schedules")
+
+	for {
+		s.evaluateSchedules(time.Now().UTC())
+
+		now := time.Now()
+		<-time.After(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+	}
+}
+
+// evaluateSchedules queues a task for every deployment schedule due since it last fired
+func (s *Scheduler) evaluateSchedules(now time.Time) {
+	configs, err := deployment.GetAllDeploymentConfigs()
+	if err != nil {
+		logger.Error(errors.Wrap(err, "Unhandled error when evaluating schedules"))
+		return
+	}
+
+	for _, config := range configs {
+		for _, schedule := range config.Schedules {
+			if err := s.evaluateSchedule(config, schedule, now); err != nil {
+				logger.Error(errors.Wrapf(err, "unable to evaluate schedule %s for deployment %s", schedule.Name, config.Name))
+			}
+		}
+	}
+
+	if err := deployment.RemoveStaleScheduleStates(configs); err != nil {
+		logger.Warnf("unable to remove stale schedule states, %v", err)
+	}
+}
+
+// evaluateSchedule queues a task for a schedule if a run is due, applying the schedule missed run and concurrency policies
+func (s *Scheduler) evaluateSchedule(config deployment.Config, schedule deployment.ScheduleConfig, now time.Time) error {
+	c, err := schedule.Cron()
+	if err != nil {
+		return err
+	}
+
+	state, err := deployment.GetScheduleState(config.Name, schedule.Name)
+	if err != nil {
+		return err
+	}
+
+	// first time seeing the schedule, runs are counted from now on
+	if state == nil {
+		return deployment.SaveScheduleState(deployment.ScheduleState{
+			Deployment:       config.Name,
+			Schedule:         schedule.Name,
+			LastScheduleTime: now,
+		})
+	}
+
+	latest, due := dueRuns(c, state.LastScheduleTime, now)
+	if due == 0 {
+		return nil
+	}
+
+	state.LastScheduleTime = latest
+
+	switch {
+	case schedule.Suspend:
+		logger.Debugf("Schedule %s for deployment %s is suspended, skipping run", schedule.Name, config.Name)
+		return deployment.SaveScheduleState(*state)
+	case isMissed(latest, now) && schedule.MissedRuns == deployment.SkipMissedRuns:
+		logger.Infof("Skipping %d missed run(s) of schedule %s for deployment %s", due, schedule.Name, config.Name)
+		return deployment.SaveScheduleState(*state)
+	case isMissed(latest, now):
+		logger.Infof("Running schedule %s for deployment %s once for %d missed run(s)", schedule.Name, config.Name, due)
+	}
+
+	if active := deployment.ActiveScheduledTasks(config.Name, schedule.Name); len(active) > 0 {
+		switch schedule.ConcurrencyPolicy {
+		case deployment.ForbidConcurrent:
+			logger.Infof("Skipping run of schedule %s for deployment %s, %d previous run(s) still active", schedule.Name, config.Name, len(active))
+			return deployment.SaveScheduleState(*state)
+		case deployment.ReplaceConcurrent:
+			logger.Infof("Replacing %d active run(s) of schedule %s for deployment %s", len(active), schedule.Name, config.Name)
+			deployment.CancelScheduledTasks(config.Name, schedule.Name)
+		}
+	}
+
+	j := deployment.NewScheduledTaskJob(config, schedule)
+	state.LastTaskID = j.ID
+	if err := deployment.SaveScheduleState(*state); err != nil {
+		return err
+	}
+
+	// enqueuing blocks while the job queue is full
+	go func() {
+		if _, err := s.enqueuer.Enqueue(j); err != nil {
+			logger.Error(errors.Wrapf(err, "unable to enqueue run of schedule %s for deployment %s", schedule.Name, config.Name))
+		}
+	}()
+
+	logger.Debugf("Queued task %s for schedule %s of deployment %s", j.ID, schedule.Name, config.Name)
+	return nil
+}
+
+// dueRuns returns the latest run of a schedule due after last and up to now along with the number of runs due
+func dueRuns(c cron.Schedule, last time.Time, now time.Time) (time.Time, int) {
+	var latest time.Time
+	due := 0
+	for next := c.Next(last); !next.IsZero() && !next.After(now); next = c.Next(next) {
+		latest = next
+		due++
+	}
+	return latest, due
+}
+
+// isMissed returns true if a run should have fired before now (ie. while Krane was not running)
+func isMissed(run time.Time, now time.Time) bool {
+	return now.Sub(run) > missedRunGracePeriod
+}
@@ -0,0 +1,34 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/krane/krane/internal/scheduler/cron"
+)
+
+func TestDueRuns(t *testing.T) {
+	c, _ := cron.Parse("0 2 * * *")
+	last := time.Date(2021, 1, 1, 2, 0, 0, 0, time.UTC)
+
+	// not due yet
+	latest, due := dueRuns(c, last, time.Date(2021, 1, 2, 1, 59, 30, 0, time.UTC))
+	assert.Equal(t, 0, due)
+	assert.True(t, latest.IsZero())
+
+	// due on time
+	now := time.Date(2021, 1, 2, 2, 0, 5, 0, time.UTC)
+	latest, due = dueRuns(c, last, now)
+	assert.Equal(t, 1, due)
+	assert.Equal(t, time.Date(2021, 1, 2, 2, 0, 0, 0, time.UTC), latest)
+	assert.False(t, isMissed(latest, now))
+
+	// 3 runs missed while not running
+	now = time.Date(2021, 1, 4, 9, 30, 0, 0, time.UTC)
+	latest, due = dueRuns(c, last, now)
+	assert.Equal(t, 3, due)
+	assert.Equal(t, time.Date(2021, 1, 4, 2, 0, 0, 0, time.UTC), latest)
+	assert.True(t, isMissed(latest, now))
+}